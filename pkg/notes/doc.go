@@ -0,0 +1,9 @@
+// Package notes is notectl's note storage: the note model, the SQLite schema
+// notes are kept in, queries over them and full-text search. The notectl
+// command is built on it, and other programs can embed it to read and write
+// the same database.
+//
+// The package does not register a database driver. Open the database with a
+// SQLite driver such as github.com/mattn/go-sqlite3, built with the
+// sqlite_fts5 tag for Search, and pass it to NewSQLiteStore.
+package notes
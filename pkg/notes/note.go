@@ -0,0 +1,59 @@
+package notes
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Note is a single note.
+type Note struct {
+	ID     int
+	Time   time.Time
+	Text   string
+	Tags   []string
+	Status string
+	Meta   map[string]string
+	UUID   string
+	Title  string
+}
+
+// FormatTags returns tags in the "[tag1 tag2]" form the tags column holds.
+func FormatTags(tags []string) string {
+	return fmt.Sprintf("%v", tags)
+}
+
+// ParseTags reads the tags column back into a list.
+func ParseTags(s string) []string {
+	return strings.Fields(strings.Trim(s, "[]"))
+}
+
+// NewUUID returns a random version 4 UUID.
+func NewUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Checksum returns the SHA-256 hash of a note's uncompressed text, as kept in
+// the checksum column.
+func Checksum(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// NotFoundError is returned when there is no note with the ID asked for.
+type NotFoundError struct {
+	ID int
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("no note with ID %d", e.ID)
+}
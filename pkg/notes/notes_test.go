@@ -0,0 +1,240 @@
+package notes
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestStore returns a store over a new database in a temporary directory.
+func openTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "notes-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database, err := sql.Open("sqlite3", filepath.Join(dir, "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.Close()
+		os.RemoveAll(dir)
+	})
+	store, err := NewSQLiteStore(database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// saveTestNotes saves notes created a minute apart, oldest first.
+func saveTestNotes(t *testing.T, store Store, list ...Note) {
+	t.Helper()
+	at := time.Date(2026, time.January, 1, 9, 0, 0, 0, time.UTC)
+	for i := range list {
+		list[i].Time = at.Add(time.Duration(i) * time.Minute)
+		if err := store.Save(&list[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeleteAllLeavesNothingForReusedIDs(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store,
+		Note{Text: "first", Meta: map[string]string{"alias": "first"}},
+		Note{Text: "second @ana"},
+	)
+	for _, statement := range []string{
+		"CREATE TABLE attachments (id INTEGER PRIMARY KEY, note_id INTEGER, name TEXT, mime TEXT, created INTEGER, data BLOB)",
+		"INSERT INTO attachments (note_id, name) VALUES (1, 'scan.pdf')",
+		"CREATE TABLE clock (id INTEGER PRIMARY KEY, note_id INTEGER, start INTEGER, end INTEGER)",
+		"INSERT INTO clock (note_id, start) VALUES (2, 0)",
+	} {
+		if _, err := store.DB.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Search("first", SearchOptions{}); errors.Is(err, ErrNoFTS5) {
+		t.Skip("SQLite built without FTS5, run with -tags sqlite_fts5")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteAll(store.DB); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range append([]string{"notes", "notes_fts"}, noteTables...) {
+		var count int
+		if err := store.DB.QueryRow("SELECT count(*) FROM " + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%s holds %d rows after DeleteAll", table, count)
+		}
+	}
+
+	saveTestNotes(t, store, Note{Text: "new"})
+	n, err := store.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(n.Meta) != 0 {
+		t.Errorf("new note 1 inherited metadata %v", n.Meta)
+	}
+}
+
+func TestDeleteAllWithoutLazyTables(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store, Note{Text: "only"})
+	if err := DeleteAll(store.DB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(1); err == nil {
+		t.Error("note 1 survived DeleteAll")
+	}
+}
+
+func TestDeleteRemovesEveryRowOfTheNote(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store,
+		Note{Text: "first @ana", Meta: map[string]string{"alias": "first"}},
+		Note{Text: "second @ana", Meta: map[string]string{"alias": "second"}},
+	)
+	for _, statement := range []string{
+		"CREATE TABLE attachments (id INTEGER PRIMARY KEY, note_id INTEGER, name TEXT, mime TEXT, created INTEGER, data BLOB)",
+		"INSERT INTO attachments (note_id, name) VALUES (1, 'scan.pdf'), (2, 'kept.pdf')",
+		"CREATE TABLE clock (id INTEGER PRIMARY KEY, note_id INTEGER, start INTEGER, end INTEGER)",
+		"INSERT INTO clock (note_id, start) VALUES (1, 0), (2, 0)",
+	} {
+		if _, err := store.DB.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range noteTables {
+		var deleted, kept int
+		if err := store.DB.QueryRow("SELECT count(*) FROM " + table + " WHERE note_id = 1").Scan(&deleted); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.DB.QueryRow("SELECT count(*) FROM " + table + " WHERE note_id = 2").Scan(&kept); err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 0 {
+			t.Errorf("%s holds %d rows of the deleted note", table, deleted)
+		}
+		if kept == 0 {
+			t.Errorf("%s lost the rows of the other note", table)
+		}
+	}
+}
+
+func TestSaveAndUpdateTextKeepMentions(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store, Note{Text: "ask @Ana and @bo.b, not mail@example.com"})
+	mentions := func() []string {
+		rows, err := store.DB.Query("SELECT person FROM mentions WHERE note_id = 1 ORDER BY person")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var people []string
+		for rows.Next() {
+			var person string
+			if err := rows.Scan(&person); err != nil {
+				t.Fatal(err)
+			}
+			people = append(people, person)
+		}
+		return people
+	}
+	if got := strings.Join(mentions(), ","); got != "ana,bo.b" {
+		t.Errorf("mentions after Save = %q, want ana,bo.b", got)
+	}
+	if err := UpdateText(store.DB, 1, "only @cy now", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(mentions(), ","); got != "cy" {
+		t.Errorf("mentions after UpdateText = %q, want cy", got)
+	}
+}
+
+func TestMentionsInMetadataSurviveTextChanges(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store, Note{Text: "Attendees: @ana @bo"})
+	if err := store.AddMentions(1, "Ana", "bo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateText(store.DB, 1, "attendee line edited away, @cy joined", 0); err != nil {
+		t.Fatal(err)
+	}
+	var people []string
+	rows, err := store.DB.Query("SELECT person FROM mentions WHERE note_id = 1 ORDER BY person")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var person string
+		if err := rows.Scan(&person); err != nil {
+			t.Fatal(err)
+		}
+		people = append(people, person)
+	}
+	if got := strings.Join(people, ","); got != "ana,bo,cy" {
+		t.Errorf("mentions after the text changed = %q, want ana,bo,cy", got)
+	}
+	n, err := store.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Meta[MentionKeyPrefix+"ana"] != "ana" {
+		t.Errorf("metadata %v does not keep the added mention", n.Meta)
+	}
+}
+
+func TestSetters(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store, Note{Text: "setters", Title: "Setters"})
+	modified := time.Date(2026, time.May, 4, 12, 0, 0, 0, time.UTC)
+	for _, set := range []func() error{
+		func() error { return store.SetStatus(1, "doing") },
+		func() error { return store.SetTitle(1, "") },
+		func() error { return store.SetUUID(1, "5f0c6f9e-0000-4000-8000-000000000001") },
+		func() error { return store.SetChecksum(1, "abc") },
+		func() error { return store.SetModified(1, modified) },
+		func() error { return store.AddMentions(1, "Ana", "bo") },
+	} {
+		if err := set(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var status, uuid, checksum string
+	var title sql.NullString
+	var stamp int64
+	if err := store.DB.QueryRow("SELECT status, title, uuid, checksum, modified FROM notes WHERE id = 1").Scan(&status, &title, &uuid, &checksum, &stamp); err != nil {
+		t.Fatal(err)
+	}
+	if status != "doing" || title.Valid || uuid != "5f0c6f9e-0000-4000-8000-000000000001" || checksum != "abc" || stamp != modified.Unix() {
+		t.Errorf("stored status %q, title %v, uuid %q, checksum %q, modified %d", status, title, uuid, checksum, stamp)
+	}
+	var mentions int
+	if err := store.DB.QueryRow("SELECT count(*) FROM mentions WHERE note_id = 1 AND person IN ('ana', 'bo')").Scan(&mentions); err != nil {
+		t.Fatal(err)
+	}
+	if mentions != 2 {
+		t.Errorf("%d mentions added, want 2", mentions)
+	}
+	if err := store.SetStatus(2, "done"); err != (NotFoundError{2}) {
+		t.Errorf("SetStatus of a missing note returned %v, want NotFoundError", err)
+	}
+}
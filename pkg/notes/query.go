@@ -0,0 +1,68 @@
+package notes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// IdentifierPattern matches the table and column names SQL is built from.
+// Identifiers cannot be bound as parameters, so they are checked instead.
+var IdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TagMatchClause matches a single tag against the tags column, which holds
+// the "[tag1 tag2]" form produced by FormatTags.
+const TagMatchClause = "instr(' ' || trim(tags, '[]') || ' ', ' ' || (?) || ' ') > 0"
+
+// Filter accumulates the conditions of a WHERE clause together with the
+// values bound to them. User-supplied values must only ever reach SQL through
+// the bound arguments, never by concatenation into a condition.
+type Filter struct {
+	Conditions []string
+	Args       []interface{}
+}
+
+// Add appends a condition ANDed with the others. The number of ? placeholders
+// in the condition must match the arguments given.
+func (f *Filter) Add(condition string, args ...interface{}) {
+	if strings.Count(condition, "?") != len(args) {
+		panic(fmt.Sprintf("query condition %q has %d placeholders but %d arguments", condition, strings.Count(condition, "?"), len(args)))
+	}
+	f.Conditions = append(f.Conditions, condition)
+	f.Args = append(f.Args, args...)
+}
+
+// Merge ANDs the conditions of other with those of f.
+func (f *Filter) Merge(other Filter) {
+	f.Conditions = append(f.Conditions, other.Conditions...)
+	f.Args = append(f.Args, other.Args...)
+}
+
+// Where returns the WHERE clause, including a leading space, or an empty
+// string when there are no conditions.
+func (f *Filter) Where() string {
+	if len(f.Conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.Conditions, " AND ")
+}
+
+// Query selects notes by SQL conditions on the notes table and by words that
+// must all appear in the text. Words are matched after loading, ignoring
+// case, because note text may be stored compressed.
+type Query struct {
+	Filter Filter
+	Words  []string
+}
+
+// MatchesText reports whether text contains every word of the query. Words
+// are expected in lower case.
+func (q Query) MatchesText(text string) bool {
+	lower := strings.ToLower(text)
+	for _, word := range q.Words {
+		if !strings.Contains(lower, word) {
+			return false
+		}
+	}
+	return true
+}
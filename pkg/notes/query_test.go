@@ -0,0 +1,155 @@
+package notes
+
+import (
+	"testing"
+)
+
+func TestFilterWhere(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(f *Filter)
+		where string
+		args  int
+	}{
+		{"empty", func(f *Filter) {}, "", 0},
+		{"one", func(f *Filter) { f.Add("status = (?)", "todo") }, " WHERE status = (?)", 1},
+		{"several", func(f *Filter) {
+			f.Add("status = (?)", "todo")
+			f.Add(TagMatchClause, "work")
+			f.Add("timestamp >= (?) AND timestamp < (?)", 1, 2)
+		}, " WHERE status = (?) AND " + TagMatchClause + " AND timestamp >= (?) AND timestamp < (?)", 4},
+		{"merged", func(f *Filter) {
+			f.Add("status = (?)", "todo")
+			var other Filter
+			other.Add("id = (?)", 3)
+			f.Merge(other)
+		}, " WHERE status = (?) AND id = (?)", 2},
+	}
+	for _, test := range tests {
+		var f Filter
+		test.build(&f)
+		if where := f.Where(); where != test.where {
+			t.Errorf("%s: Where() = %q, want %q", test.name, where, test.where)
+		}
+		if len(f.Args) != test.args {
+			t.Errorf("%s: %d args, want %d", test.name, len(f.Args), test.args)
+		}
+	}
+}
+
+func TestFilterAddChecksPlaceholders(t *testing.T) {
+	tests := []struct {
+		condition string
+		args      []interface{}
+	}{
+		{"tags = 'work'", []interface{}{"work"}},
+		{"status = (?)", nil},
+		{"id = (?) OR id = (?)", []interface{}{1}},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Add(%q) with %d args did not panic", test.condition, len(test.args))
+				}
+			}()
+			var f Filter
+			f.Add(test.condition, test.args...)
+		}()
+	}
+}
+
+// hostileInputs are values that would break out of a condition if they were
+// ever concatenated into SQL rather than bound.
+var hostileInputs = []string{
+	"x' OR '1'='1",
+	"x') OR 1=1 --",
+	"'; DROP TABLE notes; --",
+	`" OR ""="`,
+	"x%' OR tags LIKE '%",
+	"work ) OR ( 1",
+	"\x00",
+	"?",
+}
+
+func TestHostileTagsAndTitles(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store,
+		Note{Text: "plain work note", Tags: []string{"work"}, Title: "Work"},
+		Note{Text: "private note", Tags: []string{"private"}, Title: "Private"},
+	)
+	for _, input := range hostileInputs {
+		saveTestNotes(t, store, Note{Text: "hostile " + input, Tags: []string{"hostile"}, Title: input, Meta: map[string]string{"source": input}})
+	}
+	for _, input := range hostileInputs {
+		var query Query
+		query.Filter.Add(TagMatchClause, input)
+		found, err := store.List(query)
+		if err != nil {
+			t.Errorf("tag %q: %s", input, err)
+			continue
+		}
+		if len(found) != 0 {
+			t.Errorf("tag %q matched %d notes", input, len(found))
+		}
+
+		query = Query{}
+		query.Filter.Add("title = (?)", input)
+		found, err = store.List(query)
+		if err != nil {
+			t.Errorf("title %q: %s", input, err)
+			continue
+		}
+		if len(found) != 1 || found[0].Title != input {
+			t.Errorf("title %q matched %d notes, want only its own", input, len(found))
+		}
+	}
+	all, err := store.List(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2 + len(hostileInputs); len(all) != want {
+		t.Fatalf("%d notes after hostile queries, want %d", len(all), want)
+	}
+	for _, n := range all {
+		stored, err := store.Get(n.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.Tags) == 1 && stored.Tags[0] == "hostile" && stored.Meta["source"] != stored.Title {
+			t.Errorf("note %d: metadata %q, want %q", n.ID, stored.Meta["source"], stored.Title)
+		}
+	}
+}
+
+func TestHostileSearch(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store,
+		Note{Text: "quarterly planning notes", Tags: []string{"work"}, Title: "Planning"},
+		Note{Text: "grocery list", Tags: []string{"home"}, Title: "Groceries"},
+	)
+	if _, err := store.Search(FTSQuery("planning"), SearchOptions{}); err == ErrNoFTS5 {
+		t.Skip("SQLite built without FTS5, run with -tags sqlite_fts5")
+	}
+	for _, input := range hostileInputs {
+		results, err := store.Search(FTSQuery(input+" planning"), SearchOptions{Tags: []string{input}})
+		if err != nil {
+			t.Errorf("search %q: %s", input, err)
+			continue
+		}
+		if len(results) != 0 {
+			t.Errorf("search %q with tag %q found %d notes", input, input, len(results))
+		}
+	}
+	for _, input := range hostileInputs {
+		// Raw FTS5 syntax may be rejected, but must not reach beyond MATCH.
+		store.Search(input, SearchOptions{MarkStart: input, MarkEnd: input, Ellipsis: input})
+	}
+	results, err := store.Search(FTSQuery("planning"), SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Title != "Planning" {
+		t.Errorf("search after hostile input found %+v", results)
+	}
+}
@@ -0,0 +1,81 @@
+package notes
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ColumnMigrations are columns added to the notes table after its original
+// release, in the order they were introduced.
+var ColumnMigrations = []struct {
+	Column     string
+	Definition string
+}{
+	{"status", "TEXT NOT NULL DEFAULT ''"},
+	{"compressed", "INTEGER NOT NULL DEFAULT 0"},
+	{"textsize", "INTEGER"},
+	{"checksum", "TEXT"},
+	{"uuid", "TEXT"},
+	{"title", "TEXT"},
+	{"modified", "INTEGER"},
+}
+
+// noteTables are the tables besides notes whose rows belong to a note through
+// their note_id column. Aliases are kept in metadata. The attachments and clock
+// tables are only created once they are first used.
+var noteTables = []string{"metadata", "mentions", "attachments", "clock"}
+
+// CreateSchema creates the notes, metadata and mentions tables and the search
+// index's stale flag, and adds the columns of ColumnMigrations to databases
+// created before them.
+func CreateSchema(database *sql.DB) error {
+	if _, err := database.Exec("CREATE TABLE IF NOT EXISTS notes (id INTEGER PRIMARY KEY, day INTEGER, month INTEGER, year INTEGER, timestamp INTEGER, notetext BLOB, tags TEXT)"); err != nil {
+		return err
+	}
+	for _, statement := range []string{
+		"CREATE TABLE IF NOT EXISTS metadata (note_id INTEGER, key TEXT, value TEXT, PRIMARY KEY (note_id, key))",
+		"CREATE TABLE IF NOT EXISTS mentions (note_id INTEGER, person TEXT, PRIMARY KEY (note_id, person))",
+		"CREATE TABLE IF NOT EXISTS notes_fts_stale (stale INTEGER PRIMARY KEY)",
+	} {
+		if _, err := database.Exec(statement); err != nil {
+			return err
+		}
+	}
+	for _, migration := range ColumnMigrations {
+		if err := AddColumnIfNotExist(database, "notes", migration.Column, migration.Definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddColumnIfNotExist upgrades databases created before a column was
+// introduced.
+func AddColumnIfNotExist(database *sql.DB, table string, column string, definition string) error {
+	if !IdentifierPattern.MatchString(table) {
+		return fmt.Errorf("invalid table name %q", table)
+	}
+	rows, err := database.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	var cid, notnull, pk int
+	var name, ctype string
+	var dflt sql.NullString
+	found := false
+	for rows.Next() {
+		rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk)
+		if name == column {
+			found = true
+		}
+	}
+	rows.Close()
+	if found {
+		return nil
+	}
+	if !IdentifierPattern.MatchString(column) {
+		return fmt.Errorf("invalid column name %q", column)
+	}
+	_, err = database.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
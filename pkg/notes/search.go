@@ -0,0 +1,184 @@
+package notes
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The search index is an FTS5 table keyed by note ID. It is kept by this
+// package rather than by triggers because note text may be stored compressed,
+// and is rebuilt whenever it has fallen out of step with the notes, for
+// example after notes were saved through a driver built without FTS5.
+
+// ErrNoFTS5 is returned when SQLite was built without the FTS5 extension.
+var ErrNoFTS5 = errors.New("full-text search needs SQLite built with FTS5, such as go-sqlite3 with the sqlite_fts5 tag")
+
+// SearchResult is a note found by Search.
+type SearchResult struct {
+	ID      int       `json:"id"`
+	Created time.Time `json:"created"`
+	Title   string    `json:"title"`
+	Tags    []string  `json:"tags"`
+	Snippet string    `json:"snippet"`
+	Rank    float64   `json:"rank"`
+}
+
+// SearchOptions narrow and format a search. Snippets mark the matched terms
+// with MarkStart and MarkEnd and use Ellipsis where text was cut.
+type SearchOptions struct {
+	Tags      []string
+	Limit     int
+	MarkStart string
+	MarkEnd   string
+	Ellipsis  string
+}
+
+// indexExec updates the search index, doing nothing when there is no index
+// yet, as the next search builds it. A build of SQLite without FTS5 cannot
+// write an index made by one with it, so the index is marked stale instead and
+// the next search that can use it rebuilds it. Either way the note itself has
+// been written, and its write does not fail.
+func indexExec(database Execer, query string, args ...interface{}) error {
+	_, err := database.Exec(query, args...)
+	switch {
+	case err == nil, strings.Contains(err.Error(), "no such table: notes_fts"):
+		return nil
+	case strings.Contains(err.Error(), "no such module: fts5"):
+		_, err = database.Exec("INSERT OR IGNORE INTO notes_fts_stale (stale) VALUES (1)")
+	}
+	return err
+}
+
+// IndexNote adds a note to the search index, replacing what was indexed for
+// it before.
+func IndexNote(database Execer, id int, title string, text string, tags []string) error {
+	if err := indexExec(database, "DELETE FROM notes_fts WHERE rowid = (?)", id); err != nil {
+		return err
+	}
+	return indexExec(database, "INSERT INTO notes_fts (rowid, title, body, tagtext) VALUES (?, ?, ?, ?)", id, title, text, strings.Join(tags, " "))
+}
+
+// EnsureSearchIndex creates the search index, and rebuilds it when it does not
+// hold exactly the notes there are or was marked stale.
+func EnsureSearchIndex(database *sql.DB) error {
+	_, err := database.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS notes_fts USING fts5(title, body, tagtext, tokenize = 'unicode61 remove_diacritics 2')")
+	if err != nil {
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return ErrNoFTS5
+		}
+		return err
+	}
+	var stale bool
+	err = database.QueryRow("SELECT (SELECT count(*) FROM notes) != (SELECT count(*) FROM notes_fts) OR EXISTS (SELECT 1 FROM notes WHERE id NOT IN (SELECT rowid FROM notes_fts)) OR EXISTS (SELECT 1 FROM notes_fts_stale)").Scan(&stale)
+	if err != nil || !stale {
+		return err
+	}
+	return RebuildSearchIndex(database)
+}
+
+// RebuildSearchIndex indexes every note afresh.
+func RebuildSearchIndex(database *sql.DB) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM notes_fts"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM notes_fts_stale"); err != nil {
+		return err
+	}
+	rows, err := tx.Query("SELECT id, title, " + TextColumn + ", tags FROM notes")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var title sql.NullString
+		var text Text
+		var tags string
+		if err := rows.Scan(&id, &title, &text, &tags); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO notes_fts (rowid, title, body, tagtext) VALUES (?, ?, ?, ?)", id, title.String, string(text), strings.Join(ParseTags(tags), " ")); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	return tx.Commit()
+}
+
+// FTSQuery turns plain search words into an FTS5 query matching notes with
+// all of them, so punctuation in the words is not taken as query syntax. A
+// trailing * still matches words by prefix. NUL characters are dropped, as
+// SQLite would end the query string at them.
+func FTSQuery(words string) string {
+	var terms []string
+	for _, word := range strings.Fields(strings.Replace(words, "\x00", "", -1)) {
+		prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+		term := `"` + strings.Replace(word, `"`, `""`, -1) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, " ")
+}
+
+// search returns the notes matching an FTS5 query, best matches first.
+// Matches in titles rank above matches in tags, which rank above matches in
+// the body.
+func search(database *sql.DB, match string, options SearchOptions) ([]SearchResult, error) {
+	if strings.TrimSpace(match) == "" {
+		return nil, errors.New("nothing to search for")
+	}
+	if err := EnsureSearchIndex(database); err != nil {
+		return nil, err
+	}
+	var filter Filter
+	filter.Add("notes_fts MATCH (?)", match)
+	for _, tag := range options.Tags {
+		filter.Add(TagMatchClause, tag)
+	}
+	query := "SELECT notes.id, notes.timestamp, notes.title, notes.tags, snippet(notes_fts, -1, (?), (?), (?), 16), bm25(notes_fts, 10.0, 1.0, 5.0) AS rank FROM notes_fts JOIN notes ON notes.id = notes_fts.rowid" + filter.Where() + " ORDER BY rank"
+	args := append([]interface{}{options.MarkStart, options.MarkEnd, options.Ellipsis}, filter.Args...)
+	if options.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", options.Limit)
+	}
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "fts5: syntax error") {
+			return nil, fmt.Errorf("invalid search query %q: %s", match, err)
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	results := []SearchResult{}
+	for rows.Next() {
+		var r SearchResult
+		var timestamp int64
+		var title sql.NullString
+		var tagText string
+		if err := rows.Scan(&r.ID, &timestamp, &title, &tagText, &r.Snippet, &r.Rank); err != nil {
+			return nil, err
+		}
+		r.Created = time.Unix(timestamp, 0)
+		r.Title = title.String
+		r.Tags = ParseTags(tagText)
+		r.Snippet = strings.Join(strings.Fields(r.Snippet), " ")
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,60 @@
+package notes
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// noFTS5 is a database opened by a build of SQLite without FTS5: statements
+// on the search index fail as they would there.
+type noFTS5 struct {
+	*sql.DB
+}
+
+func (d noFTS5) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if strings.Contains(query, "notes_fts ") || strings.HasSuffix(query, "notes_fts") {
+		return nil, errors.New("no such module: fts5")
+	}
+	return d.DB.Exec(query, args...)
+}
+
+func TestWritesWithoutFTS5MarkTheIndexStale(t *testing.T) {
+	store := openTestStore(t)
+	saveTestNotes(t, store, Note{Text: "apples", Tags: []string{"fruit"}})
+	if _, err := store.Search("apples", SearchOptions{}); errors.Is(err, ErrNoFTS5) {
+		t.Skip("SQLite built without FTS5, run with -tags sqlite_fts5")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	database := noFTS5{store.DB}
+	if err := UpdateText(database, 1, "bananas", 0); err != nil {
+		t.Fatalf("UpdateText failed without FTS5: %s", err)
+	}
+	if err := UpdateTags(database, 1, []string{"yellow"}); err != nil {
+		t.Fatalf("UpdateTags failed without FTS5: %s", err)
+	}
+	var stale int
+	if err := store.DB.QueryRow("SELECT count(*) FROM notes_fts_stale").Scan(&stale); err != nil {
+		t.Fatal(err)
+	}
+	if stale != 1 {
+		t.Fatalf("the index was not marked stale")
+	}
+
+	results, err := store.Search("bananas", SearchOptions{Tags: []string{"yellow"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != 1 {
+		t.Errorf("search after the stale index was rebuilt found %v", results)
+	}
+	if err := store.DB.QueryRow("SELECT count(*) FROM notes_fts_stale").Scan(&stale); err != nil {
+		t.Fatal(err)
+	}
+	if stale != 0 {
+		t.Error("rebuilding the index left it marked stale")
+	}
+}
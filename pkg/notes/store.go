@@ -0,0 +1,396 @@
+package notes
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Store keeps notes.
+type Store interface {
+	// Save adds a note, setting its ID and, when it has none, its UUID. A
+	// note that already has an ID keeps it.
+	Save(n *Note) error
+	// Get returns a note with its metadata, or a NotFoundError.
+	Get(id int) (Note, error)
+	// List returns the notes matching a query, oldest first, without their
+	// metadata.
+	List(query Query) ([]Note, error)
+	// Delete removes a note and everything kept about it.
+	Delete(id int) error
+	// Search returns the notes matching an FTS5 query, best matches first.
+	Search(match string, options SearchOptions) ([]SearchResult, error)
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx, so updates can run inside
+// a transaction when a caller needs several to apply together.
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// SQLiteStore is a Store kept in a SQLite database.
+type SQLiteStore struct {
+	DB *sql.DB
+	// CompressionThreshold is the text size, in bytes, above which note text
+	// is stored gzipped. Negative turns compression off.
+	CompressionThreshold int
+}
+
+// NewSQLiteStore returns a store over database, creating or migrating the
+// schema. Compression is off.
+func NewSQLiteStore(database *sql.DB) (*SQLiteStore, error) {
+	if err := CreateSchema(database); err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{DB: database, CompressionThreshold: -1}, nil
+}
+
+// Save stores the note with its metadata, search index entry and mentions in
+// one transaction.
+func (s *SQLiteStore) Save(n *Note) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	saved := *n
+	if err := InsertNote(tx, &saved, s.CompressionThreshold); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	n.ID, n.UUID = saved.ID, saved.UUID
+	return nil
+}
+
+// InsertNote is SQLiteStore.Save for a database or transaction, storing text
+// compressed when it reaches threshold as EncodeText does. Callers saving
+// through a database rather than a transaction should use Save, which keeps
+// the note's rows in step.
+func InsertNote(database Execer, n *Note, threshold int) error {
+	if n.UUID == "" {
+		n.UUID = NewUUID()
+	}
+	text, compressed := EncodeText(n.Text, threshold)
+	var presetID interface{}
+	if n.ID != 0 {
+		presetID = n.ID
+	}
+	result, err := database.Exec("INSERT INTO notes (id, day, month, year, timestamp, notetext, tags, status, compressed, textsize, checksum, uuid, title) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		presetID, n.Time.Day(), n.Time.Month(), n.Time.Year(), n.Time.Unix(), text, FormatTags(n.Tags), n.Status, compressed, len(n.Text), Checksum(n.Text), n.UUID, n.Title)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	if err := IndexNote(database, int(id), n.Title, n.Text, n.Tags); err != nil {
+		return err
+	}
+	for key, value := range n.Meta {
+		if err := SetMeta(database, int(id), key, value); err != nil {
+			return err
+		}
+	}
+	if err := UpdateMentions(database, int(id), n.Text); err != nil {
+		return err
+	}
+	n.ID = int(id)
+	return nil
+}
+
+func (s *SQLiteStore) Get(id int) (Note, error) {
+	var query Query
+	query.Filter.Add("id = (?)", id)
+	found, err := s.List(query)
+	if err != nil {
+		return Note{}, err
+	}
+	if len(found) == 0 {
+		return Note{}, NotFoundError{id}
+	}
+	n := found[0]
+	if n.Meta, err = GetMeta(s.DB, id); err != nil {
+		return Note{}, err
+	}
+	return n, nil
+}
+
+func (s *SQLiteStore) List(query Query) ([]Note, error) {
+	rows, err := s.DB.Query("SELECT id, timestamp, "+TextColumn+", tags, status, COALESCE(uuid, ''), COALESCE(title, '') FROM notes"+query.Filter.Where()+" ORDER BY timestamp, id", query.Filter.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var found []Note
+	for rows.Next() {
+		var n Note
+		var timestamp int64
+		var text Text
+		var tags sql.NullString
+		if err := rows.Scan(&n.ID, &timestamp, &text, &tags, &n.Status, &n.UUID, &n.Title); err != nil {
+			return nil, err
+		}
+		if !query.MatchesText(string(text)) {
+			continue
+		}
+		n.Time = time.Unix(timestamp, 0)
+		n.Text = string(text)
+		n.Tags = ParseTags(tags.String)
+		found = append(found, n)
+	}
+	return found, rows.Err()
+}
+
+// Delete removes a note and every row belonging to it in one transaction.
+// Attachment data kept outside the database is left to the caller.
+func (s *SQLiteStore) Delete(id int) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := DeleteNote(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Search(match string, options SearchOptions) ([]SearchResult, error) {
+	return search(s.DB, match, options)
+}
+
+// SetText replaces a note's text and marks it modified, keeping its
+// compression, size, checksum and search index in step.
+func (s *SQLiteStore) SetText(id int, text string) error {
+	return UpdateText(s.DB, id, text, s.CompressionThreshold)
+}
+
+// SetStatus replaces a note's status, returning a NotFoundError when there is
+// no such note.
+func (s *SQLiteStore) SetStatus(id int, status string) error {
+	return UpdateStatus(s.DB, id, status)
+}
+
+// SetTitle replaces a note's title. An empty title is stored as none.
+func (s *SQLiteStore) SetTitle(id int, title string) error {
+	return UpdateTitle(s.DB, id, title)
+}
+
+// SetUUID replaces a note's UUID. An empty UUID is stored as none.
+func (s *SQLiteStore) SetUUID(id int, uuid string) error {
+	return UpdateUUID(s.DB, id, uuid)
+}
+
+// SetChecksum stores the checksum of a note's text, as after the text was
+// changed outside notectl on purpose.
+func (s *SQLiteStore) SetChecksum(id int, checksum string) error {
+	return UpdateChecksum(s.DB, id, checksum)
+}
+
+// SetModified replaces the time a note was last modified.
+func (s *SQLiteStore) SetModified(id int, modified time.Time) error {
+	return UpdateModified(s.DB, id, modified)
+}
+
+// AddMentions links a note to people besides those its text @mentions, such
+// as a meeting's attendees. They are kept in its metadata under
+// MentionKeyPrefix, so they stay linked when the text changes.
+func (s *SQLiteStore) AddMentions(id int, people ...string) error {
+	return AddMentions(s.DB, id, people...)
+}
+
+// GetMeta returns a note's metadata.
+func GetMeta(database *sql.DB, id int) (map[string]string, error) {
+	rows, err := database.Query("SELECT key, value FROM metadata WHERE note_id = (?)", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	meta := make(map[string]string)
+	var key, value string
+	for rows.Next() {
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		meta[key] = value
+	}
+	return meta, rows.Err()
+}
+
+// MentionKeyPrefix starts the metadata keys of people a note mentions besides
+// those in its text, each holding the person's name. Removing one unlinks the
+// person the next time the text changes.
+const MentionKeyPrefix = "mention:"
+
+// SetMeta sets a metadata key of a note, replacing its value.
+func SetMeta(database Execer, id int, key string, value string) error {
+	if _, err := database.Exec("INSERT OR REPLACE INTO metadata (note_id, key, value) VALUES (?, ?, ?)", id, key, value); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(key, MentionKeyPrefix) {
+		return nil
+	}
+	_, err := database.Exec("INSERT OR IGNORE INTO mentions (note_id, person) VALUES (?, ?)", id, strings.ToLower(value))
+	return err
+}
+
+// DeleteMeta removes a metadata key from a note.
+func DeleteMeta(database Execer, id int, key string) error {
+	_, err := database.Exec("DELETE FROM metadata WHERE note_id = (?) AND key = (?)", id, key)
+	return err
+}
+
+// mentionPattern finds @name references to people in note text.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w][\w.-]*\w|\w)`)
+
+// ParseMentions returns the distinct, lower-cased people mentioned in text.
+func ParseMentions(text string) []string {
+	var mentions []string
+	seen := make(map[string]bool)
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(m[1])
+		if !seen[name] {
+			seen[name] = true
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions
+}
+
+// UpdateMentions links a note to the people its text @mentions and those kept
+// in its metadata under MentionKeyPrefix.
+func UpdateMentions(database Execer, id int, text string) error {
+	if _, err := database.Exec("DELETE FROM mentions WHERE note_id = (?)", id); err != nil {
+		return err
+	}
+	for _, person := range ParseMentions(text) {
+		if _, err := database.Exec("INSERT INTO mentions (note_id, person) VALUES (?, ?)", id, person); err != nil {
+			return err
+		}
+	}
+	_, err := database.Exec("INSERT OR IGNORE INTO mentions (note_id, person) SELECT note_id, lower(value) FROM metadata WHERE note_id = (?) AND key LIKE (?)", id, MentionKeyPrefix+"%")
+	return err
+}
+
+// AddMentions is SQLiteStore.AddMentions for a database or transaction.
+func AddMentions(database Execer, id int, people ...string) error {
+	for _, person := range people {
+		person = strings.ToLower(person)
+		if err := SetMeta(database, id, MentionKeyPrefix+person, person); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateText is SQLiteStore.SetText for a database or transaction.
+func UpdateText(database Execer, id int, text string, threshold int) error {
+	stored, compressed := EncodeText(text, threshold)
+	if _, err := database.Exec("UPDATE notes SET notetext = (?), compressed = (?), textsize = (?), checksum = (?), modified = (?) WHERE id = (?)", stored, compressed, len(text), Checksum(text), time.Now().Unix(), id); err != nil {
+		return err
+	}
+	if err := UpdateMentions(database, id, text); err != nil {
+		return err
+	}
+	return indexExec(database, "UPDATE notes_fts SET body = (?) WHERE rowid = (?)", text, id)
+}
+
+// UpdateTags replaces a note's tags.
+func UpdateTags(database Execer, id int, tags []string) error {
+	if _, err := database.Exec("UPDATE notes SET tags = (?) WHERE id = (?)", FormatTags(tags), id); err != nil {
+		return err
+	}
+	return indexExec(database, "UPDATE notes_fts SET tagtext = (?) WHERE rowid = (?)", strings.Join(tags, " "), id)
+}
+
+// UpdateStatus is SQLiteStore.SetStatus for a database or transaction.
+func UpdateStatus(database Execer, id int, status string) error {
+	result, err := database.Exec("UPDATE notes SET status = (?) WHERE id = (?)", status, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return NotFoundError{id}
+	}
+	return nil
+}
+
+// UpdateTitle is SQLiteStore.SetTitle for a database or transaction.
+func UpdateTitle(database Execer, id int, title string) error {
+	var stored interface{}
+	if title != "" {
+		stored = title
+	}
+	_, err := database.Exec("UPDATE notes SET title = (?) WHERE id = (?)", stored, id)
+	return err
+}
+
+// UpdateUUID is SQLiteStore.SetUUID for a database or transaction.
+func UpdateUUID(database Execer, id int, uuid string) error {
+	var stored interface{}
+	if uuid != "" {
+		stored = uuid
+	}
+	_, err := database.Exec("UPDATE notes SET uuid = (?) WHERE id = (?)", stored, id)
+	return err
+}
+
+// UpdateChecksum is SQLiteStore.SetChecksum for a database or transaction.
+func UpdateChecksum(database Execer, id int, checksum string) error {
+	_, err := database.Exec("UPDATE notes SET checksum = (?) WHERE id = (?)", checksum, id)
+	return err
+}
+
+// UpdateModified is SQLiteStore.SetModified for a database or transaction.
+func UpdateModified(database Execer, id int, modified time.Time) error {
+	_, err := database.Exec("UPDATE notes SET modified = (?) WHERE id = (?)", modified.Unix(), id)
+	return err
+}
+
+// DeleteNote is SQLiteStore.Delete for a database or transaction. With the
+// note go its metadata, including aliases, its mentions, attachments and clock
+// entries, and its search index entry.
+func DeleteNote(database Execer, id int) error {
+	if _, err := database.Exec("DELETE FROM notes WHERE id = (?)", id); err != nil {
+		return err
+	}
+	if err := deleteNoteRows(database, " WHERE note_id = (?)", id); err != nil {
+		return err
+	}
+	return indexExec(database, "DELETE FROM notes_fts WHERE rowid = (?)", id)
+}
+
+// DeleteAll removes every note and every row belonging to one, in a single
+// transaction. The tables are emptied rather than dropped and created again, so
+// no metadata, attachment or clock entry is left behind for a new note that is
+// given a deleted note's ID.
+func DeleteAll(database *sql.DB) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM notes"); err != nil {
+		return err
+	}
+	if err := deleteNoteRows(tx, ""); err != nil {
+		return err
+	}
+	if err := indexExec(tx, "DELETE FROM notes_fts"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteNoteRows deletes the rows of every table in noteTables matching where,
+// skipping the tables that have not been created yet.
+func deleteNoteRows(database Execer, where string, args ...interface{}) error {
+	for _, table := range noteTables {
+		_, err := database.Exec("DELETE FROM "+table+where, args...)
+		if err != nil && !strings.Contains(err.Error(), "no such table: "+table) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package notes
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// TextColumn selects the notetext column for scanning into Text. The
+// compressed column is passed along as a leading flag byte, so Text knows
+// whether to decompress without guessing from the stored bytes.
+const TextColumn = "(CASE WHEN compressed = 1 THEN X'01' ELSE X'00' END || notetext)"
+
+// QualifiedTextColumn is TextColumn for queries joining notes with other
+// tables.
+const QualifiedTextColumn = "(CASE WHEN notes.compressed = 1 THEN X'01' ELSE X'00' END || notes.notetext)"
+
+// Text scans note text selected with TextColumn, decompressing notes that
+// were stored gzipped.
+type Text string
+
+func (t *Text) Scan(value interface{}) error {
+	var stored []byte
+	switch v := value.(type) {
+	case nil:
+		*t = ""
+		return nil
+	case string:
+		stored = []byte(v)
+	case []byte:
+		stored = v
+	default:
+		return fmt.Errorf("cannot scan %T into note text", value)
+	}
+	if len(stored) == 0 || stored[0] > 1 {
+		return errors.New("note text must be selected with notes.TextColumn")
+	}
+	if stored[0] == 0 {
+		*t = Text(stored[1:])
+		return nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(stored[1:]))
+	if err != nil {
+		return err
+	}
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	*t = Text(text)
+	return nil
+}
+
+// EncodeText returns the value to store in the notetext column and whether it
+// was compressed. Text longer than threshold bytes is gzipped, unless that
+// would not make it smaller; a negative threshold never compresses.
+func EncodeText(text string, threshold int) (interface{}, bool) {
+	if threshold < 0 || len(text) <= threshold {
+		return text, false
+	}
+	var buf bytes.Buffer
+	writer, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	writer.Write([]byte(text))
+	if err := writer.Close(); err != nil || buf.Len() >= len(text) {
+		return text, false
+	}
+	return buf.Bytes(), true
+}
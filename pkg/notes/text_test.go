@@ -0,0 +1,47 @@
+package notes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompressionFollowsColumnFlag(t *testing.T) {
+	store := openTestStore(t)
+	store.CompressionThreshold = 64
+	tests := []struct {
+		name       string
+		text       string
+		compressed bool
+	}{
+		{"short", "a short note", false},
+		{"long", strings.Repeat("a line of a pasted log\n", 100), true},
+		{"gzip magic, uncompressed", "\x1f\x8b looks like gzip but is not", false},
+		{"empty", "", false},
+	}
+	for _, test := range tests {
+		n := Note{Text: test.text}
+		saveTestNotes(t, store, n)
+		var id int
+		var compressed bool
+		if err := store.DB.QueryRow("SELECT id, compressed FROM notes ORDER BY id DESC LIMIT 1").Scan(&id, &compressed); err != nil {
+			t.Fatal(err)
+		}
+		if compressed != test.compressed {
+			t.Errorf("%s: compressed = %t, want %t", test.name, compressed, test.compressed)
+		}
+		got, err := store.Get(id)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got.Text != test.text {
+			t.Errorf("%s: read back %q", test.name, got.Text)
+		}
+		if err := store.SetText(id, test.text+" edited"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err = store.Get(id); err != nil || got.Text != test.text+" edited" {
+			t.Errorf("%s: after SetText read back %q, %v", test.name, got.Text, err)
+		}
+	}
+}
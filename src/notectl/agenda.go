@@ -0,0 +1,142 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// dueDateFormat is how due dates are written on the command line and stored.
+const dueDateFormat = "2006-01-02"
+
+// openCheckboxPattern matches unticked Markdown task list items.
+var openCheckboxPattern = regexp.MustCompile(`^\s*[-*+]\s+\[ \]\s+(\S.*)$`)
+
+type agendaItem struct {
+	ID   int
+	When time.Time
+	Text string
+}
+
+// printAgendaSection prints a titled list of items, prefixing each with its
+// time in the given layout unless layout is empty.
+func printAgendaSection(title string, items []agendaItem, layout string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Printf("%s\n", title)
+	for _, item := range items {
+		if layout != "" {
+			fmt.Printf("  %s  #%d %s\n", formatLocal(item.When, layout), item.ID, item.Text)
+		} else {
+			fmt.Printf("  #%d %s\n", item.ID, item.Text)
+		}
+	}
+	fmt.Println()
+}
+
+// showAgenda lists, most urgent first, overdue notes, notes due in the range,
+// meetings in the range and open checkboxes in notes that are not done.
+func showAgenda(from time.Time, to time.Time, database *sql.DB) error {
+	var overdue, due, meetings, checkboxes []agendaItem
+
+	rows, err := database.Query("SELECT notes.id, "+notes.QualifiedTextColumn+", metadata.value FROM notes JOIN metadata ON metadata.note_id = notes.id WHERE metadata.key = 'due' AND notes.status NOT IN ('done', 'archived') AND metadata.value < (?) ORDER BY metadata.value", to.Format(dueDateFormat))
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item agendaItem
+		var notetext noteText
+		var when string
+		if err := rows.Scan(&item.ID, &notetext, &when); err != nil {
+			rows.Close()
+			return err
+		}
+		item.When, _ = time.ParseInLocation(dueDateFormat, when, time.Local)
+		item.Text = firstLine(string(notetext), 60)
+		if item.When.Before(from) {
+			overdue = append(overdue, item)
+		} else {
+			due = append(due, item)
+		}
+	}
+	rows.Close()
+
+	rows, err = database.Query("SELECT id, timestamp, "+notes.TextColumn+" FROM notes WHERE "+tagMatchClause+" AND timestamp >= (?) AND timestamp < (?) ORDER BY timestamp", meetingTag, from.Unix(), to.Unix())
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item agendaItem
+		var timestamp int64
+		var notetext noteText
+		if err := rows.Scan(&item.ID, &timestamp, &notetext); err != nil {
+			rows.Close()
+			return err
+		}
+		item.When = time.Unix(timestamp, 0)
+		item.Text = firstLine(strings.TrimLeft(string(notetext), "# "), 60)
+		meetings = append(meetings, item)
+	}
+	rows.Close()
+
+	rows, err = database.Query("SELECT id, " + notes.TextColumn + " FROM notes WHERE status NOT IN ('done', 'archived') AND (compressed = 1 OR notetext LIKE '%[ ]%') ORDER BY timestamp")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var id int
+		var notetext noteText
+		if err := rows.Scan(&id, &notetext); err != nil {
+			rows.Close()
+			return err
+		}
+		for _, line := range strings.Split(string(notetext), "\n") {
+			if m := openCheckboxPattern.FindStringSubmatch(line); m != nil {
+				checkboxes = append(checkboxes, agendaItem{ID: id, Text: m[1]})
+			}
+		}
+	}
+	rows.Close()
+
+	if len(overdue)+len(due)+len(meetings)+len(checkboxes) == 0 {
+		fmt.Println("Nothing on the agenda.")
+		return nil
+	}
+	fmt.Printf("Agenda for %s to %s\n\n", formatLocal(from, "Mon 2 Jan"), formatLocal(to.AddDate(0, 0, -1), "Mon 2 Jan"))
+	printAgendaSection("Overdue", overdue, "Mon 2 Jan")
+	printAgendaSection("Due", due, "Mon 2 Jan")
+	printAgendaSection("Meetings", meetings, "Mon 2 Jan 15:04")
+	printAgendaSection("Open tasks", checkboxes, "")
+	return nil
+}
+
+// runAgenda prints what is due or scheduled today or this week.
+func runAgenda(args []string) error {
+	agendaCommand := newFlagSet("agenda")
+	agendaTodayPtr := agendaCommand.Bool("today", false, "Only show what is due or scheduled today (the default).")
+	agendaWeekPtr := agendaCommand.Bool("week", false, "Show what is due or scheduled this week.")
+	if err := parseFlags(agendaCommand, args); err != nil {
+		return err
+	}
+	if *agendaTodayPtr && *agendaWeekPtr {
+		return errors.New("use only one of -today and -week")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, 1)
+	if *agendaWeekPtr {
+		from = startOfWeek(now)
+		to = from.AddDate(0, 0, 7)
+	}
+	return showAgenda(from, to, database)
+}
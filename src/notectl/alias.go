@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aliasKey is the metadata key holding a note's alias.
+const aliasKey = "alias"
+
+var aliasPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// lastNotePattern matches "last", the most recently created or modified
+// note, and "last~N", the note N before it.
+var lastNotePattern = regexp.MustCompile(`^last(?:~(\d+))?$`)
+
+func validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("invalid alias %q, use lowercase letters, digits, - and _", alias)
+	}
+	if _, err := strconv.Atoi(alias); err == nil {
+		return fmt.Errorf("invalid alias %q, aliases cannot be plain numbers", alias)
+	}
+	if _, _, ok := parseIDRange(alias); ok {
+		return fmt.Errorf("invalid alias %q, aliases cannot look like ID ranges", alias)
+	}
+	if lastNotePattern.MatchString(alias) {
+		return fmt.Errorf("invalid alias %q, it refers to the most recent notes", alias)
+	}
+	if _, _, ok := dateSelectorRange(alias, time.Now()); ok {
+		return fmt.Errorf("invalid alias %q, it refers to the notes of a period", alias)
+	}
+	return nil
+}
+
+// parseIDRange splits an ID range such as 10-20 into its first and last ID.
+func parseIDRange(s string) (int, int, bool) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	first, err := strconv.Atoi(strings.TrimPrefix(parts[0], "#"))
+	if err != nil {
+		return 0, 0, false
+	}
+	last, err := strconv.Atoi(strings.TrimPrefix(parts[1], "#"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return first, last, true
+}
+
+// noteRefFilter matches the notes named by IDs, ID ranges and aliases.
+func noteRefFilter(refs []string, database *sql.DB) (queryFilter, error) {
+	var conditions []string
+	var args []interface{}
+	for _, ref := range refs {
+		if first, last, ok := parseIDRange(strings.TrimSpace(ref)); ok {
+			if err := validateID(first); err != nil {
+				return queryFilter{}, err
+			}
+			if last < first {
+				return queryFilter{}, fmt.Errorf("invalid range %q, the last ID comes before the first", ref)
+			}
+			conditions = append(conditions, "id BETWEEN (?) AND (?)")
+			args = append(args, first, last)
+			continue
+		}
+		id, err := resolveNoteRef(ref, database)
+		if err != nil {
+			return queryFilter{}, err
+		}
+		conditions = append(conditions, "id = (?)")
+		args = append(args, id)
+	}
+	var filter queryFilter
+	filter.Add("("+strings.Join(conditions, " OR ")+")", args...)
+	return filter, nil
+}
+
+// resolveNoteRef accepts a note ID or alias, returning the note's ID.
+func resolveNoteRef(ref string, database *sql.DB) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if _, err := strconv.Atoi(strings.TrimPrefix(ref, "#")); err == nil {
+		return parseID(ref)
+	}
+	if match := lastNotePattern.FindStringSubmatch(strings.ToLower(ref)); match != nil {
+		return resolveLastNote(match[1], database)
+	}
+	var id int
+	err := database.QueryRow("SELECT note_id FROM metadata WHERE key = (?) AND value = (?)", aliasKey, strings.ToLower(ref)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no note with ID or alias %q", ref)
+	}
+	return id, err
+}
+
+// resolveLastNote returns the ID of the most recently created or modified
+// note, or of the note offset places before it.
+func resolveLastNote(offset string, database *sql.DB) (int, error) {
+	skip := 0
+	if offset != "" {
+		var err error
+		if skip, err = strconv.Atoi(offset); err != nil {
+			return 0, fmt.Errorf("invalid offset %q in last~%s", offset, offset)
+		}
+	}
+	var id int
+	err := database.QueryRow("SELECT id FROM notes ORDER BY COALESCE(modified, timestamp) DESC, id DESC LIMIT 1 OFFSET (?)", skip).Scan(&id)
+	if err == sql.ErrNoRows {
+		if skip == 0 {
+			return 0, errors.New("there are no notes yet")
+		}
+		return 0, fmt.Errorf("last~%d goes back further than there are notes", skip)
+	}
+	return id, err
+}
+
+// setAlias gives a note a memorable name, replacing any alias it had.
+func setAlias(id int, alias string, database *sql.DB) error {
+	alias = strings.ToLower(alias)
+	if err := validateAlias(alias); err != nil {
+		return err
+	}
+	if _, err := getNoteText(id, database); err != nil {
+		return err
+	}
+	var existing int
+	err := database.QueryRow("SELECT note_id FROM metadata WHERE key = (?) AND value = (?)", aliasKey, alias).Scan(&existing)
+	if err == nil && existing != id {
+		return fmt.Errorf("alias %q is already used by note %d", alias, existing)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return setNoteMeta(id, aliasKey, alias, database)
+}
+
+// runAlias dispatches the "alias set", "alias rm" and "alias list" subcommands.
+func runAlias(args []string, database *sql.DB) error {
+	usage := "usage: notectl alias <set <id> <alias>|rm <alias>|list>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "set":
+		if len(args) != 3 {
+			return errors.New(usage)
+		}
+		id, err := resolveNoteRef(args[1], database)
+		if err != nil {
+			return err
+		}
+		if err := setAlias(id, args[2], database); err != nil {
+			return err
+		}
+		fmt.Printf("Note %d is now also %s\n", id, strings.ToLower(args[2]))
+		return nil
+	case "rm":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		var id int
+		err := database.QueryRow("SELECT note_id FROM metadata WHERE key = (?) AND value = (?)", aliasKey, strings.ToLower(args[1])).Scan(&id)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no alias %q", args[1])
+		}
+		if err != nil {
+			return err
+		}
+		return deleteNoteMeta(id, aliasKey, database)
+	case "list":
+		rows, err := database.Query("SELECT value, note_id FROM metadata WHERE key = (?) ORDER BY value", aliasKey)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var alias string
+			var id int
+			if err := rows.Scan(&alias, &id); err != nil {
+				return err
+			}
+			fmt.Printf("%s - %d\n", alias, id)
+		}
+		return rows.Err()
+	default:
+		return errors.New(usage)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestValidateAliasRejectsSelectors(t *testing.T) {
+	for _, alias := range append([]string{"12", "10-20", "last", "last~2"}, dateSelectors...) {
+		if err := validateAlias(alias); err == nil {
+			t.Errorf("alias %q was accepted", alias)
+		}
+	}
+	for _, alias := range []string{"standup", "last-call", "today-plan"} {
+		if err := validateAlias(alias); err != nil {
+			t.Errorf("alias %q was refused: %s", alias, err)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// archiveFormat identifies the header line of a JSONL archive.
+const archiveFormat = "notectl-archive"
+
+// archiveVersion is the layout written by writeArchive. Version 2 added clocked
+// time; earlier archives are read as having none.
+const archiveVersion = 2
+
+// archiveHeader is the first line of an archive.
+type archiveHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// archiveNote is one line of an archive after the header: every stored field
+// of a note, with its metadata, attachments and clocked time. Fields derived from others,
+// such as the checksum, mentions and search index, are rebuilt on import.
+type archiveNote struct {
+	ID          int                 `json:"id"`
+	UUID        string              `json:"uuid"`
+	Created     time.Time           `json:"created"`
+	Modified    *time.Time          `json:"modified,omitempty"`
+	Title       string              `json:"title"`
+	Text        string              `json:"text"`
+	Tags        []string            `json:"tags"`
+	Status      string              `json:"status"`
+	Meta        map[string]string   `json:"meta"`
+	Attachments []archiveAttachment `json:"attachments"`
+	Clock       []archiveInterval   `json:"clock"`
+}
+
+// archiveAttachment is an attachment's manifest entry and data, which
+// encoding/json writes as base64.
+type archiveAttachment struct {
+	Name    string    `json:"name"`
+	Mime    string    `json:"mime"`
+	Created time.Time `json:"created"`
+	SHA256  string    `json:"sha256"`
+	Size    int       `json:"size"`
+	Data    []byte    `json:"data"`
+}
+
+// archiveInterval is time clocked on a note, without an end while clocked in.
+type archiveInterval struct {
+	Start time.Time  `json:"start"`
+	End   *time.Time `json:"end,omitempty"`
+}
+
+// tableExists reports whether database has the table, for tables only created
+// once they are first used.
+func tableExists(name string, database *sql.DB) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = (?)", name).Scan(&count)
+	return count > 0, err
+}
+
+// archivedClock returns the intervals clocked on a note, oldest first.
+func archivedClock(noteID int, database *sql.DB) ([]archiveInterval, error) {
+	rows, err := database.Query("SELECT start, end FROM clock WHERE note_id = (?) ORDER BY id", noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	intervals := []archiveInterval{}
+	for rows.Next() {
+		var start int64
+		var end sql.NullInt64
+		if err := rows.Scan(&start, &end); err != nil {
+			return nil, err
+		}
+		interval := archiveInterval{Start: time.Unix(start, 0).UTC()}
+		if end.Valid {
+			at := time.Unix(end.Int64, 0).UTC()
+			interval.End = &at
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals, rows.Err()
+}
+
+// writeArchive writes the notes matching query to w as a canonical archive:
+// notes in ID order, attachments in the order they were added, times in UTC
+// and map keys sorted, so the same database always gives the same bytes. It
+// returns the number of notes written. Exporting does not change the
+// database, so notes from before UUIDs were stored are exported without one.
+func writeArchive(w io.Writer, query noteQuery, database *sql.DB) (int, error) {
+	hasAttachments, err := tableExists("attachments", database)
+	if err != nil {
+		return 0, err
+	}
+	hasClock, err := tableExists("clock", database)
+	if err != nil {
+		return 0, err
+	}
+	var archived []archiveNote
+	rows, err := database.Query("SELECT id, COALESCE(uuid, ''), timestamp, modified, COALESCE(title, ''), "+notes.TextColumn+", tags, status FROM notes"+query.Filter.Where()+" ORDER BY id", query.Filter.Args...)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var n archiveNote
+		var created int64
+		var modified sql.NullInt64
+		var text noteText
+		var tags sql.NullString
+		if err := rows.Scan(&n.ID, &n.UUID, &created, &modified, &n.Title, &text, &tags, &n.Status); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !query.MatchesText(string(text)) {
+			continue
+		}
+		n.Created = time.Unix(created, 0).UTC()
+		if modified.Valid {
+			at := time.Unix(modified.Int64, 0).UTC()
+			n.Modified = &at
+		}
+		n.Text = string(text)
+		n.Tags = parseTags(tags.String)
+		archived = append(archived, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	out := bufio.NewWriter(w)
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(archiveHeader{archiveFormat, archiveVersion}); err != nil {
+		return 0, err
+	}
+	for _, n := range archived {
+		if n.Meta, err = getNoteMeta(n.ID, database); err != nil {
+			return 0, err
+		}
+		var attachments []attachment
+		if hasAttachments {
+			if attachments, err = listAttachments(n.ID, database); err != nil {
+				return 0, err
+			}
+		}
+		n.Attachments = []archiveAttachment{}
+		for _, a := range attachments {
+			_, data, err := readAttachment(a.ID, database)
+			if err != nil {
+				return 0, err
+			}
+			n.Attachments = append(n.Attachments, archiveAttachment{a.Name, a.Mime, a.Created.UTC(), blobHash(data), len(data), data})
+		}
+		n.Clock = []archiveInterval{}
+		if hasClock {
+			if n.Clock, err = archivedClock(n.ID, database); err != nil {
+				return 0, err
+			}
+		}
+		if err := encoder.Encode(n); err != nil {
+			return 0, err
+		}
+	}
+	return len(archived), out.Flush()
+}
+
+// restoring is set while an archive is imported.
+var restoring bool
+
+// readArchive restores the notes of an archive into database, which must hold
+// no notes since they keep their IDs. The archive is restored in one
+// transaction, so a failure leaves the database empty. It returns the number
+// of notes restored.
+func readArchive(r io.Reader, database *sql.DB) (int, error) {
+	restoring = true
+	defer func() { restoring = false }()
+	// The tables created on first use are created up front, as schema changes
+	// would wait on the transaction.
+	if err := createAttachmentTableIfNotExist(database); err != nil {
+		return 0, err
+	}
+	if err := createClockTableIfNotExist(database); err != nil {
+		return 0, err
+	}
+	tx, err := database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	restored, err := restoreArchive(r, tx)
+	if err != nil {
+		rollbackJournaled(tx)
+		return 0, err
+	}
+	return restored, commitJournaled(tx)
+}
+
+// restoreArchive is readArchive within a transaction.
+func restoreArchive(r io.Reader, tx *sql.Tx) (int, error) {
+	var existing int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM notes").Scan(&existing); err != nil {
+		return 0, err
+	}
+	if existing > 0 {
+		return 0, fmt.Errorf("the database already holds %d notes, archives keep note IDs and are only imported into an empty one", existing)
+	}
+	scanner := bufio.NewScanner(r)
+	// Lines hold whole notes and their attachments.
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errors.New("empty archive")
+	}
+	var header archiveHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Format != archiveFormat {
+		return 0, errors.New("not a notectl archive")
+	}
+	if header.Version < 1 || header.Version > archiveVersion {
+		return 0, fmt.Errorf("archive version %d is not supported, expected %d or earlier", header.Version, archiveVersion)
+	}
+	line, restored := 1, 0
+	for scanner.Scan() {
+		line++
+		var archived archiveNote
+		if err := json.Unmarshal(scanner.Bytes(), &archived); err != nil {
+			return restored, fmt.Errorf("line %d: %s", line, err)
+		}
+		if err := restoreNote(archived, tx); err != nil {
+			return restored, fmt.Errorf("line %d: note %d: %s", line, archived.ID, err)
+		}
+		restored++
+	}
+	return restored, scanner.Err()
+}
+
+// restoreNote saves an archived note as it was exported.
+func restoreNote(archived archiveNote, database execer) error {
+	n := note{
+		ID:     archived.ID,
+		Time:   archived.Created.Local(),
+		Text:   archived.Text,
+		Tags:   archived.Tags,
+		Status: archived.Status,
+		Meta:   archived.Meta,
+		UUID:   archived.UUID,
+		Title:  archived.Title,
+	}
+	if err := n.insert(database); err != nil {
+		return err
+	}
+	// Saving fills in what a new note lacks; an archived note lacked it too.
+	if archived.UUID == "" {
+		if err := notes.UpdateUUID(database, n.ID, ""); err != nil {
+			return err
+		}
+	}
+	if archived.Title == "" {
+		if err := notes.UpdateTitle(database, n.ID, ""); err != nil {
+			return err
+		}
+	}
+	if _, ok := archived.Meta[langKey]; !ok {
+		if err := deleteNoteMeta(n.ID, langKey, database); err != nil {
+			return err
+		}
+	}
+	if archived.Modified != nil {
+		if err := notes.UpdateModified(database, n.ID, *archived.Modified); err != nil {
+			return err
+		}
+	}
+	for _, a := range archived.Attachments {
+		if hash := blobHash(a.Data); hash != a.SHA256 {
+			return fmt.Errorf("attachment %s does not match its hash", a.Name)
+		}
+		name, err := attachmentFileName(a.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := insertAttachment(n.ID, name, a.Mime, a.Created, a.Data, database); err != nil {
+			return err
+		}
+	}
+	for _, interval := range archived.Clock {
+		var end interface{}
+		if interval.End != nil {
+			end = interval.End.Unix()
+		}
+		if _, err := database.Exec("INSERT INTO clock (note_id, start, end) VALUES (?, ?, ?)", n.ID, interval.Start.Unix(), end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportArchive writes the notes matching query as an archive to path, or to
+// standard output when path is "-". With verify set the archive is first imported into a
+// scratch database and exported again, and nothing is written unless both
+// exports are identical.
+func exportArchive(query noteQuery, path string, verify bool, database *sql.DB) error {
+	var archive bytes.Buffer
+	count, err := writeArchive(&archive, query, database)
+	if err != nil {
+		return err
+	}
+	if verify {
+		if err := verifyArchive(archive.Bytes()); err != nil {
+			return err
+		}
+	}
+	if path == "-" {
+		_, err := os.Stdout.Write(archive.Bytes())
+		return err
+	}
+	if err := writeFileAtomic(path, archive.Bytes()); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d notes to %s\n", count, path)
+	return nil
+}
+
+// importArchive restores the notes of the archive at path.
+func importArchive(path string, database *sql.DB) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	count, err := readArchive(file, database)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	fmt.Printf("Imported %d notes from %s\n", count, path)
+	return nil
+}
+
+// verifyArchive imports archive into a scratch database, exports that again
+// and reports the first line where the two differ.
+func verifyArchive(archive []byte) error {
+	dir, err := ioutil.TempDir("", "notectl-verify")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	scratch, err := connectToDatabase(filepath.Join(dir, "notes.db"))
+	if err != nil {
+		return err
+	}
+	defer scratch.Close()
+	if err := createTableIfNotExist(scratch); err != nil {
+		return err
+	}
+	// The scratch copy is not a change to journal, and its attachments stay in
+	// the scratch database rather than the configured blob store.
+	replaying = true
+	defer func() { replaying = false }()
+	store, hadStore := config["attachments.store"]
+	config["attachments.store"] = "sqlite"
+	defer func() {
+		if hadStore {
+			config["attachments.store"] = store
+		} else {
+			delete(config, "attachments.store")
+		}
+	}()
+
+	if _, err := readArchive(bytes.NewReader(archive), scratch); err != nil {
+		return fmt.Errorf("verify: %s", err)
+	}
+	var again bytes.Buffer
+	if _, err := writeArchive(&again, noteQuery{}, scratch); err != nil {
+		return fmt.Errorf("verify: %s", err)
+	}
+	if bytes.Equal(archive, again.Bytes()) {
+		return nil
+	}
+	want, got := bytes.Split(archive, []byte("\n")), bytes.Split(again.Bytes(), []byte("\n"))
+	for i := 0; i < len(want) && i < len(got); i++ {
+		if !bytes.Equal(want[i], got[i]) {
+			return fmt.Errorf("verify: line %d changes when the archive is imported and exported again", i+1)
+		}
+	}
+	return fmt.Errorf("verify: the archive has %d lines, but %d after it is imported and exported again", len(want), len(got))
+}
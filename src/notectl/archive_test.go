@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+func TestArchiveRoundTripIsByteIdentical(t *testing.T) {
+	database := openTestDatabase(t)
+	at := time.Date(2026, time.February, 3, 8, 30, 0, 0, time.UTC)
+	first := saveTestNote(t, database, at, "Standup\n\n- shipped the importer\n- talk to @ana", "work", "meeting")
+	saveTestNote(t, database, at.Add(time.Hour), "ünïcödé <b>&</b> \"quotes\"\ttabs")
+	long := saveTestNote(t, database, at.Add(2*time.Hour), strings.Repeat("a long note that gets compressed ", 400), "archive")
+	if err := setNoteMeta(first.ID, "alias", "standup", database); err != nil {
+		t.Fatal(err)
+	}
+	if err := setNoteMeta(first.ID, "mood", "good", database); err != nil {
+		t.Fatal(err)
+	}
+	if err := setNoteMeta(first.ID, notes.MentionKeyPrefix+"cy", "cy", database); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateNoteText(long.ID, strings.Repeat("an edited long note ", 400), database); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec("UPDATE notes SET title = NULL WHERE id = (?)", long.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addAttachment(first.ID, "board.png", "image/png", []byte("\x89PNG\r\n\x1a\nnot really"), database); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addAttachment(first.ID, "notes.txt", "text/plain", []byte("plain"), database); err != nil {
+		t.Fatal(err)
+	}
+	if err := createClockTableIfNotExist(database); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec("INSERT INTO clock (note_id, start, end) VALUES (?, ?, ?), (?, ?, NULL)", first.ID, at.Unix(), at.Add(25*time.Minute).Unix(), long.ID, at.Add(3*time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	// A note from before UUIDs were stored is exported without one.
+	if _, err := database.Exec("UPDATE notes SET uuid = NULL WHERE id = (?)", long.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var exported bytes.Buffer
+	count, err := writeArchive(&exported, noteQuery{}, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("exported %d notes, want 3", count)
+	}
+	var unassigned int
+	if err := database.QueryRow("SELECT COUNT(*) FROM notes WHERE uuid IS NULL").Scan(&unassigned); err != nil {
+		t.Fatal(err)
+	}
+	if unassigned != 1 {
+		t.Error("exporting assigned a UUID to the note without one")
+	}
+
+	restored := openTestDatabase(t)
+	if _, err := readArchive(bytes.NewReader(exported.Bytes()), restored); err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if _, err := writeArchive(&again, noteQuery{}, restored); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exported.Bytes(), again.Bytes()) {
+		t.Errorf("export, import, export changed the archive:\n%s\nbecame\n%s", exported.Bytes(), again.Bytes())
+	}
+	if err := verifyArchive(exported.Bytes()); err != nil {
+		t.Error(err)
+	}
+	for _, table := range noteRowTables(t, database) {
+		if table == "notes" {
+			continue
+		}
+		var before, after int
+		if err := database.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&before); err != nil {
+			t.Fatal(err)
+		}
+		if err := restored.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&after); err != nil {
+			t.Fatal(err)
+		}
+		if before == 0 {
+			t.Errorf("the test leaves %s empty", table)
+		}
+		if after != before {
+			t.Errorf("%s has %d rows after the round trip, want %d", table, after, before)
+		}
+	}
+}
+
+// noteRowTables returns the tables of database with a note_id column, those
+// holding rows that belong to a note.
+func noteRowTables(t *testing.T, database *sql.DB) []string {
+	t.Helper()
+	rows, err := database.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND sql LIKE '%note_id%'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+func TestReadArchiveRestoresNothingOnFailure(t *testing.T) {
+	archive := `{"format":"notectl-archive","version":2}
+{"id":1,"uuid":"5f0c6f9e-0000-4000-8000-000000000001","created":"2026-02-03T08:30:00Z","title":"t","text":"kept @ana","tags":["generic"],"status":"","meta":{"alias":"kept"},"attachments":[],"clock":[{"start":"2026-02-03T08:30:00Z"}]}
+{"id":2,"uuid":"5f0c6f9e-0000-4000-8000-000000000002","created":"2026-02-03T08:31:00Z","title":"t","text":"t","tags":["generic"],"status":"","meta":{},"attachments":[{"name":"a.txt","mime":"text/plain","created":"2026-02-03T08:30:00Z","sha256":"wrong","size":1,"data":"eA=="}],"clock":[]}
+`
+	database := openTestDatabase(t)
+	if _, err := readArchive(strings.NewReader(archive), database); err == nil {
+		t.Fatal("an archive with a corrupt attachment was imported")
+	}
+	for _, table := range append(noteRowTables(t, database), "notes") {
+		var count int
+		if err := database.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("%s holds %d rows after a failed import", table, count)
+		}
+	}
+}
+
+func TestReadArchiveCleansAttachmentNames(t *testing.T) {
+	archive := `{"format":"notectl-archive","version":1}
+{"id":1,"uuid":"5f0c6f9e-0000-4000-8000-000000000001","created":"2026-02-03T08:30:00Z","title":"t","text":"t","tags":["generic"],"status":"","meta":{},"attachments":[{"name":"../../.profile","mime":"text/plain","created":"2026-02-03T08:30:00Z","sha256":"` + blobHash([]byte("x")) + `","size":1,"data":"eA=="}]}
+`
+	database := openTestDatabase(t)
+	if _, err := readArchive(strings.NewReader(archive), database); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err := database.QueryRow("SELECT name FROM attachments").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != ".profile" {
+		t.Errorf("restored attachment named %q, want .profile", name)
+	}
+}
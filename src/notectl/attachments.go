@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// attachmentColumnMigrations are attachment columns added after the table was
+// first released. Attachments kept in a blobStore have no data, and are found
+// there by their hash.
+var attachmentColumnMigrations = []struct {
+	Column     string
+	Definition string
+}{
+	{"sha256", "TEXT"},
+	{"size", "INTEGER"},
+	{"store", "TEXT NOT NULL DEFAULT ''"},
+}
+
+func createAttachmentTableIfNotExist(database *sql.DB) error {
+	if _, err := database.Exec("CREATE TABLE IF NOT EXISTS attachments (id INTEGER PRIMARY KEY, note_id INTEGER, name TEXT, mime TEXT, created INTEGER, data BLOB)"); err != nil {
+		return err
+	}
+	for _, migration := range attachmentColumnMigrations {
+		if err := notes.AddColumnIfNotExist(database, "attachments", migration.Column, migration.Definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type attachment struct {
+	ID      int
+	NoteID  int
+	Name    string
+	Mime    string
+	Created time.Time
+	Size    int
+}
+
+// attachmentFileName reduces an attachment name to a bare file name, so a
+// name from an imported note cannot point outside the directory it is saved
+// in.
+func attachmentFileName(name string) (string, error) {
+	base := path.Base(strings.Replace(name, "\\", "/", -1))
+	if base == "." || base == ".." || base == "/" {
+		return "", fmt.Errorf("invalid attachment name %q", name)
+	}
+	return base, nil
+}
+
+func addAttachment(noteID int, name string, mime string, data []byte, database *sql.DB) (int, error) {
+	if err := createAttachmentTableIfNotExist(database); err != nil {
+		return 0, err
+	}
+	return insertAttachment(noteID, name, mime, time.Now(), data, database)
+}
+
+// insertAttachment is addAttachment for a transaction, with the time it was
+// added given. The attachments table must exist.
+func insertAttachment(noteID int, name string, mime string, created time.Time, data []byte, database execer) (int, error) {
+	data = scrubAttachment(mime, data)
+	hash := blobHash(data)
+	store, err := configuredBlobStore()
+	if err != nil {
+		return 0, err
+	}
+	stored, storeName := data, ""
+	if store != nil {
+		if err := store.Put(hash, data); err != nil {
+			return 0, err
+		}
+		stored, storeName = nil, store.Name()
+	}
+	result, err := database.Exec("INSERT INTO attachments (note_id, name, mime, created, data, sha256, size, store) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", noteID, name, mime, created.Unix(), stored, hash, len(data), storeName)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func listAttachments(noteID int, database *sql.DB) ([]attachment, error) {
+	if err := createAttachmentTableIfNotExist(database); err != nil {
+		return nil, err
+	}
+	rows, err := database.Query("SELECT id, note_id, name, mime, created, COALESCE(size, length(data)) FROM attachments WHERE note_id = (?) ORDER BY id", noteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []attachment
+	for rows.Next() {
+		var a attachment
+		var created int64
+		if err := rows.Scan(&a.ID, &a.NoteID, &a.Name, &a.Mime, &created, &a.Size); err != nil {
+			return nil, err
+		}
+		a.Created = time.Unix(created, 0)
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func readAttachment(id int, database *sql.DB) (attachment, []byte, error) {
+	var a attachment
+	var created int64
+	var data []byte
+	var hash sql.NullString
+	var storeName string
+	if err := createAttachmentTableIfNotExist(database); err != nil {
+		return a, nil, err
+	}
+	err := database.QueryRow("SELECT id, note_id, name, mime, created, data, sha256, store FROM attachments WHERE id = (?)", id).Scan(&a.ID, &a.NoteID, &a.Name, &a.Mime, &created, &data, &hash, &storeName)
+	if err == sql.ErrNoRows {
+		return a, nil, fmt.Errorf("no attachment with ID %d", id)
+	}
+	if err != nil {
+		return a, nil, err
+	}
+	a.Created = time.Unix(created, 0)
+	if storeName != "" {
+		store, err := blobStoreNamed(storeName)
+		if err != nil {
+			return a, nil, err
+		}
+		if data, err = store.Get(hash.String); err != nil {
+			return a, nil, fmt.Errorf("attachment %d: %s", id, err)
+		}
+		if blobHash(data) != hash.String {
+			return a, nil, fmt.Errorf("attachment %d is corrupt, its data in the %s store does not match its hash", id, storeName)
+		}
+	}
+	a.Size = len(data)
+	return a, data, nil
+}
+
+// migrateAttachments moves every attachment not in the configured store into
+// it, so changing attachments.store applies to existing attachments too.
+func migrateAttachments(database *sql.DB) error {
+	if err := createAttachmentTableIfNotExist(database); err != nil {
+		return err
+	}
+	store, err := configuredBlobStore()
+	if err != nil {
+		return err
+	}
+	storeName := ""
+	if store != nil {
+		storeName = store.Name()
+	}
+	rows, err := database.Query("SELECT id FROM attachments WHERE store != (?) ORDER BY id", storeName)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	for i, id := range ids {
+		// Each attachment is switched over once its data is in the new
+		// store, so a rerun picks up where an interrupted one stopped.
+		if interrupted(interrupt) {
+			return fmt.Errorf("interrupted after moving %d of %d attachments, run the migration again to continue", i, len(ids))
+		}
+		_, data, err := readAttachment(id, database)
+		if err != nil {
+			return err
+		}
+		hash, stored := blobHash(data), data
+		if store != nil {
+			if err := store.Put(hash, data); err != nil {
+				return err
+			}
+			stored = nil
+		}
+		if _, err := database.Exec("UPDATE attachments SET data = (?), sha256 = (?), size = (?), store = (?) WHERE id = (?)", stored, hash, len(data), storeName, id); err != nil {
+			return err
+		}
+	}
+	if store == nil {
+		fmt.Printf("Moved %d attachments into the database\n", len(ids))
+	} else {
+		fmt.Printf("Moved %d attachments to the %s store\n", len(ids), storeName)
+	}
+	return nil
+}
+
+// runAttachments dispatches the "attachments list", "attachments save",
+// "attachments show" and "attachments migrate" subcommands.
+func runAttachments(args []string, database *sql.DB) error {
+	usage := "usage: notectl attachments <list -i <note id>|save <attachment id> [-o file]|show <attachment id> [-viewer]|migrate>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "list":
+		listCommand := newFlagSet("attachments list")
+		idPtr := listCommand.Int("i", -1, "ID of the note to list attachments of.")
+		if err := parseFlags(listCommand, args[1:]); err != nil {
+			return err
+		}
+		if *idPtr == -1 {
+			return errors.New(usage)
+		}
+		list, err := listAttachments(*idPtr, database)
+		if err != nil {
+			return err
+		}
+		for _, a := range list {
+			fmt.Printf("%d - %s: %s (%s, %d bytes)\n", a.ID, formatDateTime(a.Created), a.Name, a.Mime, a.Size)
+		}
+		return nil
+	case "save":
+		saveCommand := newFlagSet("attachments save")
+		outputPtr := saveCommand.String("o", "", "File to write to, defaults to the attachment's name.")
+		ids, err := parseInterspersed(saveCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(ids) != 1 {
+			return errors.New(usage)
+		}
+		id, err := parseID(ids[0])
+		if err != nil {
+			return err
+		}
+		a, data, err := readAttachment(id, database)
+		if err != nil {
+			return err
+		}
+		output := *outputPtr
+		if output == "" {
+			if output, err = attachmentFileName(a.Name); err != nil {
+				return err
+			}
+		}
+		if output == "-" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := ioutil.WriteFile(output, data, 0600); err != nil {
+			return err
+		}
+		fmt.Printf("Saved %s (%d bytes)\n", output, len(data))
+		return nil
+	case "show":
+		showCommand := newFlagSet("attachments show")
+		viewerPtr := showCommand.Bool("viewer", false, "Open the attachment in the default viewer instead of the terminal.")
+		ids, err := parseInterspersed(showCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(ids) != 1 {
+			return errors.New(usage)
+		}
+		id, err := parseID(ids[0])
+		if err != nil {
+			return err
+		}
+		a, data, err := readAttachment(id, database)
+		if err != nil {
+			return err
+		}
+		return previewAttachment(a, data, *viewerPtr)
+	case "migrate":
+		return migrateAttachments(database)
+	}
+	return errors.New(usage)
+}
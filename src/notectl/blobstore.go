@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// blobStore keeps attachment data outside the database, addressed by the
+// SHA-256 hash of the data. Attachments kept in the database itself have no
+// store.
+type blobStore interface {
+	// Name is recorded with each attachment to find its data again.
+	Name() string
+	Put(hash string, data []byte) error
+	Get(hash string) ([]byte, error)
+}
+
+// configuredBlobStore returns the store new attachments are written to, from
+// the attachments.store setting: "sqlite", the default, keeps them in the
+// database and returns nil.
+func configuredBlobStore() (blobStore, error) {
+	name := configValue("attachments.store", "sqlite")
+	if name == "sqlite" {
+		return nil, nil
+	}
+	return blobStoreNamed(name)
+}
+
+// blobStoreNamed returns the store an attachment was written to.
+func blobStoreNamed(name string) (blobStore, error) {
+	switch name {
+	case "disk":
+		return diskStore{Dir: configValue("attachments.dir", filepath.Join(os.Getenv("HOME"), ".notectl", "blobs"))}, nil
+	case "s3":
+		return newS3Store()
+	}
+	return nil, fmt.Errorf("unknown attachments.store %q, expected sqlite, disk or s3", name)
+}
+
+// blobHash returns the hex SHA-256 hash attachments are addressed by.
+func blobHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// diskStore keeps attachments as files under a data directory, spread over
+// subdirectories named after the first two characters of their hash.
+type diskStore struct {
+	Dir string
+}
+
+func (s diskStore) Name() string { return "disk" }
+
+func (s diskStore) path(hash string) string {
+	return filepath.Join(s.Dir, hash[:2], hash)
+}
+
+func (s diskStore) Put(hash string, data []byte) error {
+	path := s.path(hash)
+	// Identical attachments share one file.
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (s diskStore) Get(hash string) ([]byte, error) {
+	return ioutil.ReadFile(s.path(hash))
+}
+
+// s3Store keeps attachments as objects in an S3 bucket, or any service
+// speaking the S3 API, signing requests with AWS Signature Version 4.
+type s3Store struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// newS3Store reads the attachments.s3.* settings. The keys fall back to the
+// standard AWS environment variables.
+func newS3Store() (s3Store, error) {
+	s := s3Store{
+		Region:    configValue("attachments.s3.region", "us-east-1"),
+		Bucket:    configValue("attachments.s3.bucket", ""),
+		Prefix:    configValue("attachments.s3.prefix", "notectl/"),
+		AccessKey: configValue("attachments.s3.access_key", os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey: secretValue("attachments.s3.secret_key"),
+		Client:    &http.Client{Timeout: 60 * time.Second},
+	}
+	if s.SecretKey == "" {
+		s.SecretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	s.Endpoint = configValue("attachments.s3.endpoint", "https://s3."+s.Region+".amazonaws.com")
+	if s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return s, fmt.Errorf("the s3 attachment store needs attachments.s3.bucket, attachments.s3.access_key and attachments.s3.secret_key")
+	}
+	return s, nil
+}
+
+func (s s3Store) Name() string { return "s3" }
+
+func (s s3Store) Put(hash string, data []byte) error {
+	_, err := s.do("PUT", hash, data)
+	return err
+}
+
+func (s s3Store) Get(hash string) ([]byte, error) {
+	return s.do("GET", hash, nil)
+}
+
+// do sends a signed request for the object holding hash and returns the
+// response body.
+func (s s3Store) do(method string, hash string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(s.Endpoint, "/")+"/"+s.Bucket+"/"+s.Prefix+hash, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body, time.Now().UTC())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 %s %s: %s", method, hash, resp.Status)
+	}
+	return data, nil
+}
+
+// sign adds AWS Signature Version 4 headers to a request.
+func (s s3Store) sign(req *http.Request, body []byte, now time.Time) {
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payload := blobHash(body)
+	req.Header.Set("x-amz-date", stamp)
+	req.Header.Set("x-amz-content-sha256", payload)
+	const signed = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payload,
+		"x-amz-date:" + stamp,
+		"",
+		signed,
+		payload,
+	}, "\n")
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + blobHash([]byte(canonical))
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{day, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKey, scope, signed, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// boardColumnWidth is the width of a single board column, including padding.
+const boardColumnWidth = 30
+
+// boardStatuses are the statuses shown as board columns, in order.
+var boardStatuses = []string{"todo", "doing", "done"}
+
+func setNoteStatus(id int, status string, database execer) error {
+	if err := notes.UpdateStatus(database, id, status); err != nil {
+		return err
+	}
+	return journal(database, journalEntry{Op: journalStatus, ID: id, Status: stringPtr(status)})
+}
+
+// firstLine returns the first non-empty line of a note, cut to width runes.
+func firstLine(text string, width int) string {
+	line := ""
+	for _, l := range strings.Split(text, "\n") {
+		if strings.TrimSpace(l) != "" {
+			line = strings.TrimSpace(l)
+			break
+		}
+	}
+	r := []rune(line)
+	if len(r) > width {
+		return string(r[:width-1]) + ellipsis()
+	}
+	return line
+}
+
+func padRight(s string, width int) string {
+	n := len([]rune(s))
+	if n >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// showBoard renders notes with a status as one column per status.
+func showBoard(tag string, database *sql.DB) error {
+	var filter queryFilter
+	filter.Add("status IN ('todo', 'doing', 'done')")
+	if tag != "" {
+		filter.Add(tagMatchClause, tag)
+	}
+	rows, err := database.Query("SELECT id, "+notes.TextColumn+", status FROM notes"+filter.Where()+" ORDER BY timestamp", filter.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	columns := make(map[string][]string)
+	var id int
+	var notetext noteText
+	var status string
+	for rows.Next() {
+		if err := rows.Scan(&id, &notetext, &status); err != nil {
+			return err
+		}
+		// The ID goes in front of the first line, which need not be the
+		// first line of the text.
+		card := fmt.Sprintf("#%d %s", id, firstLine(string(notetext), boardColumnWidth))
+		columns[status] = append(columns[status], firstLine(card, boardColumnWidth-2))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if plainOutput {
+		for _, status := range boardStatuses {
+			fmt.Printf("%s, %d notes\n", strings.ToUpper(status), len(columns[status]))
+			for _, card := range columns[status] {
+				fmt.Println(card)
+			}
+		}
+		return nil
+	}
+
+	height := 0
+	var header, rule []string
+	for _, status := range boardStatuses {
+		if len(columns[status]) > height {
+			height = len(columns[status])
+		}
+		title := fmt.Sprintf("%s (%d)", strings.ToUpper(status), len(columns[status]))
+		header = append(header, padRight(title, boardColumnWidth))
+		rule = append(rule, strings.Repeat("-", boardColumnWidth-2)+"  ")
+	}
+	fmt.Println(strings.TrimRight(strings.Join(header, ""), " "))
+	fmt.Println(strings.TrimRight(strings.Join(rule, ""), " "))
+	for i := 0; i < height; i++ {
+		var line []string
+		for _, status := range boardStatuses {
+			cell := ""
+			if i < len(columns[status]) {
+				cell = columns[status][i]
+			}
+			line = append(line, padRight(cell, boardColumnWidth))
+		}
+		fmt.Println(strings.TrimRight(strings.Join(line, ""), " "))
+	}
+	return nil
+}
+
+// runStatus sets or clears the status of a note.
+func runStatus(args []string) error {
+	statusCommand := newFlagSet("status")
+	statusIDPtr := statusCommand.Int("i", -1, "ID of the note to set the status of.")
+	if err := parseFlags(statusCommand, args); err != nil {
+		return err
+	}
+	if *statusIDPtr == -1 || statusCommand.NArg() != 1 {
+		return errors.New("usage: notectl status -i <id> <todo|doing|done|none>")
+	}
+	if err := validateID(*statusIDPtr); err != nil {
+		return err
+	}
+	status := statusCommand.Arg(0)
+	if status == "none" {
+		status = ""
+	} else if !validStatus(status) {
+		return fmt.Errorf("unknown status %q, expected one of %s or none", status, strings.Join(noteStatuses, ", "))
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return setNoteStatus(*statusIDPtr, status, database)
+}
+
+// runBoard prints the notes with a status as a kanban board.
+func runBoard(args []string) error {
+	boardCommand := newFlagSet("board")
+	boardTagPtr := boardCommand.String("tag", "", "Only show notes with this tag.")
+	if err := parseFlags(boardCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return showBoard(*boardTagPtr, database)
+}
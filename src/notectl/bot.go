@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramAPI is the base URL of the Telegram Bot API.
+const telegramAPI = "https://api.telegram.org"
+
+// telegramPollTimeout is how long each getUpdates long poll waits for messages.
+const telegramPollTimeout = 50
+
+var hashtagPattern = regexp.MustCompile(`(?:^|\s)#([\pL\pN_-]+)`)
+
+// parseHashtags returns the #hashtags in text as tags.
+func parseHashtags(text string) tagList {
+	var tags tagList
+	for _, match := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tags = append(tags, strings.ToLower(match[1]))
+	}
+	return tags
+}
+
+type telegramBot struct {
+	Token   string
+	Allowed map[int64]bool
+	Client  *http.Client
+}
+
+type telegramMessage struct {
+	MessageID int `json:"message_id"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Date    int64  `json:"date"`
+	Text    string `json:"text"`
+	Caption string `json:"caption"`
+	Photo   []struct {
+		FileID   string `json:"file_id"`
+		FileSize int    `json:"file_size"`
+	} `json:"photo"`
+}
+
+type telegramUpdate struct {
+	UpdateID int              `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+// call invokes a Bot API method and decodes its result into result.
+func (bot telegramBot) call(method string, params url.Values, result interface{}) error {
+	resp, err := bot.Client.PostForm(fmt.Sprintf("%s/bot%s/%s", telegramAPI, bot.Token, method), params)
+	if err != nil {
+		// The error's URL contains the token, so report only the method.
+		return fmt.Errorf("telegram %s: request failed", method)
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("telegram %s: %s", method, err)
+	}
+	if !reply.OK {
+		return fmt.Errorf("telegram %s: %s", method, reply.Description)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(reply.Result, result)
+}
+
+func (bot telegramBot) reply(chat int64, text string) error {
+	return bot.call("sendMessage", url.Values{"chat_id": {strconv.FormatInt(chat, 10)}, "text": {text}}, nil)
+}
+
+// downloadFile fetches a file sent to the bot, returning its name and data.
+func (bot telegramBot) downloadFile(fileID string) (string, []byte, error) {
+	var file struct {
+		FilePath string `json:"file_path"`
+	}
+	if err := bot.call("getFile", url.Values{"file_id": {fileID}}, &file); err != nil {
+		return "", nil, err
+	}
+	resp, err := bot.Client.Get(fmt.Sprintf("%s/file/bot%s/%s", telegramAPI, bot.Token, file.FilePath))
+	if err != nil {
+		return "", nil, errors.New("telegram: downloading file failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("telegram: downloading file failed: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	return path.Base(file.FilePath), data, err
+}
+
+// saveMessage turns a message into a note, attaching the largest size of any
+// photo it carries.
+func (bot telegramBot) saveMessage(message *telegramMessage, database *sql.DB) (int, error) {
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	if text == "" && len(message.Photo) == 0 {
+		return 0, errors.New("only text and photo messages can be saved")
+	}
+	tags := addTags(tagList{"generic"}, parseHashtags(text))
+	n := note{
+		Time: time.Unix(message.Date, 0),
+		Text: text,
+		Tags: tags,
+		Meta: metaList{"source": "telegram"},
+	}
+	if err := n.Save(database); err != nil {
+		return 0, err
+	}
+	if len(message.Photo) > 0 {
+		name, data, err := bot.downloadFile(message.Photo[len(message.Photo)-1].FileID)
+		if err != nil {
+			return n.ID, err
+		}
+		if _, err := addAttachment(n.ID, name, http.DetectContentType(data), data, database); err != nil {
+			return n.ID, err
+		}
+	}
+	return n.ID, nil
+}
+
+// run long polls for updates until interrupted, saving messages from allowed
+// chats as notes.
+func (bot telegramBot) run(database *sql.DB) error {
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := bot.call("getMe", nil, &me); err != nil {
+		return err
+	}
+	fmt.Printf("Listening as @%s, Ctrl-C to stop\n", me.Username)
+
+	offset := 0
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		default:
+		}
+		var updates []telegramUpdate
+		params := url.Values{
+			"offset":          {strconv.Itoa(offset)},
+			"timeout":         {strconv.Itoa(telegramPollTimeout)},
+			"allowed_updates": {`["message"]`},
+		}
+		if err := bot.call("getUpdates", params, &updates); err != nil {
+			fmt.Println(err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			message := update.Message
+			if message == nil {
+				continue
+			}
+			if !bot.Allowed[message.Chat.ID] {
+				fmt.Printf("Ignoring message from chat %d, which is not allowed\n", message.Chat.ID)
+				bot.reply(message.Chat.ID, fmt.Sprintf("This chat (%d) is not allowed to save notes. Run the bot with -chat %d to allow it.", message.Chat.ID, message.Chat.ID))
+				continue
+			}
+			id, err := bot.saveMessage(message, database)
+			if err != nil {
+				fmt.Println(err)
+				bot.reply(message.Chat.ID, "Could not save note: "+err.Error())
+				continue
+			}
+			fmt.Printf("Saved note %d from chat %d\n", id, message.Chat.ID)
+			bot.reply(message.Chat.ID, fmt.Sprintf("Saved note %d", id))
+		}
+	}
+}
+
+// runBot dispatches the "bot telegram" subcommand.
+func runBot(args []string, database *sql.DB) error {
+	usage := "usage: notectl bot telegram [-token token] [-chat id,...]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "telegram":
+		telegramCommand := newFlagSet("bot telegram")
+		tokenPtr := telegramCommand.String("token", secretValue("bot.telegram.token"), "Bot token from @BotFather.")
+		chatPtr := telegramCommand.String("chat", configValue("bot.telegram.chats", ""), "Comma separated chat IDs allowed to save notes.")
+		if err := parseFlags(telegramCommand, args[1:]); err != nil {
+			return err
+		}
+		if *tokenPtr == "" {
+			return errors.New("a bot token is required, pass -token or set bot.telegram.token")
+		}
+		bot := telegramBot{
+			Token:   *tokenPtr,
+			Allowed: make(map[int64]bool),
+			Client:  &http.Client{Timeout: (telegramPollTimeout + 10) * time.Second},
+		}
+		for _, chat := range strings.Split(*chatPtr, ",") {
+			chat = strings.TrimSpace(chat)
+			if chat == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(chat, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid chat ID %q", chat)
+			}
+			bot.Allowed[id] = true
+		}
+		if len(bot.Allowed) == 0 {
+			fmt.Println("No chats are allowed yet; messages will be answered with their chat ID so you can allow them with -chat.")
+		}
+		return bot.run(database)
+	default:
+		return errors.New(usage)
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// bulkHeaderPattern matches the header line that starts each note in a
+// bulk-edit buffer. Headers written before metadata was shown have no meta
+// field, which leaves the note's metadata unchanged.
+var bulkHeaderPattern = regexp.MustCompile(`^=== note (\d+) \| tags: (.*?) \| status: (.*?)(?: \| meta: (\{.*\}))? ===$`)
+
+// bulkEscapePattern matches text lines that would read as a header, with any
+// backslashes already escaping them. Each is written with one more backslash,
+// which is removed again when the buffer is read.
+var bulkEscapePattern = regexp.MustCompile(`^\\*=== note `)
+
+const bulkEditHelp = `# Edit the text, tags, status and metadata of each note below, then save and quit.
+# Each note starts at its "=== note" header; keep the headers intact.
+# Tags are comma-delimited and metadata is a JSON object of keys and values.
+# Text lines starting with "=== note " get a leading \ that is removed on save.
+# Removing a note's section leaves it unchanged.
+# Lines starting with "# " before the first header are ignored.
+`
+
+func bulkHeader(n note) string {
+	meta, _ := json.Marshal(map[string]string(n.Meta))
+	if n.Meta == nil {
+		meta = []byte("{}")
+	}
+	return fmt.Sprintf("=== note %d | tags: %s | status: %s | meta: %s ===", n.ID, strings.Join(n.Tags, ","), n.Status, meta)
+}
+
+func bulkBuffer(notes []note) string {
+	var b strings.Builder
+	b.WriteString(bulkEditHelp)
+	for _, n := range notes {
+		b.WriteString("\n" + bulkHeader(n) + "\n")
+		for _, line := range strings.Split(strings.TrimRight(n.Text, "\n"), "\n") {
+			if bulkEscapePattern.MatchString(line) {
+				line = "\\" + line
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// parseBulkBuffer reads the edited buffer back into notes keyed by ID.
+func parseBulkBuffer(buffer string) (map[int]note, error) {
+	edited := make(map[int]note)
+	var current *note
+	var lines []string
+	flush := func() {
+		if current != nil {
+			current.Text = strings.Trim(strings.Join(lines, "\n"), "\n") + "\n"
+			edited[current.ID] = *current
+		}
+	}
+	scanner := bufio.NewScanner(strings.NewReader(buffer))
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := bulkHeaderPattern.FindStringSubmatch(line); m != nil {
+			flush()
+			id, _ := strconv.Atoi(m[1])
+			if _, dup := edited[id]; dup {
+				return nil, fmt.Errorf("note %d appears more than once", id)
+			}
+			var tags tagList
+			for _, tag := range strings.Split(m[2], ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			status := strings.TrimSpace(m[3])
+			if status != "" && !validStatus(status) {
+				return nil, fmt.Errorf("note %d: unknown status %q", id, status)
+			}
+			current = &note{ID: id, Tags: tags, Status: status}
+			if m[4] != "" {
+				if err := json.Unmarshal([]byte(m[4]), &current.Meta); err != nil {
+					return nil, fmt.Errorf("note %d: invalid metadata: %v", id, err)
+				}
+				if current.Meta == nil {
+					current.Meta = metaList{}
+				}
+			}
+			lines = nil
+			continue
+		}
+		if current != nil {
+			if strings.HasPrefix(line, "\\") && bulkEscapePattern.MatchString(line) {
+				line = line[1:]
+			}
+			lines = append(lines, line)
+		}
+	}
+	flush()
+	return edited, scanner.Err()
+}
+
+// bulkEdit opens every note matching the query in one editor buffer and
+// applies the changes made to each note's text, tags and status.
+func bulkEdit(q string, database *sql.DB) error {
+	query, err := parseQuery(q)
+	if err != nil {
+		return err
+	}
+	notes, err := findNotes(query, database)
+	if err != nil {
+		return err
+	}
+	if len(notes) == 0 {
+		return errNoMatch
+	}
+	for i := range notes {
+		if notes[i].Meta, err = getNoteMeta(notes[i].ID, database); err != nil {
+			return err
+		}
+	}
+	buffer, err := captureFromEditorWithTemplate(bulkBuffer(notes))
+	if err != nil {
+		return err
+	}
+	edited, err := parseBulkBuffer(string(buffer))
+	if err != nil {
+		return fmt.Errorf("nothing was changed: %v", err)
+	}
+	reader := interactiveReader()
+	for _, original := range notes {
+		if n, ok := edited[original.ID]; ok && n.Tags.String() != original.Tags.String() {
+			if err := checkTagVocabulary(n.Tags, reader); err != nil {
+				return fmt.Errorf("nothing was changed: %v", err)
+			}
+		}
+	}
+	if err := checkBulkAliases(notes, edited, database); err != nil {
+		return fmt.Errorf("nothing was changed: %v", err)
+	}
+	// The edits apply together or not at all, also when interrupted.
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	changed := 0
+	for _, original := range notes {
+		if interrupted(interrupt) {
+			rollbackJournaled(tx)
+			return errors.New("interrupted, no notes were changed")
+		}
+		n, ok := edited[original.ID]
+		if !ok {
+			continue
+		}
+		updated := false
+		if strings.TrimRight(n.Text, "\n") != strings.TrimRight(original.Text, "\n") {
+			if err := updateNoteText(n.ID, n.Text, tx); err != nil {
+				rollbackJournaled(tx)
+				return err
+			}
+			updated = true
+		}
+		if len(n.Tags) == 0 {
+			n.Tags = tagList{"generic"}
+		}
+		if n.Tags.String() != original.Tags.String() {
+			if err := setNoteTags(n.ID, n.Tags, tx); err != nil {
+				rollbackJournaled(tx)
+				return err
+			}
+			updated = true
+		}
+		if n.Status != original.Status {
+			if err := setNoteStatus(n.ID, n.Status, tx); err != nil {
+				rollbackJournaled(tx)
+				return err
+			}
+			updated = true
+		}
+		metaChanged, err := applyBulkMeta(n.ID, original.Meta, n.Meta, tx)
+		if err != nil {
+			rollbackJournaled(tx)
+			return err
+		}
+		updated = updated || metaChanged
+		if updated {
+			changed++
+		}
+	}
+	if err := commitJournaled(tx); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %d of %d notes.\n", changed, len(notes))
+	return nil
+}
+
+// checkBulkAliases refuses aliases changed in the buffer that are invalid or
+// would be held by two notes.
+func checkBulkAliases(notes []note, edited map[int]note, database *sql.DB) error {
+	held := make(map[string]int)
+	for _, original := range notes {
+		n, ok := edited[original.ID]
+		if !ok || n.Meta == nil || n.Meta[aliasKey] == original.Meta[aliasKey] {
+			continue
+		}
+		alias := strings.ToLower(n.Meta[aliasKey])
+		if alias == "" {
+			continue
+		}
+		if err := validateAlias(alias); err != nil {
+			return fmt.Errorf("note %d: %v", n.ID, err)
+		}
+		if other, dup := held[alias]; dup {
+			return fmt.Errorf("notes %d and %d both have alias %q", other, n.ID, alias)
+		}
+		held[alias] = n.ID
+		var existing int
+		err := database.QueryRow("SELECT note_id FROM metadata WHERE key = (?) AND value = (?)", aliasKey, alias).Scan(&existing)
+		if err == nil && existing != n.ID {
+			if other, ok := edited[existing]; !ok || other.Meta == nil || strings.ToLower(other.Meta[aliasKey]) == alias {
+				return fmt.Errorf("alias %q is already used by note %d", alias, existing)
+			}
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		n.Meta[aliasKey] = alias
+	}
+	return nil
+}
+
+// applyBulkMeta writes the metadata keys edited in the buffer, reporting
+// whether any changed. A header without metadata leaves it unchanged.
+func applyBulkMeta(id int, original metaList, edited metaList, database execer) (bool, error) {
+	if edited == nil {
+		return false, nil
+	}
+	changed := false
+	for key, value := range edited {
+		if current, ok := original[key]; ok && current == value {
+			continue
+		}
+		if err := setNoteMeta(id, key, value, database); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	for key := range original {
+		if _, ok := edited[key]; ok {
+			continue
+		}
+		if err := deleteNoteMeta(id, key, database); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// runBulkEdit edits the notes matching a query in one editor session.
+func runBulkEdit(args []string) error {
+	bulkEditCommand := newFlagSet("bulk-edit")
+	bulkEditQueryPtr := bulkEditCommand.String("q", "", "Query selecting the notes to edit, e.g. 'tag:meeting after:2024-01-01'.")
+	if err := parseFlags(bulkEditCommand, args); err != nil {
+		return err
+	}
+	if *bulkEditQueryPtr == "" {
+		bulkEditCommand.PrintDefaults()
+		return exitStatus(1)
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return bulkEdit(*bulkEditQueryPtr, database)
+}
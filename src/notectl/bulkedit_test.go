@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBulkBufferRoundTrip(t *testing.T) {
+	original := []note{
+		{ID: 1, Text: "top\n=== note 2 | tags: x | status:  ===\n\\=== note 3 | tags: y | status:  | meta: {} ===\nend\n", Tags: tagList{"work"}, Meta: metaList{"alias": "top", "weird": "a | b === c"}},
+		{ID: 2, Text: "second\n", Tags: tagList{"generic"}, Status: "todo"},
+	}
+	edited, err := parseBulkBuffer(bulkBuffer(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edited) != 2 {
+		t.Fatalf("read %d notes back, want 2", len(edited))
+	}
+	for _, want := range original {
+		got := edited[want.ID]
+		if got.Text != want.Text {
+			t.Errorf("note %d text read back as %q, want %q", want.ID, got.Text, want.Text)
+		}
+		if got.Tags.String() != want.Tags.String() || got.Status != want.Status {
+			t.Errorf("note %d read back with tags %v and status %q", want.ID, got.Tags, got.Status)
+		}
+		if len(got.Meta) != len(want.Meta) {
+			t.Errorf("note %d metadata read back as %v, want %v", want.ID, got.Meta, want.Meta)
+		}
+		for key, value := range want.Meta {
+			if got.Meta[key] != value {
+				t.Errorf("note %d metadata %s read back as %q, want %q", want.ID, key, got.Meta[key], value)
+			}
+		}
+	}
+}
+
+func TestBulkBufferWithoutMetaKeepsMetadata(t *testing.T) {
+	edited, err := parseBulkBuffer("=== note 4 | tags: a,b | status: done ===\ntext\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := edited[4]; n.Meta != nil || strings.Join(n.Tags, ",") != "a,b" {
+		t.Errorf("header without meta read as tags %v, metadata %v", n.Tags, n.Meta)
+	}
+	changed, err := applyBulkMeta(4, metaList{"alias": "kept"}, edited[4].Meta, openTestDatabase(t))
+	if err != nil || changed {
+		t.Errorf("applying a header without meta changed metadata: %v", err)
+	}
+}
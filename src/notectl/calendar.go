@@ -0,0 +1,137 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// calendarLevels are the glyphs used to shade a day, from no notes to busiest.
+var calendarLevels = []string{"·", "░", "▒", "▓", "█"}
+
+// plainCalendarLevels replace calendarLevels in plain output.
+var plainCalendarLevels = []string{"0", "1", "2", "3", "4"}
+
+// calendarLevel buckets a day's note count relative to the busiest day of the year.
+func calendarLevel(count int, max int) int {
+	if count == 0 || max == 0 {
+		return 0
+	}
+	level := (count*(len(calendarLevels)-1) + max - 1) / max
+	if level < 1 {
+		level = 1
+	}
+	return level
+}
+
+func countNotesPerDay(year int, tag string, database *sql.DB) (map[string]int, error) {
+	var filter queryFilter
+	filter.Add("year = (?)", year)
+	if tag != "" {
+		filter.Add(tagMatchClause, tag)
+	}
+	rows, err := database.Query("SELECT month, day, COUNT(*) FROM notes"+filter.Where()+" GROUP BY month, day", filter.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	var month, day, count int
+	for rows.Next() {
+		if err := rows.Scan(&month, &day, &count); err != nil {
+			return nil, err
+		}
+		counts[fmt.Sprintf("%02d-%02d", month, day)] = count
+	}
+	return counts, rows.Err()
+}
+
+// daysBetween counts the calendar days from a to b. Days are compared as UTC
+// dates, since a local day is 23 or 25 hours long when daylight saving time
+// starts or ends.
+func daysBetween(a time.Time, b time.Time) int {
+	utc := func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return int(utc(b).Sub(utc(a)).Hours() / 24)
+}
+
+// showCalendar renders a contribution-style heatmap with one column per week
+// and one row per weekday, starting at the beginning of January 1st's week.
+func showCalendar(year int, tag string, database *sql.DB) error {
+	counts, err := countNotesPerDay(year, tag, database)
+	if err != nil {
+		return err
+	}
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	last := time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local)
+	start := startOfWeek(first)
+	weeks := daysBetween(start, last)/7 + 1
+
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+
+	levels := calendarLevels
+	if plainOutput {
+		levels = plainCalendarLevels
+	}
+	names, _ := currentLocaleNames()
+	grid := make([][]string, 7)
+	for i := range grid {
+		grid[i] = make([]string, weeks)
+		for j := range grid[i] {
+			grid[i][j] = " "
+		}
+	}
+	labels := []rune(strings.Repeat(" ", weeks*2+4))
+	total, streak, longest := 0, 0, 0
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		week := daysBetween(start, d) / 7
+		count := counts[d.Format("01-02")]
+		grid[(int(d.Weekday())-int(start.Weekday())+7)%7][week] = levels[calendarLevel(count, max)]
+		if d.Day() == 1 {
+			copy(labels[week*2:], []rune(names.ShortMonths[d.Month()-1]))
+		}
+		total += count
+		if count > 0 {
+			streak++
+			if streak > longest {
+				longest = streak
+			}
+		} else {
+			streak = 0
+		}
+	}
+
+	fmt.Printf("    %s\n", strings.TrimRight(string(labels), " "))
+	for row := range grid {
+		day := names.ShortDays[(int(start.Weekday())+row)%7]
+		fmt.Printf("%s %s\n", padRight(day, 3), strings.Join(grid[row], " "))
+	}
+	fmt.Printf("    Less %s More\n", strings.Join(levels, " "))
+	fmt.Printf("%d notes in %d, longest streak: %d days\n", total, year, longest)
+	return nil
+}
+
+// runCalendar prints a year of notes as a heatmap.
+func runCalendar(args []string) error {
+	calendarCommand := newFlagSet("calendar")
+	calendarYearPtr := calendarCommand.Int("year", time.Now().Year(), "Year to render the heatmap for.")
+	calendarTagPtr := calendarCommand.String("tag", "", "Only count notes with this tag.")
+	if err := parseFlags(calendarCommand, args); err != nil {
+		return err
+	}
+	if err := validateYear(*calendarYearPtr); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return showCalendar(*calendarYearPtr, *calendarTagPtr, database)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDaysBetweenAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	setTestConfig(t, "week.start", "sunday")
+	start := startOfWeek(time.Date(2026, time.January, 1, 0, 0, 0, 0, newYork))
+	tests := []struct {
+		day  time.Time
+		week int
+	}{
+		{time.Date(2026, time.March, 7, 0, 0, 0, 0, newYork), 9},
+		{time.Date(2026, time.March, 8, 0, 0, 0, 0, newYork), 10},
+		{time.Date(2026, time.March, 15, 0, 0, 0, 0, newYork), 11},
+		{time.Date(2026, time.November, 1, 0, 0, 0, 0, newYork), 44},
+		{time.Date(2026, time.December, 31, 0, 0, 0, 0, newYork), 52},
+	}
+	for _, test := range tests {
+		if week := daysBetween(start, test.day) / 7; week != test.week {
+			t.Errorf("%s: week %d, want %d", test.day.Format("2006-01-02"), week, test.week)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paneCapture is the scrollback of a terminal multiplexer window.
+type paneCapture struct {
+	Multiplexer string
+	Session     string
+	Window      string
+	Text        string
+}
+
+// captureTmuxPane reads the current tmux pane, including up to lines of
+// scrollback, joining wrapped lines.
+func captureTmuxPane(lines int) (paneCapture, error) {
+	capture := paneCapture{Multiplexer: "tmux"}
+	names, err := exec.Command("tmux", "display-message", "-p", "#S\t#W").Output()
+	if err != nil {
+		return capture, fmt.Errorf("tmux display-message: %s", err)
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(names)), "\t", 2)
+	capture.Session = parts[0]
+	if len(parts) == 2 {
+		capture.Window = parts[1]
+	}
+	text, err := exec.Command("tmux", "capture-pane", "-p", "-J", "-S", "-"+strconv.Itoa(lines)).Output()
+	if err != nil {
+		return capture, fmt.Errorf("tmux capture-pane: %s", err)
+	}
+	capture.Text = string(text)
+	return capture, nil
+}
+
+// captureScreenWindow reads the current GNU screen window and its scrollback
+// through a hardcopy file.
+func captureScreenWindow() (paneCapture, error) {
+	capture := paneCapture{Multiplexer: "screen"}
+	// STY is "<pid>.<session name>".
+	sty := os.Getenv("STY")
+	capture.Session = sty[strings.Index(sty, ".")+1:]
+	capture.Window = os.Getenv("WINDOW")
+	file, err := ioutil.TempFile("", "notectl-screen")
+	if err != nil {
+		return capture, err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	if err := exec.Command("screen", "-X", "hardcopy", "-h", file.Name()).Run(); err != nil {
+		return capture, fmt.Errorf("screen hardcopy: %s", err)
+	}
+	// screen writes the hardcopy asynchronously after the command returns.
+	time.Sleep(200 * time.Millisecond)
+	text, err := ioutil.ReadFile(file.Name())
+	capture.Text = string(text)
+	return capture, err
+}
+
+// capturePane saves the current tmux pane or screen window as a note tagged
+// with the session and window names.
+func capturePane(lines int, tags tagList, database *sql.DB) error {
+	var capture paneCapture
+	var err error
+	switch {
+	case os.Getenv("TMUX") != "":
+		capture, err = captureTmuxPane(lines)
+	case os.Getenv("STY") != "":
+		capture, err = captureScreenWindow()
+	default:
+		return errors.New("not running inside tmux or screen")
+	}
+	if err != nil {
+		return err
+	}
+	text := strings.TrimRight(capture.Text, " \n")
+	if text == "" {
+		return errors.New("the pane is empty")
+	}
+	title := capture.Session
+	if capture.Window != "" {
+		title += ":" + capture.Window
+	}
+	for _, name := range []string{capture.Session, capture.Window} {
+		if slug := personSlug(name); slug != "" {
+			tags = append(tags, slug)
+		}
+	}
+	n := note{
+		Time: time.Now(),
+		Text: fmt.Sprintf("# Terminal capture %s\n\n```\n%s\n```\n", title, text),
+		Tags: addTags(tagList{capture.Multiplexer}, tags),
+		Meta: metaList{"source": capture.Multiplexer, "session": capture.Session, "window": capture.Window},
+	}
+	if err := saveNote(&n, database); err != nil {
+		return err
+	}
+	fmt.Printf("Saved %s as note %d\n", title, n.ID)
+	return nil
+}
+
+// runCapture saves the current terminal pane as a note.
+func runCapture(args []string) error {
+	captureCommand := newFlagSet("capture")
+	capturePanePtr := captureCommand.Bool("pane", false, "Capture the current tmux pane or screen window.")
+	captureLinesPtr := captureCommand.Int("lines", 2000, "Lines of tmux scrollback to include.")
+	var captureTags tagList
+	captureCommand.Var(&captureTags, "t", "A comma-delimited list of extra tags.")
+	if err := parseFlags(captureCommand, args); err != nil {
+		return err
+	}
+	if !*capturePanePtr {
+		return errors.New("usage: notectl capture -pane [-lines n] [-t tag]")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return capturePane(*captureLinesPtr, captureTags, database)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// cardTag marks notes that are flashcards. The question and answer are
+// separated by a line holding only the card delimiter.
+const cardTag = "card"
+
+// DefaultCardDelimiter separates question from answer in a card note.
+const DefaultCardDelimiter = "---"
+
+type card struct {
+	ID       int
+	Question string
+	Answer   string
+	Tags     tagList
+}
+
+func splitCard(text string, delimiter string) (string, string, bool) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == delimiter {
+			question := strings.TrimSpace(strings.Join(lines[:i], "\n"))
+			answer := strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+			return question, answer, question != "" && answer != ""
+		}
+	}
+	return "", "", false
+}
+
+// loadCards returns all well-formed cards, skipping and reporting notes that
+// are tagged as cards but have no delimiter.
+func loadCards(delimiter string, database *sql.DB) ([]card, error) {
+	rows, err := database.Query("SELECT id, "+notes.TextColumn+", tags FROM notes WHERE "+tagMatchClause+" ORDER BY id", cardTag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cards []card
+	var id int
+	var notetext noteText
+	var tags string
+	for rows.Next() {
+		if err := rows.Scan(&id, &notetext, &tags); err != nil {
+			return nil, err
+		}
+		q, a, ok := splitCard(string(notetext), delimiter)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Skipping note %d: no %q line between question and answer\n", id, delimiter)
+			continue
+		}
+		cards = append(cards, card{ID: id, Question: q, Answer: a, Tags: parseTags(tags)})
+	}
+	return cards, rows.Err()
+}
+
+// ankiFieldEscaper keeps a field on one line and free of the tab separator.
+var ankiFieldEscaper = strings.NewReplacer("\n", "<br>", "\t", "&#9;")
+
+func ankiField(s string) string {
+	return ankiFieldEscaper.Replace(html.EscapeString(s))
+}
+
+// exportAnki writes cards in Anki's tab separated import format with tags in
+// the third column.
+func exportAnki(cards []card, w io.Writer) error {
+	if _, err := fmt.Fprint(w, "#separator:tab\n#html:true\n#tags column:3\n"); err != nil {
+		return err
+	}
+	for _, c := range cards {
+		var tags []string
+		for _, t := range c.Tags {
+			if t != cardTag {
+				tags = append(tags, t)
+			}
+		}
+		tags = append(tags, fmt.Sprintf("notectl::%d", c.ID))
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", ankiField(c.Question), ankiField(c.Answer), strings.Join(tags, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sm2State is the SuperMemo 2 scheduling state kept in a card's metadata.
+type sm2State struct {
+	EF       float64
+	Interval int
+	Reps     int
+	Due      string
+}
+
+func loadSM2(id int, database *sql.DB) (sm2State, error) {
+	state := sm2State{EF: 2.5}
+	meta, err := getNoteMeta(id, database)
+	if err != nil {
+		return state, err
+	}
+	if v, err := strconv.ParseFloat(meta["sm2.ef"], 64); err == nil {
+		state.EF = v
+	}
+	state.Interval, _ = strconv.Atoi(meta["sm2.interval"])
+	state.Reps, _ = strconv.Atoi(meta["sm2.reps"])
+	state.Due = meta["sm2.due"]
+	return state, nil
+}
+
+func saveSM2(id int, state sm2State, database *sql.DB) error {
+	values := map[string]string{
+		"sm2.ef":       strconv.FormatFloat(state.EF, 'f', 2, 64),
+		"sm2.interval": strconv.Itoa(state.Interval),
+		"sm2.reps":     strconv.Itoa(state.Reps),
+		"sm2.due":      state.Due,
+	}
+	for key, value := range values {
+		if err := setNoteMeta(id, key, value, database); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// review applies a 0-5 recall grade using the SM-2 algorithm. A failed
+// recall starts the repetitions over but leaves the ease factor alone.
+func (s sm2State) review(grade int, today time.Time) sm2State {
+	if grade < 3 {
+		s.Reps = 0
+		s.Interval = 1
+	} else {
+		switch s.Reps {
+		case 0:
+			s.Interval = 1
+		case 1:
+			s.Interval = 6
+		default:
+			s.Interval = int(math.Round(float64(s.Interval) * s.EF))
+		}
+		s.Reps++
+		q := float64(5 - grade)
+		s.EF = math.Max(1.3, s.EF+0.1-q*(0.08+q*0.02))
+	}
+	s.Due = today.AddDate(0, 0, s.Interval).Format(dueDateFormat)
+	return s
+}
+
+// reviewCards runs an interactive review of every card that is due.
+func reviewCards(cards []card, database *sql.DB) error {
+	today := time.Now()
+	reader := bufio.NewReader(os.Stdin)
+	reviewed := 0
+	for _, c := range cards {
+		state, err := loadSM2(c.ID, database)
+		if err != nil {
+			return err
+		}
+		if state.Due != "" && state.Due > today.Format(dueDateFormat) {
+			continue
+		}
+		fmt.Printf("\n#%d %s\n\n(press Enter to reveal, q to quit) ", c.ID, c.Question)
+		line, err := reader.ReadString('\n')
+		if err != nil || strings.TrimSpace(line) == "q" {
+			break
+		}
+		fmt.Printf("\n%s\n\n", c.Answer)
+		grade := -1
+		for grade < 0 {
+			fmt.Print("How well did you recall it? 0 (not at all) to 5 (perfectly): ")
+			line, err = reader.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if g, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && g >= 0 && g <= 5 {
+				grade = g
+			}
+		}
+		state = state.review(grade, today)
+		if err := saveSM2(c.ID, state, database); err != nil {
+			return err
+		}
+		fmt.Printf("Next review in %d day(s)\n", state.Interval)
+		reviewed++
+	}
+	fmt.Printf("\nReviewed %d card(s).\n", reviewed)
+	return nil
+}
+
+// runCards dispatches the "cards export" and "cards review" subcommands.
+func runCards(args []string, database *sql.DB) error {
+	usage := "usage: notectl cards <export -format anki [-o file]|review> [-delimiter ---]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	cardsCommand := newFlagSet("cards " + args[0])
+	delimiterPtr := cardsCommand.String("delimiter", DefaultCardDelimiter, "Line separating the question from the answer.")
+	switch args[0] {
+	case "export":
+		formatPtr := cardsCommand.String("format", "anki", "Export format, only anki is supported.")
+		outputPtr := cardsCommand.String("o", "", "File to write to instead of standard output.")
+		if err := parseFlags(cardsCommand, args[1:]); err != nil {
+			return err
+		}
+		if *formatPtr != "anki" {
+			return fmt.Errorf("unsupported format %q", *formatPtr)
+		}
+		cards, err := loadCards(*delimiterPtr, database)
+		if err != nil {
+			return err
+		}
+		if *outputPtr == "" {
+			return exportAnki(cards, os.Stdout)
+		}
+		file, err := os.Create(*outputPtr)
+		if err != nil {
+			return err
+		}
+		if err := exportAnki(cards, file); err != nil {
+			file.Close()
+			return err
+		}
+		fmt.Printf("Exported %d card(s) to %s\n", len(cards), *outputPtr)
+		return file.Close()
+	case "review":
+		if err := parseFlags(cardsCommand, args[1:]); err != nil {
+			return err
+		}
+		cards, err := loadCards(*delimiterPtr, database)
+		if err != nil {
+			return err
+		}
+		return reviewCards(cards, database)
+	}
+	return errors.New(usage)
+}
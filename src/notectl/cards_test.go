@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSM2Review(t *testing.T) {
+	today := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		state sm2State
+		grade int
+		want  sm2State
+	}{
+		{"first success", sm2State{EF: 2.5}, 4, sm2State{EF: 2.5, Interval: 1, Reps: 1, Due: "2026-03-02"}},
+		{"second success", sm2State{EF: 2.5, Interval: 1, Reps: 1}, 5, sm2State{EF: 2.6, Interval: 6, Reps: 2, Due: "2026-03-07"}},
+		{"later success", sm2State{EF: 2.5, Interval: 6, Reps: 2}, 3, sm2State{EF: 2.36, Interval: 15, Reps: 3, Due: "2026-03-16"}},
+		{"failure keeps ease", sm2State{EF: 2.2, Interval: 15, Reps: 3}, 1, sm2State{EF: 2.2, Interval: 1, Reps: 0, Due: "2026-03-02"}},
+		{"ease floor", sm2State{EF: 1.3, Interval: 6, Reps: 2}, 3, sm2State{EF: 1.3, Interval: 8, Reps: 3, Due: "2026-03-09"}},
+	}
+	for _, test := range tests {
+		got := test.state.review(test.grade, today)
+		got.EF = float64(int(got.EF*100+0.5)) / 100
+		if got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestExportAnkiEscapesSeparators(t *testing.T) {
+	var out bytes.Buffer
+	cards := []card{{ID: 7, Question: "a\tb <c>", Answer: "line one\nline two", Tags: []string{cardTag, "go"}}}
+	if err := exportAnki(cards, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	last := lines[len(lines)-1]
+	want := "a&#9;b &lt;c&gt;\tline one<br>line two\tgo notectl::7"
+	if last != want {
+		t.Errorf("got %q, want %q", last, want)
+	}
+}
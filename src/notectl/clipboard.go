@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand returns the command that copies stdin to the system
+// clipboard, marking the content as HTML where the tool supports it.
+func clipboardCommand(isHTML bool) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("pbcopy"), nil
+	case "windows":
+		return exec.Command("clip"), nil
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if path, err := exec.LookPath("wl-copy"); err == nil {
+			if isHTML {
+				return exec.Command(path, "--type", "text/html"), nil
+			}
+			return exec.Command(path), nil
+		}
+	}
+	if path, err := exec.LookPath("xclip"); err == nil {
+		if isHTML {
+			return exec.Command(path, "-selection", "clipboard", "-t", "text/html"), nil
+		}
+		return exec.Command(path, "-selection", "clipboard"), nil
+	}
+	if path, err := exec.LookPath("xsel"); err == nil {
+		return exec.Command(path, "--clipboard", "--input"), nil
+	}
+	return nil, errors.New("no clipboard tool found, install wl-clipboard, xclip or xsel")
+}
+
+// copyNote places a note's text on the clipboard as Markdown, as plain text
+// with the markup removed, or rendered to HTML.
+func copyNote(id int, format string, database *sql.DB) error {
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "markdown":
+	case "raw":
+		// A width no line reaches keeps renderPlainText from wrapping.
+		text = renderPlainText(text, len(text)+1)
+	case "html":
+		text = renderMarkdown(text)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	cmd, err := clipboardCommand(format == "html")
+	if err != nil {
+		return err
+	}
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	fmt.Printf("Copied note %d to the clipboard\n", id)
+	return nil
+}
+
+// runCopy copies a note to the clipboard.
+func runCopy(args []string) error {
+	copyCommand := newFlagSet("copy")
+	copyIDPtr := copyCommand.Int("i", -1, "The ID of the note to copy.")
+	copyRawPtr := copyCommand.Bool("raw", false, "Copy the text with Markdown formatting removed.")
+	copyMarkdownPtr := copyCommand.Bool("markdown", false, "Copy the text as Markdown, the default.")
+	copyHTMLPtr := copyCommand.Bool("html", false, "Copy the note rendered to HTML.")
+	if err := parseFlags(copyCommand, args); err != nil {
+		return err
+	}
+	format := "markdown"
+	switch {
+	case *copyRawPtr && !*copyMarkdownPtr && !*copyHTMLPtr:
+		format = "raw"
+	case *copyHTMLPtr && !*copyMarkdownPtr && !*copyRawPtr:
+		format = "html"
+	case *copyRawPtr || *copyHTMLPtr:
+		return errors.New("choose only one of -raw, -markdown and -html")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	if copyCommand.NArg() == 1 && *copyIDPtr == -1 {
+		if *copyIDPtr, err = resolveNoteRef(copyCommand.Arg(0), database); err != nil {
+			return err
+		}
+	}
+	if err := validateID(*copyIDPtr); err != nil {
+		return err
+	}
+	return copyNote(*copyIDPtr, format, database)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+func createClockTableIfNotExist(database *sql.DB) error {
+	_, err := database.Exec("CREATE TABLE IF NOT EXISTS clock (id INTEGER PRIMARY KEY, note_id INTEGER, start INTEGER, end INTEGER)")
+	return err
+}
+
+// clockOut closes the open interval, if there is one, and reports it.
+func clockOut(database *sql.DB) error {
+	var id, noteID int
+	var start int64
+	err := database.QueryRow("SELECT id, note_id, start FROM clock WHERE end IS NULL").Scan(&id, &noteID, &start)
+	if err == sql.ErrNoRows {
+		fmt.Println("Not clocked in.")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	now := time.Now()
+	if _, err := database.Exec("UPDATE clock SET end = (?) WHERE id = (?)", now.Unix(), id); err != nil {
+		return err
+	}
+	fmt.Printf("Clocked out of note %d after %s\n", noteID, now.Sub(time.Unix(start, 0)).Round(time.Second))
+	return nil
+}
+
+func clockIn(noteID int, database *sql.DB) error {
+	var exists int
+	if err := database.QueryRow("SELECT COUNT(*) FROM notes WHERE id = (?)", noteID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("no note with ID %d", noteID)
+	}
+	var open int
+	err := database.QueryRow("SELECT id FROM clock WHERE end IS NULL").Scan(&open)
+	if err == nil {
+		if err := clockOut(database); err != nil {
+			return err
+		}
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+	now := time.Now()
+	if _, err := database.Exec("INSERT INTO clock (note_id, start) VALUES (?, ?)", noteID, now.Unix()); err != nil {
+		return err
+	}
+	fmt.Printf("Clocked in on note %d at %s\n", noteID, now.Format(time.Kitchen))
+	return nil
+}
+
+type clockTotal struct {
+	Name     string
+	Duration time.Duration
+}
+
+func sortedTotals(totals map[string]time.Duration) []clockTotal {
+	var list []clockTotal
+	for name, d := range totals {
+		list = append(list, clockTotal{Name: name, Duration: d})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Duration == list[j].Duration {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Duration > list[j].Duration
+	})
+	return list
+}
+
+// clockReport sums tracked time since the given moment per note and per tag.
+// Intervals that are still open count up to now.
+func clockReport(since time.Time, database *sql.DB) error {
+	rows, err := database.Query("SELECT clock.note_id, clock.start, clock.end, "+notes.QualifiedTextColumn+", notes.tags FROM clock JOIN notes ON notes.id = clock.note_id WHERE clock.end IS NULL OR clock.end >= (?)", since.Unix())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	perNote := make(map[string]time.Duration)
+	perTag := make(map[string]time.Duration)
+	var total time.Duration
+	var noteID int
+	var start int64
+	var end sql.NullInt64
+	var notetext noteText
+	var tags string
+	for rows.Next() {
+		if err := rows.Scan(&noteID, &start, &end, &notetext, &tags); err != nil {
+			return err
+		}
+		from := time.Unix(start, 0)
+		if from.Before(since) {
+			from = since
+		}
+		to := time.Now()
+		if end.Valid {
+			to = time.Unix(end.Int64, 0)
+		}
+		d := to.Sub(from)
+		perNote[fmt.Sprintf("#%d %s", noteID, firstLine(string(notetext), 40))] += d
+		for _, tag := range parseTags(tags) {
+			perTag[tag] += d
+		}
+		total += d
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	fmt.Printf("Time tracked since %s\n\nBy tag:\n", formatLocal(since, "Mon 2 Jan 2006"))
+	for _, t := range sortedTotals(perTag) {
+		fmt.Printf("  %10s  %s\n", t.Duration.Round(time.Minute), t.Name)
+	}
+	fmt.Println("\nBy note:")
+	for _, t := range sortedTotals(perNote) {
+		fmt.Printf("  %10s  %s\n", t.Duration.Round(time.Minute), t.Name)
+	}
+	fmt.Printf("\nTotal: %s\n", total.Round(time.Minute))
+	return nil
+}
+
+// runClock dispatches the "clock in", "clock out" and "clock report" subcommands.
+func runClock(args []string, database *sql.DB) error {
+	usage := "usage: notectl clock <in -i <id>|out|report [-week|-since <period>]>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	if err := createClockTableIfNotExist(database); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "in":
+		inCommand := newFlagSet("clock in")
+		idPtr := inCommand.Int("i", -1, "ID of the note to track time against.")
+		if err := parseFlags(inCommand, args[1:]); err != nil {
+			return err
+		}
+		if *idPtr == -1 {
+			inCommand.PrintDefaults()
+			return errors.New(usage)
+		}
+		return clockIn(*idPtr, database)
+	case "out":
+		return clockOut(database)
+	case "report":
+		reportCommand := newFlagSet("clock report")
+		weekPtr := reportCommand.Bool("week", false, "Report on the current week.")
+		sincePtr := reportCommand.String("since", "", "Report on a period, e.g. 7d, 2w or 1m.")
+		if err := parseFlags(reportCommand, args[1:]); err != nil {
+			return err
+		}
+		since := time.Unix(0, 0)
+		if *weekPtr {
+			since = startOfWeek(time.Now())
+		} else if *sincePtr != "" {
+			var err error
+			if since, err = parseSince(*sincePtr); err != nil {
+				return err
+			}
+		}
+		return clockReport(since, database)
+	}
+	return errors.New(usage)
+}
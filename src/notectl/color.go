@@ -0,0 +1,43 @@
+package main
+
+import "os"
+
+// ANSI escape sequences for terminal colors.
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorBold    = "\x1b[1m"
+)
+
+// namedColors are the colors that can be given by name in the config.
+var namedColors = map[string]string{
+	"red":     colorRed,
+	"green":   colorGreen,
+	"yellow":  colorYellow,
+	"blue":    colorBlue,
+	"magenta": colorMagenta,
+	"cyan":    colorCyan,
+	"bold":    colorBold,
+}
+
+// useColor is false when output should be plain, for example when stdout is
+// not a terminal or NO_COLOR is set.
+var useColor = stdoutIsTerminal() && os.Getenv("NO_COLOR") == ""
+
+func stdoutIsTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in the given color when color output is enabled.
+func colorize(color string, s string) string {
+	if !useColor || s == "" {
+		return s
+	}
+	return color + s + colorReset
+}
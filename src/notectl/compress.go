@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// DefaultCompressionThreshold is the note size, in bytes, above which text is
+// compressed when compression is enabled.
+const DefaultCompressionThreshold = 4096
+
+// noteText scans the notetext column, transparently decompressing notes that
+// were stored gzipped.
+type noteText = notes.Text
+
+// compressionThreshold returns the size above which notes are compressed, or
+// -1 when the compression setting is not gzip.
+func compressionThreshold() int {
+	if configValue("compression", "none") != "gzip" {
+		return -1
+	}
+	threshold, err := strconv.Atoi(configValue("compression.threshold", ""))
+	if err != nil || threshold < 0 {
+		return DefaultCompressionThreshold
+	}
+	return threshold
+}
+
+func percent(part int64, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
+// showStats prints note counts and, with storage set, how much space note
+// text takes up and how much compression saves.
+func showStats(storage bool, dbpath string, database *sql.DB) error {
+	var notes, compressed int64
+	var first, last sql.NullInt64
+	if err := database.QueryRow("SELECT COUNT(*), COALESCE(SUM(compressed), 0), MIN(timestamp), MAX(timestamp) FROM notes").Scan(&notes, &compressed, &first, &last); err != nil {
+		return err
+	}
+	fmt.Printf("Notes: %d\n", notes)
+	if first.Valid {
+		fmt.Printf("First note: %s\n", formatDateTime(time.Unix(first.Int64, 0)))
+		fmt.Printf("Last note: %s\n", formatDateTime(time.Unix(last.Int64, 0)))
+	}
+	if !storage {
+		return nil
+	}
+	var stored, original, compressedStored, compressedOriginal int64
+	err := database.QueryRow(`SELECT
+		COALESCE(SUM(length(CAST(notetext AS BLOB))), 0),
+		COALESCE(SUM(COALESCE(textsize, length(CAST(notetext AS BLOB)))), 0),
+		COALESCE(SUM(CASE WHEN compressed = 1 THEN length(notetext) ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN compressed = 1 THEN textsize ELSE 0 END), 0)
+		FROM notes`).Scan(&stored, &original, &compressedStored, &compressedOriginal)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nCompression: %s", configValue("compression", "none"))
+	if threshold := compressionThreshold(); threshold >= 0 {
+		fmt.Printf(" (notes over %d bytes)", threshold)
+	}
+	fmt.Printf("\nCompressed notes: %d of %d (%.1f%%)\n", compressed, notes, percent(compressed, notes))
+	fmt.Printf("Note text: %d bytes stored, %d bytes uncompressed\n", stored, original)
+	if compressedOriginal > 0 {
+		fmt.Printf("Compressed notes: %d bytes stored, %d bytes uncompressed, %.1f%% saved\n",
+			compressedStored, compressedOriginal, 100-percent(compressedStored, compressedOriginal))
+	}
+	fmt.Printf("Total saved: %d bytes (%.1f%%)\n", original-stored, percent(original-stored, original))
+	if info, err := os.Stat(dbpath); err == nil {
+		fmt.Printf("Database file: %d bytes\n", info.Size())
+	}
+	return nil
+}
+
+// runStats prints statistics about the notes and, with -storage, the
+// space they take.
+func runStats(args []string) error {
+	statsCommand := newFlagSet("stats")
+	statsStoragePtr := statsCommand.Bool("storage", false, "Report storage use and compression savings.")
+	if err := parseFlags(statsCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return showStats(*statsStoragePtr, databasePath(), database)
+}
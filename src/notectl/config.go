@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// config holds settings read from the configuration file, keyed by their
+// dotted names, e.g. "week.start". Every setting can also be given through
+// the environment, see configEnv.
+var config = make(map[string]string)
+
+// configFile is the file settings were loaded from, which setConfigValue
+// writes back to. It is empty when they were read from standard input.
+var configFile string
+
+// configPath returns the configuration file location, $HOME/.notectl.conf
+// unless NOTECTL_CONFIG points elsewhere.
+func configPath() string {
+	if path := os.Getenv("NOTECTL_CONFIG"); path != "" {
+		return path
+	}
+	return fmt.Sprintf("%s/.notectl.conf", os.Getenv("HOME"))
+}
+
+// loadConfig reads "key = value" lines, ignoring blank lines and # comments.
+// A missing file is not an error, and a path of - reads standard input.
+func loadConfig(path string) error {
+	if path == "-" {
+		return readConfig("standard input", os.Stdin)
+	}
+	configFile = path
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	return readConfig(path, file)
+}
+
+func readConfig(path string, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%s:%d: expected key = value", path, lineNumber)
+		}
+		config[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+	}
+	return scanner.Err()
+}
+
+// configEnv returns the environment variable that overrides a setting, e.g.
+// NOTECTL_WEEK_START for week.start.
+func configEnv(key string) string {
+	return "NOTECTL_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// setConfigValue stores a setting in the configuration file, replacing the
+// line that sets it or appending one, and keeping comments and other lines.
+// An empty value removes the setting.
+func setConfigValue(key string, value string) error {
+	if configFile == "" {
+		return fmt.Errorf("cannot save %s, the configuration was read from standard input", key)
+	}
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	found := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) == 2 && !strings.HasPrefix(strings.TrimSpace(line), "#") && strings.TrimSpace(kv[0]) == key {
+			if !found && value != "" {
+				lines = append(lines, key+" = "+value)
+			}
+			found = true
+			continue
+		}
+		if line != "" || len(lines) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if !found && value != "" {
+		lines = append(lines, key+" = "+value)
+	}
+	if value == "" {
+		delete(config, key)
+	} else {
+		config[key] = value
+	}
+	return ioutil.WriteFile(configFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// configValue returns a setting from the environment or the configuration
+// file, in that order, or fallback when it is not set in either.
+func configValue(key string, fallback string) string {
+	if value := os.Getenv(configEnv(key)); value != "" {
+		return value
+	}
+	if value, ok := config[key]; ok && value != "" {
+		return value
+	}
+	return fallback
+}
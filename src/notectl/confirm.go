@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// assumeYes answers every confirmation with yes, set by the global -yes or -y
+// flag for scripts and cron jobs.
+var assumeYes bool
+
+// confirm asks a yes or no question, reading the answer from reader or, when
+// it is nil, from standard input. An empty answer picks defaultYes. With
+// -yes it returns true without asking, and it refuses to wait for an answer
+// that cannot come when standard input is not a terminal. The question is
+// translated, so callers pass the English text with its verbs unformatted.
+func confirm(reader *bufio.Reader, question string, defaultYes bool, args ...interface{}) (bool, error) {
+	question = fmt.Sprintf(tr(question), args...)
+	yes, no := tr("y"), tr("n")
+	choices := fmt.Sprintf("[%s/%s]", yes, strings.ToUpper(no))
+	if defaultYes {
+		choices = fmt.Sprintf("[%s/%s]", strings.ToUpper(yes), no)
+	}
+	if assumeYes {
+		fmt.Printf("%s %s %s\n", question, choices, yes)
+		return true, nil
+	}
+	if reader == nil {
+		if !stdinIsTerminal() {
+			return false, errors.New(tr("confirmation needed but standard input is not a terminal, pass -yes to confirm"))
+		}
+		reader = bufio.NewReader(os.Stdin)
+	}
+	answer, err := prompt(reader, question+" "+choices+" ")
+	if err != nil {
+		return false, err
+	}
+	switch answer = strings.ToLower(answer); answer {
+	case "":
+		return defaultYes, nil
+	case "y", "yes", yes, tr("yes"):
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Metadata keys written by context stamping.
+const (
+	contextHostKey    = "context:host"
+	contextOSKey      = "context:os"
+	contextBranchKey  = "context:branch"
+	contextWeatherKey = "context:weather"
+)
+
+// weatherTimeout bounds the weather lookup so a slow provider never holds up
+// saving a note.
+const weatherTimeout = 3 * time.Second
+
+// fetchWeather asks the configured provider for a one line summary of the
+// local weather. The provider is a URL returning plain text, such as
+// https://wttr.in/?format=%C+%t
+func fetchWeather(provider string) (string, error) {
+	client := &http.Client{Timeout: weatherTimeout}
+	resp, err := client.Get(provider)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("weather provider returned %s", resp.Status)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Scan()
+	return strings.TrimSpace(scanner.Text()), scanner.Err()
+}
+
+// stampContext records where a note was written: the hostname, operating
+// system, current git branch and, when context.weather names a provider, the
+// weather. Values already present in meta are kept and lookups that fail are
+// skipped. Nothing is stamped in privacy mode.
+func stampContext(meta metaList) {
+	if privacyMode() {
+		fmt.Fprintln(os.Stderr, "privacy mode is on, not stamping context")
+		return
+	}
+	set := func(key string, value string) {
+		if value != "" && meta[key] == "" {
+			meta[key] = value
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		set(contextHostKey, host)
+	}
+	set(contextOSKey, runtime.GOOS+"/"+runtime.GOARCH)
+	if branch, err := git("rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		set(contextBranchKey, branch)
+	}
+	if provider := configValue("context.weather", ""); provider != "" {
+		weather, err := fetchWeather(provider)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "weather:", err)
+		}
+		set(contextWeatherKey, weather)
+	}
+}
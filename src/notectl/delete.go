@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// runDelete deletes one note, the notes matching -tag and -before, or all of
+// them.
+func runDelete(args []string) error {
+	deleteCommand := newFlagSet("delete")
+	deleteAllPtr := deleteCommand.Bool("all", false, "Delete all stored notes.")
+	deleteIDPtr := deleteCommand.Int("i", -1, "The ID of the note to delete.")
+	deleteTagPtr := deleteCommand.String("tag", "", "Delete the notes with this tag.")
+	deleteBeforePtr := deleteCommand.String("before", "", "Delete the notes written before this date, <yyyy>-<mm>-<dd>.")
+	deleteForcePtr := deleteCommand.Bool("force", false, "Delete without asking for confirmation.")
+	if err := parseFlags(deleteCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	if deleteCommand.NArg() == 1 && *deleteIDPtr == -1 {
+		if *deleteIDPtr, err = resolveNoteRef(deleteCommand.Arg(0), database); err != nil {
+			return err
+		}
+	}
+	if *deleteAllPtr {
+		if err := deleteAll(*deleteForcePtr, database); err != nil {
+			return err
+		}
+	} else if *deleteIDPtr != -1 || *deleteTagPtr != "" || *deleteBeforePtr != "" {
+		if *deleteIDPtr != -1 {
+			if err := validateID(*deleteIDPtr); err != nil {
+				return err
+			}
+		}
+		if err := deleteSelected(*deleteIDPtr, *deleteTagPtr, *deleteBeforePtr, *deleteForcePtr, database); err != nil {
+			return err
+		}
+	} else {
+		deleteCommand.PrintDefaults()
+		return exitStatus(1)
+	}
+	return nil
+}
+
+func deleteAll(force bool, database *sql.DB) error {
+	ok := force
+	if !ok {
+		var err error
+		if ok, err = confirm(nil, "Are you sure you want to delete all notes?", false); err != nil {
+			return err
+		}
+	}
+	if ok {
+		fmt.Println(tr("Deleting all notes..."))
+		return dropAllNotes(database)
+	}
+	fmt.Println(tr("Not deleting notes, everything is still there."))
+	return nil
+}
+
+// deleteSelected deletes a single note, or the notes carrying a tag and
+// written before a date when those are given, after confirming unless force
+// is set.
+func deleteSelected(id int, tag string, before string, force bool, database *sql.DB) error {
+	var filter queryFilter
+	var question string
+	var args []interface{}
+	if id != -1 {
+		text, err := getNoteText(id, database)
+		if err != nil {
+			return err
+		}
+		filter.Add("id = (?)", id)
+		question, args = "Delete note %d, %q?", []interface{}{id, plainTitle(text)}
+	}
+	if tag != "" {
+		filter.Add(tagMatchClause, tag)
+	}
+	if before != "" {
+		day, err := parseDueDate(before)
+		if err != nil {
+			return err
+		}
+		filter.Add("timestamp < (?)", day.Unix())
+	}
+	ids, err := matchingNoteIDs(filter, database)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return errNoMatch
+	}
+	if id == -1 {
+		switch {
+		case tag != "" && before != "":
+			question, args = "Delete %d notes tagged %q written before %s?", []interface{}{len(ids), tag, before}
+		case tag != "":
+			question, args = "Delete %d notes tagged %q?", []interface{}{len(ids), tag}
+		default:
+			question, args = "Delete %d notes written before %s?", []interface{}{len(ids), before}
+		}
+	}
+	if !force {
+		ok, err := confirm(nil, question, false, args...)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println(tr("Not deleting notes, everything is still there."))
+			return nil
+		}
+	}
+	if err := deleteNotes(ids, database); err != nil {
+		return err
+	}
+	fmt.Printf(tr("Deleted %d notes.")+"\n", len(ids))
+	return nil
+}
+
+func matchingNoteIDs(filter queryFilter, database *sql.DB) ([]int, error) {
+	rows, err := database.Query("SELECT id FROM notes"+filter.Where()+" ORDER BY id", filter.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// deleteNotes deletes notes with their metadata, mentions and attachments,
+// all together or, when interrupted, not at all. Attachment data in a
+// blobStore is left in place as other attachments may share it.
+func deleteNotes(ids []int, database *sql.DB) error {
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if interrupted(interrupt) {
+			rollbackJournaled(tx)
+			return errors.New("interrupted, no notes were deleted")
+		}
+		if err := deleteNote(id, tx); err != nil {
+			rollbackJournaled(tx)
+			return fmt.Errorf("note %d: %s", id, err)
+		}
+	}
+	return commitJournaled(tx)
+}
+
+func deleteNote(id int, database execer) error {
+	if err := notes.DeleteNote(database, id); err != nil {
+		return err
+	}
+	return journal(database, journalEntry{Op: journalDelete, ID: id})
+}
+
+func dropAllNotes(database *sql.DB) error {
+	if err := notes.DeleteAll(database); err != nil {
+		return err
+	}
+	return journal(database, journalEntry{Op: journalDeleteAll})
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type diffOp struct {
+	Kind byte // ' ', '-' or '+'
+	Line string
+}
+
+// diffLines computes a line diff from the longest common subsequence.
+func diffLines(a []string, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff renders ops as unified diff hunks, returning an empty string
+// when there are no changes.
+func unifiedDiff(ops []diffOp) string {
+	var b strings.Builder
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].Kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		// Extend the hunk until diffContext*2 unchanged lines separate changes.
+		end := start
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > diffContext*2 {
+				break
+			}
+			end = run
+		}
+		to := end + diffContext
+		if to > len(ops) {
+			to = len(ops)
+		}
+		aStart, bStart := 1, 1
+		for _, op := range ops[:from] {
+			if op.Kind != '+' {
+				aStart++
+			}
+			if op.Kind != '-' {
+				bStart++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, op := range ops[from:to] {
+			if op.Kind != '+' {
+				aLen++
+			}
+			if op.Kind != '-' {
+				bLen++
+			}
+		}
+		b.WriteString(colorize(colorCyan, fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aLen, bStart, bLen)) + "\n")
+		for _, op := range ops[from:to] {
+			line := string(op.Kind) + op.Line
+			switch op.Kind {
+			case '-':
+				line = colorize(colorRed, line)
+			case '+':
+				line = colorize(colorGreen, line)
+			}
+			b.WriteString(line + "\n")
+		}
+		start = to
+	}
+	return b.String()
+}
+
+// noteMetaLines describes a note's metadata as sorted "key: value" lines so
+// it can be diffed like text.
+func noteMetaLines(n note, meta metaList) []string {
+	lines := []string{
+		"date: " + formatDateTime(n.Time),
+		"tags: " + strings.Join(n.Tags, ", "),
+		"status: " + n.Status,
+	}
+	var keys []string
+	for key := range meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, key+": "+meta[key])
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func loadNote(id int, database *sql.DB) (note, metaList, error) {
+	stored, err := noteStore(database).Get(id)
+	if err != nil {
+		return note{}, nil, err
+	}
+	n := noteFromStore(stored)
+	return n, n.Meta, nil
+}
+
+// diffNotes prints a unified diff of two notes' metadata and text.
+func diffNotes(id1 int, id2 int, database *sql.DB) error {
+	a, aMeta, err := loadNote(id1, database)
+	if err != nil {
+		return err
+	}
+	b, bMeta, err := loadNote(id2, database)
+	if err != nil {
+		return err
+	}
+	fmt.Println(colorize(colorBold, fmt.Sprintf("--- note %d", id1)))
+	fmt.Println(colorize(colorBold, fmt.Sprintf("+++ note %d", id2)))
+	if d := unifiedDiff(diffLines(noteMetaLines(a, aMeta), noteMetaLines(b, bMeta))); d != "" {
+		fmt.Println("metadata:")
+		fmt.Print(d)
+	}
+	if d := unifiedDiff(diffLines(splitLines(a.Text), splitLines(b.Text))); d != "" {
+		fmt.Println("text:")
+		fmt.Print(d)
+	} else {
+		fmt.Println("text: identical")
+	}
+	return nil
+}
+
+// runDiff prints the differences between two notes.
+func runDiff(args []string) error {
+	diffCommand := newFlagSet("diff")
+	diffNoColorPtr := diffCommand.Bool("no-color", false, "Do not color the diff.")
+	refs, err := parseInterspersed(diffCommand, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) != 2 {
+		return errors.New("usage: notectl diff [-no-color] <id|alias> <id|alias>")
+	}
+	if *diffNoColorPtr {
+		useColor = false
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	id1, err := resolveNoteRef(refs[0], database)
+	if err != nil {
+		return err
+	}
+	id2, err := resolveNoteRef(refs[1], database)
+	if err != nil {
+		return err
+	}
+	return diffNotes(id1, id2, database)
+}
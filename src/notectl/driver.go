@@ -0,0 +1,13 @@
+//go:build !sqlcipher
+// +build !sqlcipher
+
+package main
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is the database/sql driver notes are stored with. The
+// go-sqlite3 driver needs cgo: built with CGO_ENABLED=0 notectl still
+// compiles, but opening a database fails with go-sqlite3's cgo error.
+const sqliteDriver = "sqlite3"
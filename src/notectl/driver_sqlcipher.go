@@ -0,0 +1,65 @@
+//go:build sqlcipher
+// +build sqlcipher
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Builds tagged sqlcipher link go-sqlite3 against SQLCipher instead of the
+// bundled SQLite (see the build-sqlcipher make target) so the whole database
+// file is encrypted. Every connection is unlocked with PRAGMA key before use.
+const sqliteDriver = "sqlcipher"
+
+var databaseKey struct {
+	once sync.Once
+	key  string
+	err  error
+}
+
+// readDatabaseKey reads the database key from NOTECTL_DB_KEY or the keyring,
+// prompting on the terminal, without echo, when it is in neither. The key is
+// read once per run.
+func readDatabaseKey() (string, error) {
+	databaseKey.once.Do(func() {
+		if key := secretValue("db.key"); key != "" {
+			databaseKey.key = key
+			return
+		}
+		if !stdinIsTerminal() {
+			databaseKey.err = errors.New("no database key, set NOTECTL_DB_KEY or store db.key in the keyring")
+			return
+		}
+		databaseKey.key, databaseKey.err = promptSecret("Database key: ")
+		if databaseKey.err == nil && databaseKey.key == "" {
+			databaseKey.err = errors.New("a database key is required")
+		}
+	})
+	return databaseKey.key, databaseKey.err
+}
+
+func unlockDatabase(conn *sqlite3.SQLiteConn) error {
+	key, err := readDatabaseKey()
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec("PRAGMA key = '"+strings.Replace(key, "'", "''", -1)+"'", nil); err != nil {
+		return err
+	}
+	// A wrong key only shows once the file is read.
+	if _, err := conn.Exec("SELECT count(*) FROM sqlite_master", nil); err != nil {
+		return fmt.Errorf("could not unlock the database, is the key correct? (%s)", err)
+	}
+	return nil
+}
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{ConnectHook: unlockDatabase})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// enableEchoInput is the console mode flag echoing typed characters.
+const enableEchoInput = 0x4
+
+var setConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// disableEcho stops the console on stdin from echoing what is typed, and
+// returns a function turning echo back on.
+func disableEcho() (func(), error) {
+	handle := syscall.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return nil, err
+	}
+	if ok, _, err := setConsoleMode.Call(uintptr(handle), uintptr(mode&^enableEchoInput)); ok == 0 {
+		return nil, err
+	}
+	return func() { setConsoleMode.Call(uintptr(handle), uintptr(mode)) }, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// editNote opens a note's text in the editor and saves it back when it was
+// changed.
+func editNote(id int, database *sql.DB) error {
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	edited, err := captureFromEditorWithTemplate(text)
+	if err != nil {
+		return err
+	}
+	if string(edited) == text {
+		fmt.Printf("Note %d is unchanged\n", id)
+		return nil
+	}
+	if strings.TrimSpace(string(edited)) == "" {
+		return fmt.Errorf("not saving an empty note, use notectl delete -i %d to delete it", id)
+	}
+	if err := updateNoteText(id, string(edited), database); err != nil {
+		return err
+	}
+	fmt.Printf("Updated note %d\n", id)
+	return nil
+}
+
+// appendToNote adds text to the end of a note on a new line. Without text it
+// reads from standard input when that is not a terminal, and opens the editor
+// otherwise.
+func appendToNote(id int, text string, database *sql.DB) error {
+	existing, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		var data []byte
+		if stdinIsTerminal() {
+			data, err = captureFromEditor()
+		} else {
+			data, err = ioutil.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			return err
+		}
+		text = string(data)
+	}
+	text = strings.TrimRight(text, "\n")
+	if strings.TrimSpace(text) == "" {
+		return fmt.Errorf("nothing to append to note %d", id)
+	}
+	if existing != "" && !strings.HasSuffix(existing, "\n") {
+		existing += "\n"
+	}
+	if err := updateNoteText(id, existing+text+"\n", database); err != nil {
+		return err
+	}
+	fmt.Printf("Appended to note %d\n", id)
+	return nil
+}
+
+// DefaultEditor Default text editor for notes
+const DefaultEditor = "vi"
+
+func openFileInEditor(filename string) error {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = DefaultEditor
+	}
+
+	executable, err := exec.LookPath(editor)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(executable, filename)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+func captureFromEditor() ([]byte, error) {
+	return captureFromEditorWithTemplate("")
+}
+
+// captureFromEditorWithTemplate opens the editor on a buffer pre-filled with template.
+func captureFromEditorWithTemplate(template string) ([]byte, error) {
+	file, err := ioutil.TempFile(os.TempDir(), "*")
+	if err != nil {
+		return []byte{}, err
+	}
+
+	filename := file.Name()
+
+	defer os.Remove(filename)
+
+	if _, err = file.WriteString(template); err != nil {
+		file.Close()
+		return []byte{}, err
+	}
+
+	if err = file.Close(); err != nil {
+		return []byte{}, err
+	}
+
+	if err = openFileInEditor(filename); err != nil {
+		return []byte{}, err
+	}
+
+	bytes, err := ioutil.ReadFile(filename)
+
+	return bytes, nil
+}
+
+// runEdit opens a note in the editor and saves the changes.
+func runEdit(args []string) error {
+	editCommand := newFlagSet("edit")
+	editIDPtr := editCommand.Int("i", -1, "The ID of the note to edit.")
+	if err := parseFlags(editCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	if editCommand.NArg() == 1 && *editIDPtr == -1 {
+		if *editIDPtr, err = resolveNoteRef(editCommand.Arg(0), database); err != nil {
+			return err
+		}
+	}
+	if err := validateID(*editIDPtr); err != nil {
+		return err
+	}
+	return editNote(*editIDPtr, database)
+}
+
+// runAppend adds text to the end of a note.
+func runAppend(args []string) error {
+	appendCommand := newFlagSet("append")
+	appendIDPtr := appendCommand.Int("i", -1, "The ID of the note to append to.")
+	if err := parseFlags(appendCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	// The first argument names the note unless -i does, the rest is the
+	// text to append.
+	args = appendCommand.Args()
+	if *appendIDPtr == -1 && len(args) > 0 {
+		if *appendIDPtr, err = resolveNoteRef(args[0], database); err != nil {
+			return err
+		}
+		args = args[1:]
+	}
+	if err := validateID(*appendIDPtr); err != nil {
+		return err
+	}
+	return appendToNote(*appendIDPtr, strings.Join(args, " "), database)
+}
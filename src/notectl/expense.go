@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// expenseType is the built-in note type for expenses and receipts.
+var expenseType = noteType{
+	Name:   "expense",
+	Fields: []string{"amount", "currency", "category"},
+	Tags:   tagList{"expense"},
+}
+
+// parseAmount reads an amount such as 12.50 or 12,50 and formats it with two
+// decimals.
+func parseAmount(s string) (string, error) {
+	amount, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(s), ",", ".", 1), 64)
+	if err != nil || amount < 0 {
+		return "", fmt.Errorf("invalid amount %q, expected a number such as 12.50", s)
+	}
+	return strconv.FormatFloat(amount, 'f', 2, 64), nil
+}
+
+func addExpense(amount string, currency string, category string, description string, database *sql.DB) error {
+	amount, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	meta := metaList{"amount": amount, "currency": strings.ToUpper(currency), "category": strings.ToLower(category)}
+	if err := expenseType.requireFields(meta, nil); err != nil {
+		return err
+	}
+	text := description
+	if text == "" {
+		text = fmt.Sprintf("%s %s %s", amount, meta["currency"], meta["category"])
+	}
+	n := note{Time: time.Now(), Text: text, Tags: expenseType.Tags, Meta: meta}
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded expense %d: %s %s for %s\n", n.ID, amount, meta["currency"], meta["category"])
+	return nil
+}
+
+type expenseTotal struct {
+	Category string
+	Currency string
+	Total    float64
+	Count    int
+}
+
+// expenseReport totals the expenses of a month per category and currency.
+func expenseReport(month int, year int, asCSV bool, database *sql.DB) error {
+	rows, err := database.Query(`SELECT
+		MAX(CASE WHEN metadata.key = 'category' THEN metadata.value END),
+		MAX(CASE WHEN metadata.key = 'currency' THEN metadata.value END),
+		MAX(CASE WHEN metadata.key = 'amount' THEN metadata.value END)
+		FROM notes JOIN metadata ON metadata.note_id = notes.id
+		WHERE notes.month = (?) AND notes.year = (?)
+		AND notes.id IN (SELECT note_id FROM metadata WHERE key = 'type' AND value = 'expense')
+		GROUP BY notes.id`, month, year)
+	if err != nil {
+		return err
+	}
+	totals := make(map[string]*expenseTotal)
+	for rows.Next() {
+		var category, currency, amount sql.NullString
+		if err := rows.Scan(&category, &currency, &amount); err != nil {
+			rows.Close()
+			return err
+		}
+		value, err := strconv.ParseFloat(amount.String, 64)
+		if err != nil {
+			continue
+		}
+		key := category.String + "\x00" + currency.String
+		if totals[key] == nil {
+			totals[key] = &expenseTotal{Category: category.String, Currency: currency.String}
+		}
+		totals[key].Total += value
+		totals[key].Count++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	var list []*expenseTotal
+	for _, t := range totals {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Currency != list[j].Currency {
+			return list[i].Currency < list[j].Currency
+		}
+		return list[i].Total > list[j].Total
+	})
+	if asCSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"category", "currency", "total", "count"})
+		for _, t := range list {
+			w.Write([]string{t.Category, t.Currency, strconv.FormatFloat(t.Total, 'f', 2, 64), strconv.Itoa(t.Count)})
+		}
+		w.Flush()
+		return w.Error()
+	}
+	if len(list) == 0 {
+		fmt.Printf("No expenses in %s %d\n", time.Month(month), year)
+		return nil
+	}
+	fmt.Printf("Expenses for %s %d\n\n", time.Month(month), year)
+	sums := make(map[string]float64)
+	for _, t := range list {
+		fmt.Printf("  %s %10.2f %s  (%d)\n", padRight(t.Category, 16), t.Total, t.Currency, t.Count)
+		sums[t.Currency] += t.Total
+	}
+	var currencies []string
+	for currency := range sums {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	for _, currency := range currencies {
+		fmt.Printf("  %s %10.2f %s\n", padRight("total", 16), sums[currency], currency)
+	}
+	return nil
+}
+
+// runExpenses dispatches the "expenses add" and "expenses report" subcommands.
+func runExpenses(args []string, database *sql.DB) error {
+	usage := "usage: notectl expenses <add [-currency c] -category c <amount> [description]|report [-month m] [-year y] [-csv]>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "add":
+		addCommand := newFlagSet("expenses add")
+		currencyPtr := addCommand.String("currency", configValue("expenses.currency", "USD"), "Currency code of the amount.")
+		categoryPtr := addCommand.String("category", "", "Category, e.g. travel or food.")
+		rest, err := parseInterspersed(addCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) == 0 || *categoryPtr == "" {
+			return errors.New(usage)
+		}
+		return addExpense(rest[0], *currencyPtr, *categoryPtr, strings.Join(rest[1:], " "), database)
+	case "report":
+		reportCommand := newFlagSet("expenses report")
+		monthPtr := reportCommand.Int("month", int(time.Now().Month()), "Month to report on.")
+		yearPtr := reportCommand.Int("year", time.Now().Year(), "Year of the month to report on.")
+		csvPtr := reportCommand.Bool("csv", false, "Write the totals as CSV.")
+		if err := parseFlags(reportCommand, args[1:]); err != nil {
+			return err
+		}
+		if err := validateMonth(*monthPtr); err != nil {
+			return err
+		}
+		if err := validateYear(*yearPtr); err != nil {
+			return err
+		}
+		return expenseReport(*monthPtr, *yearPtr, *csvPtr, database)
+	default:
+		return errors.New(usage)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// exportFile is a rendered note waiting to be written.
+type exportFile struct {
+	Name string
+	Data string
+}
+
+// renderExportFile renders a note as a Markdown file with front matter or as a
+// standalone HTML page.
+func renderExportFile(n note, format string) exportFile {
+	name := vaultFileName(n)
+	if format == "markdown" {
+		return exportFile{name, formatVaultNote(n)}
+	}
+	header := fmt.Sprintf("Note %d - %s", n.ID, formatDateTime(n.Time))
+	if len(n.Tags) > 0 {
+		header += " - " + strings.Join(n.Tags, ", ")
+	}
+	title, _ := notionTitle(n.Text)
+	return exportFile{strings.TrimSuffix(name, ".md") + ".html", renderHTMLPage(title, header, n.Text)}
+}
+
+// visibilityKey is the metadata key marking a note public or private, as
+// tagging it public or private does.
+const visibilityKey = "visibility"
+
+// exportScope narrows an export so a mixed database can be published safely.
+// It applies to every format.
+type exportScope struct {
+	// PublicOnly keeps only notes marked public.
+	PublicOnly bool
+	// IncludeTags keeps only notes with at least one of the tags.
+	IncludeTags []string
+	// ExcludeTags leaves out notes with any of the tags.
+	ExcludeTags []string
+}
+
+// visibilityClause matches notes marked with a visibility by tag or metadata.
+func visibilityClause(visibility string) string {
+	return "(" + tagMatchClause + " OR id IN (SELECT note_id FROM metadata WHERE key = '" + visibilityKey + "' AND value = (?)))"
+}
+
+// apply adds the scope to filter. Notes marked private are never included in
+// HTML exports, which are meant for publishing.
+func (s exportScope) apply(filter *queryFilter, format string) {
+	if s.PublicOnly || format == "html" {
+		filter.Add("NOT "+visibilityClause("private"), "private", "private")
+	}
+	if s.PublicOnly {
+		filter.Add(visibilityClause("public"), "public", "public")
+	}
+	if len(s.IncludeTags) > 0 {
+		var conditions []string
+		var args []interface{}
+		for _, tag := range s.IncludeTags {
+			conditions = append(conditions, tagMatchClause)
+			args = append(args, tag)
+		}
+		filter.Add("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	for _, tag := range s.ExcludeTags {
+		filter.Add("NOT "+tagMatchClause, tag)
+	}
+}
+
+// exportFiles writes the notes matching query to dir as Markdown or HTML files. A
+// reader goroutine streams notes from the database to jobs workers rendering
+// them, and the calling goroutine writes the results. The channels between
+// the stages are bounded, so memory use stays flat however large the
+// database is. An interrupt stops the export after the file being written,
+// and files are renamed into place so none is left half written.
+func exportFiles(query noteQuery, format string, dir string, jobs int, database *sql.DB) error {
+	if format != "markdown" && format != "html" {
+		return fmt.Errorf("unknown export format %q", format)
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	start := time.Now()
+	found := make(chan note, 2*jobs)
+	files := make(chan exportFile, 2*jobs)
+	done := make(chan struct{})
+	var halt sync.Once
+	readErr := make(chan error, 1)
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+
+	go func() {
+		defer close(found)
+		readErr <- streamNotes(query, database, found, done)
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for n := range found {
+				select {
+				case files <- renderExportFile(n, format):
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(files)
+	}()
+
+	written := 0
+	var writeErr error
+	wasInterrupted := false
+	for f := range files {
+		if writeErr != nil || wasInterrupted {
+			continue
+		}
+		if wasInterrupted = interrupted(interrupt); !wasInterrupted {
+			writeErr = writeFileAtomic(filepath.Join(dir, f.Name), []byte(f.Data))
+		}
+		if writeErr != nil || wasInterrupted {
+			// Stop the reader and workers, then drain what is in flight.
+			halt.Do(func() { close(done) })
+			continue
+		}
+		written++
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if wasInterrupted {
+		return fmt.Errorf("interrupted after exporting %d notes to %s", written, dir)
+	}
+	if err := <-readErr; err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d notes to %s in %s\n", written, dir, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// streamNotes sends the notes matching query to out, oldest first, until they
+// run out or done is closed.
+func streamNotes(query noteQuery, database *sql.DB, out chan<- note, done <-chan struct{}) error {
+	rows, err := database.Query("SELECT id, timestamp, "+notes.TextColumn+", tags, status, uuid FROM notes"+query.Filter.Where()+" ORDER BY timestamp, id", query.Filter.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n note
+		var timestamp int64
+		var text noteText
+		var tags string
+		var uuid sql.NullString
+		if err := rows.Scan(&n.ID, &timestamp, &text, &tags, &n.Status, &uuid); err != nil {
+			return err
+		}
+		if !query.MatchesText(string(text)) {
+			continue
+		}
+		n.Time = time.Unix(timestamp, 0)
+		n.Text = string(text)
+		n.Tags = parseTags(tags)
+		n.UUID = uuid.String
+		select {
+		case out <- n:
+		case <-done:
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// runExport exports the notes matching a query and filters to files, Notion
+// or an archive.
+func runExport(args []string) error {
+	exportCommand := newFlagSet("export")
+	exportToPtr := exportCommand.String("to", "", "Where to export to: markdown, html, notion or jsonl, a lossless archive of the notes.")
+	exportCommand.StringVar(exportToPtr, "format", "", "Same as -to.")
+	exportDirPtr := exportCommand.String("dir", "", "With -to markdown or html, the directory to write the files to.")
+	exportOutputPtr := exportCommand.String("o", "-", "With -to jsonl, the file to write the archive to, or - for standard output.")
+	exportVerifyPtr := exportCommand.Bool("verify", false, "With -to jsonl, check that importing the archive and exporting it again gives the same bytes before writing it.")
+	exportJobsPtr := exportCommand.Int("jobs", runtime.NumCPU(), "With -to markdown or html, how many notes to render at once.")
+	exportDatabasePtr := exportCommand.String("database", configValue("notion.database", ""), "With -to notion, the ID of the Notion database to create pages in.")
+	exportQueryPtr := exportCommand.String("q", "", "Only export notes matching this query.")
+	exportTagsPtr := exportCommand.String("t", "", "Only export notes with all of these comma-delimited tags.")
+	exportDayPtr := exportCommand.Int("day", -1, "Only export notes from the specified day of the current month and year.")
+	exportMonthPtr := exportCommand.Int("month", -1, "Only export notes from the specified month of the current year.")
+	exportYearPtr := exportCommand.Int("year", -1, "Only export notes from the specified year.")
+	exportDatePtr := exportCommand.String("date", "", "Only export notes from a date in the format <d>/<m>/<y>.")
+	exportUSADatePtr := exportCommand.Bool("usa", false, "Take -date in US format <m>/<d>/<y>.")
+	exportGrepPtr := exportCommand.String("grep", "", "Only export notes matching this regular expression.")
+	exportRepoPtr := exportCommand.String("repo", "", "Only export commits recorded by the git hook for a repository.")
+	exportHerePtr := exportCommand.Bool("here", false, "Only export notes associated with the current git repository or directory.")
+	exportLangPtr := exportCommand.String("lang", "", "Only export notes written in a language, given as a two letter code such as de.")
+	exportPublicPtr := exportCommand.Bool("public", configValue("export.public", "") == "on", "Only export notes tagged public or with visibility=public metadata.")
+	exportIncludeTagsPtr := exportCommand.String("include-tags", "", "Only export notes with at least one of these comma-delimited tags.")
+	exportExcludeTagsPtr := exportCommand.String("exclude-tags", "", "Leave out notes with any of these comma-delimited tags.")
+	// Bare arguments add to the query, so a period such as today can be
+	// given on its own.
+	exportArgs, err := parseInterspersed(exportCommand, args)
+	if err != nil {
+		return err
+	}
+	query := strings.TrimSpace(*exportQueryPtr + " " + strings.Join(exportArgs, " "))
+	filters := showFilters{
+		Tags:  *exportTagsPtr,
+		Day:   *exportDayPtr,
+		Month: *exportMonthPtr,
+		Year:  *exportYearPtr,
+		Date:  *exportDatePtr,
+		USA:   *exportUSADatePtr,
+		Repo:  *exportRepoPtr,
+		Here:  *exportHerePtr,
+		Lang:  *exportLangPtr,
+		Grep:  *exportGrepPtr,
+	}
+	switch *exportToPtr {
+	case "markdown", "html":
+		if *exportDirPtr == "" {
+			return fmt.Errorf("-to %s requires -dir <directory>", *exportToPtr)
+		}
+	case "notion":
+		if *exportDatabasePtr == "" {
+			return errors.New("-to notion requires -database <id> or notion.database in the config")
+		}
+	case "jsonl":
+	default:
+		return errors.New("usage: notectl export -to markdown|html -dir <directory> [-jobs n] | -to notion -database <id> | -to jsonl [-o file] [-verify], with [-public] [-include-tags t,...] [-exclude-tags t,...] [-q query] [-t tags] [-day|-month|-year|-date ...] [-grep re] [-repo r] [-here] [-lang code] [today|yesterday|this-week|...]")
+	}
+	if *exportDayPtr != -1 {
+		err = validateDay(*exportDayPtr)
+	}
+	if err == nil && *exportMonthPtr != -1 {
+		err = validateMonth(*exportMonthPtr)
+	}
+	if err == nil && *exportYearPtr != -1 {
+		err = validateYear(*exportYearPtr)
+	}
+	if err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	// The query and the filter flags, as show takes them, and the export
+	// scope apply to every format.
+	noteQuery, err := parseQuery(query)
+	if err != nil {
+		return err
+	}
+	filter, err := filters.build(database)
+	if err != nil {
+		return err
+	}
+	noteQuery.Filter.Merge(filter)
+	scope := exportScope{
+		PublicOnly:  *exportPublicPtr,
+		IncludeTags: splitList(*exportIncludeTagsPtr),
+		ExcludeTags: splitList(*exportExcludeTagsPtr),
+	}
+	scope.apply(&noteQuery.Filter, *exportToPtr)
+	switch *exportToPtr {
+	case "jsonl":
+		err = exportArchive(noteQuery, *exportOutputPtr, *exportVerifyPtr, database)
+	case "notion":
+		err = exportToNotion(noteQuery, *exportDatabasePtr, database)
+	default:
+		err = exportFiles(noteQuery, *exportToPtr, *exportDirPtr, *exportJobsPtr, database)
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestExportScopeAppliesToArchives(t *testing.T) {
+	database := openTestDatabase(t)
+	at := time.Date(2026, time.April, 1, 9, 0, 0, 0, time.UTC)
+	saveTestNote(t, database, at, "release plan", "public", "work")
+	saveTestNote(t, database, at.Add(time.Minute), "salary talk", "work", "private")
+	saveTestNote(t, database, at.Add(2*time.Minute), "blog draft", "public", "draft")
+	saveTestNote(t, database, at.Add(3*time.Minute), "groceries", "home")
+
+	tests := []struct {
+		name  string
+		query string
+		scope exportScope
+		want  []string
+	}{
+		{"everything", "", exportScope{}, []string{"release plan", "salary talk", "blog draft", "groceries"}},
+		{"public", "", exportScope{PublicOnly: true}, []string{"release plan", "blog draft"}},
+		{"include", "", exportScope{IncludeTags: []string{"work", "home"}}, []string{"release plan", "salary talk", "groceries"}},
+		{"exclude", "", exportScope{ExcludeTags: []string{"draft", "private"}}, []string{"release plan", "groceries"}},
+		{"query and scope", "tag:work", exportScope{ExcludeTags: []string{"private"}}, []string{"release plan"}},
+		{"words", "plan", exportScope{}, []string{"release plan"}},
+	}
+	for _, test := range tests {
+		query, err := parseQuery(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.scope.apply(&query.Filter, "jsonl")
+		var archive bytes.Buffer
+		count, err := writeArchive(&archive, query, database)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		var got []string
+		lines := bytes.Split(bytes.TrimSpace(archive.Bytes()), []byte("\n"))
+		for _, line := range lines[1:] {
+			var archived archiveNote
+			if err := json.Unmarshal(line, &archived); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, archived.Text)
+		}
+		if count != len(got) || len(got) != len(test.want) {
+			t.Errorf("%s: exported %q, want %q", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: exported %q, want %q", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commitTag marks notes recorded from git commits.
+const commitTag = "commit"
+
+// repoKey is the metadata key holding the repository a commit note came from.
+const repoKey = "repo"
+
+// hookMarker identifies hooks written by notectl so they can be updated.
+const hookMarker = "# installed by notectl"
+
+// git runs a git command and returns its trimmed output.
+func git(args ...string) (string, error) {
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// installGitHooks writes a post-commit hook to the current repository that
+// records every commit as a note. An existing hook is only replaced when it
+// was written by notectl or force is set.
+func installGitHooks(force bool) error {
+	hooks, err := git("rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(hooks, "post-commit")
+	if existing, err := ioutil.ReadFile(path); err == nil && !force && !strings.Contains(string(existing), hookMarker) {
+		return fmt.Errorf("%s already exists, use -force to replace it", path)
+	}
+	if err := os.MkdirAll(hooks, 0755); err != nil {
+		return err
+	}
+	hook := fmt.Sprintf("#!/bin/sh\n%s\n%q git record-commit >/dev/null 2>&1 || true\n", hookMarker, executable)
+	if err := ioutil.WriteFile(path, []byte(hook), 0755); err != nil {
+		return err
+	}
+	fmt.Printf("Installed %s\n", path)
+	return nil
+}
+
+// recordCommit saves the repository's latest commit as a note tagged with
+// the repository name.
+func recordCommit(database *sql.DB) error {
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	log, err := git("log", "-1", "--format=%H%x00%ct%x00%B")
+	if err != nil {
+		return err
+	}
+	parts := strings.SplitN(log, "\x00", 3)
+	if len(parts) != 3 {
+		return errors.New("unexpected git log output")
+	}
+	hash, message := parts[0], strings.TrimSpace(parts[2])
+	var stamp int64
+	fmt.Sscan(parts[1], &stamp)
+	repo := filepath.Base(root)
+	n := note{
+		Time: time.Unix(stamp, 0),
+		Text: message,
+		Tags: tagList{commitTag, personSlug(repo)},
+		Meta: metaList{repoKey: personSlug(repo), "commit": hash, projectKey: filepath.Clean(root)},
+	}
+	return n.Save(database)
+}
+
+// addRepoFilter restricts filter to the commits recorded for a repository.
+func addRepoFilter(filter *queryFilter, repo string) {
+	filter.Add("id IN (SELECT note_id FROM metadata WHERE key = (?) AND value = (?))", repoKey, personSlug(repo))
+}
+
+// runGit dispatches the "git install-hooks" and "git record-commit" subcommands.
+func runGit(args []string, database *sql.DB) error {
+	usage := "usage: notectl git install-hooks [-force]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "install-hooks":
+		installCommand := newFlagSet("git install-hooks")
+		forcePtr := installCommand.Bool("force", false, "Replace an existing post-commit hook.")
+		if err := parseFlags(installCommand, args[1:]); err != nil {
+			return err
+		}
+		return installGitHooks(*forcePtr)
+	case "record-commit":
+		// Run by the installed hook.
+		return recordCommit(database)
+	default:
+		return errors.New(usage)
+	}
+}
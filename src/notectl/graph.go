@@ -0,0 +1,173 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+type graphNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Kind  string `json:"kind"`
+}
+
+type graphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+}
+
+type noteGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// buildGraph links notes to the people they mention and to their tags. Tags
+// become nodes of their own unless sharedTags is set, in which case every
+// pair of notes sharing a tag is linked directly.
+func buildGraph(sharedTags bool, database *sql.DB) (noteGraph, error) {
+	var graph noteGraph
+	rows, err := database.Query("SELECT id, " + notes.TextColumn + ", tags FROM notes ORDER BY id")
+	if err != nil {
+		return graph, err
+	}
+	byTag := make(map[string][]string)
+	var id int
+	var notetext noteText
+	var tags string
+	for rows.Next() {
+		if err := rows.Scan(&id, &notetext, &tags); err != nil {
+			rows.Close()
+			return graph, err
+		}
+		node := fmt.Sprintf("note:%d", id)
+		graph.Nodes = append(graph.Nodes, graphNode{ID: node, Label: firstLine(string(notetext), 40), Kind: "note"})
+		for _, tag := range parseTags(tags) {
+			byTag[tag] = append(byTag[tag], node)
+		}
+	}
+	rows.Close()
+
+	var tagNames []string
+	for tag := range byTag {
+		tagNames = append(tagNames, tag)
+	}
+	sort.Strings(tagNames)
+	for _, tag := range tagNames {
+		notes := byTag[tag]
+		if sharedTags {
+			for i := 0; i < len(notes); i++ {
+				for j := i + 1; j < len(notes); j++ {
+					graph.Edges = append(graph.Edges, graphEdge{Source: notes[i], Target: notes[j], Kind: "tag:" + tag})
+				}
+			}
+			continue
+		}
+		graph.Nodes = append(graph.Nodes, graphNode{ID: "tag:" + tag, Label: "#" + tag, Kind: "tag"})
+		for _, node := range notes {
+			graph.Edges = append(graph.Edges, graphEdge{Source: node, Target: "tag:" + tag, Kind: "tag"})
+		}
+	}
+
+	people := make(map[string]int)
+	rows, err = database.Query("SELECT value, note_id FROM metadata WHERE key = 'slug' AND note_id IN (SELECT id FROM notes WHERE "+tagMatchClause+")", personTag)
+	if err != nil {
+		return graph, err
+	}
+	var slug string
+	for rows.Next() {
+		if err := rows.Scan(&slug, &id); err != nil {
+			rows.Close()
+			return graph, err
+		}
+		people[slug] = id
+	}
+	rows.Close()
+
+	rows, err = database.Query("SELECT note_id, person FROM mentions ORDER BY note_id, person")
+	if err != nil {
+		return graph, err
+	}
+	defer rows.Close()
+	added := make(map[string]bool)
+	for rows.Next() {
+		if err := rows.Scan(&id, &slug); err != nil {
+			return graph, err
+		}
+		target := "person:" + slug
+		if personID, ok := people[slug]; ok {
+			target = fmt.Sprintf("note:%d", personID)
+		} else if !added[target] {
+			graph.Nodes = append(graph.Nodes, graphNode{ID: target, Label: "@" + slug, Kind: "person"})
+			added[target] = true
+		}
+		graph.Edges = append(graph.Edges, graphEdge{Source: fmt.Sprintf("note:%d", id), Target: target, Kind: "mention"})
+	}
+	return graph, rows.Err()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ").Replace(s) + `"`
+}
+
+func writeDot(graph noteGraph, w io.Writer) error {
+	shapes := map[string]string{"note": "box", "tag": "ellipse", "person": "diamond"}
+	fmt.Fprintln(w, "graph notectl {")
+	for _, n := range graph.Nodes {
+		fmt.Fprintf(w, "  %s [label=%s, shape=%s];\n", dotQuote(n.ID), dotQuote(n.Label), shapes[n.Kind])
+	}
+	for _, e := range graph.Edges {
+		style := ""
+		if strings.HasPrefix(e.Kind, "tag") {
+			style = " [style=dashed]"
+		}
+		fmt.Fprintf(w, "  %s -- %s%s;\n", dotQuote(e.Source), dotQuote(e.Target), style)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// runGraph dispatches the "graph export" subcommand.
+func runGraph(args []string, database *sql.DB) error {
+	usage := "usage: notectl graph export [-format dot|json] [-shared-tags] [-o file]"
+	if len(args) == 0 || args[0] != "export" {
+		return errors.New(usage)
+	}
+	exportCommand := newFlagSet("graph export")
+	formatPtr := exportCommand.String("format", "dot", "Output format: dot or json.")
+	sharedTagsPtr := exportCommand.Bool("shared-tags", false, "Link notes sharing a tag directly instead of through tag nodes.")
+	outputPtr := exportCommand.String("o", "", "File to write to instead of standard output.")
+	if err := parseFlags(exportCommand, args[1:]); err != nil {
+		return err
+	}
+	if *formatPtr != "dot" && *formatPtr != "json" {
+		return fmt.Errorf("unsupported format %q, expected dot or json", *formatPtr)
+	}
+	graph, err := buildGraph(*sharedTagsPtr, database)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = os.Stdout
+	if *outputPtr != "" {
+		file, err := os.Create(*outputPtr)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	if *formatPtr == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(graph)
+	}
+	return writeDot(graph, w)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// highlightPattern, when set, marks the text matched by a search so list and
+// detail views can highlight it.
+var highlightPattern *regexp.Regexp
+
+// highlightMatches colors the parts of s matched by highlightPattern.
+func highlightMatches(s string) string {
+	if highlightPattern == nil || !useColor {
+		return s
+	}
+	return highlightPattern.ReplaceAllStringFunc(s, func(match string) string {
+		return colorize(colorBold+colorYellow, match)
+	})
+}
+
+// addGrepFilter restricts filter to the notes it already matches whose text
+// matches pattern, and highlights the matches. Note text is matched after
+// loading because it may be compressed. The matching IDs are bound as one
+// blob holding a byte per ID, 1 for a match, rather than a parameter each,
+// which SQLite caps at 32766 per statement.
+func addGrepFilter(filter *queryFilter, pattern string, database *sql.DB) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %s", err)
+	}
+	highlightPattern = re
+	rows, err := database.Query("SELECT id, "+notes.TextColumn+" FROM notes"+filter.Where(), filter.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		var text noteText
+		if err := rows.Scan(&id, &text); err != nil {
+			return err
+		}
+		if re.MatchString(string(text)) {
+			ids = append(ids, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return errNoMatch
+	}
+	first, last := ids[0], ids[0]
+	for _, id := range ids {
+		if id < first {
+			first = id
+		}
+		if id > last {
+			last = id
+		}
+	}
+	matched := make([]byte, last-first+1)
+	for _, id := range ids {
+		matched[id-first] = 1
+	}
+	filter.Add("id BETWEEN (?) AND (?) AND substr((?), id - (?) + 1, 1) = x'01'", first, last, matched, first)
+	return nil
+}
+
+// grepNote prints the lines of a note matching pattern, grep style: matches
+// as "n:line", context as "n-line", and "--" between separate groups.
+func grepNote(id int, pattern string, before int, after int, database *sql.DB) (int, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, fmt.Errorf("invalid pattern: %s", err)
+	}
+	highlightPattern = re
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	var matched []int
+	for i, line := range lines {
+		if re.MatchString(line) {
+			matched = append(matched, i)
+		}
+	}
+	printed := -1
+	for _, m := range matched {
+		start, end := m-before, m+after
+		if start < 0 {
+			start = 0
+		}
+		if end >= len(lines) {
+			end = len(lines) - 1
+		}
+		if start <= printed {
+			start = printed + 1
+		} else if printed >= 0 {
+			fmt.Println("--")
+		}
+		for i := start; i <= end; i++ {
+			separator := "-"
+			if re.MatchString(lines[i]) {
+				separator = ":"
+			}
+			fmt.Printf("%d%s%s\n", i+1, separator, highlightMatches(lines[i]))
+		}
+		if end > printed {
+			printed = end
+		}
+	}
+	return len(matched), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// habitTag is carried by every habit completion note, alongside the habit's name.
+const habitTag = "habit"
+
+func createHabitTableIfNotExist(database *sql.DB) error {
+	_, err := database.Exec("CREATE TABLE IF NOT EXISTS habits (name TEXT PRIMARY KEY, created INTEGER)")
+	return err
+}
+
+func habitExists(name string, database *sql.DB) (bool, error) {
+	var count int
+	err := database.QueryRow("SELECT COUNT(*) FROM habits WHERE name = (?)", name).Scan(&count)
+	return count > 0, err
+}
+
+// habitDays returns the set of days, formatted as 2006-01-02, on which the
+// habit was completed.
+func habitDays(name string, database *sql.DB) (map[string]bool, error) {
+	rows, err := database.Query("SELECT DISTINCT year, month, day FROM notes WHERE "+tagMatchClause+" AND "+tagMatchClause, habitTag, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	days := make(map[string]bool)
+	var year, month, day int
+	for rows.Next() {
+		if err := rows.Scan(&year, &month, &day); err != nil {
+			return nil, err
+		}
+		days[fmt.Sprintf("%04d-%02d-%02d", year, month, day)] = true
+	}
+	return days, rows.Err()
+}
+
+func addHabit(name string, database *sql.DB) error {
+	exists, err := habitExists(name, database)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("habit %q already exists", name)
+	}
+	if _, err := database.Exec("INSERT INTO habits (name, created) VALUES (?, ?)", name, time.Now().Unix()); err != nil {
+		return err
+	}
+	fmt.Printf("Tracking habit %q\n", name)
+	return nil
+}
+
+func completeHabit(name string, database *sql.DB) error {
+	exists, err := habitExists(name, database)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("unknown habit %q, add it with: notectl habit add %s", name, name)
+	}
+	days, err := habitDays(name, database)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if days[now.Format("2006-01-02")] {
+		fmt.Printf("Habit %q is already done today.\n", name)
+		return nil
+	}
+	n := note{Time: now, Text: fmt.Sprintf("Habit done: %s", name), Tags: tagList{habitTag, name}, Status: "done"}
+	n.PrintConsole()
+	return n.Save(database)
+}
+
+// habitStreaks returns the current streak, which may end today or yesterday,
+// and the longest streak ever recorded.
+func habitStreaks(days map[string]bool, since time.Time) (int, int) {
+	today := time.Now()
+	longest, run := 0, 0
+	for d := since; !d.After(today); d = d.AddDate(0, 0, 1) {
+		if days[d.Format("2006-01-02")] {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else if d.Format("2006-01-02") != today.Format("2006-01-02") {
+			run = 0
+		}
+	}
+	return run, longest
+}
+
+func showHabitStatus(database *sql.DB) error {
+	rows, err := database.Query("SELECT name, created FROM habits ORDER BY name")
+	if err != nil {
+		return err
+	}
+	type habit struct {
+		name    string
+		created int64
+	}
+	var habits []habit
+	for rows.Next() {
+		var h habit
+		if err := rows.Scan(&h.name, &h.created); err != nil {
+			rows.Close()
+			return err
+		}
+		habits = append(habits, h)
+	}
+	rows.Close()
+	if len(habits) == 0 {
+		fmt.Println("No habits tracked yet, add one with: notectl habit add <name>")
+		return nil
+	}
+	today := time.Now()
+	fmt.Println("Last 14 days, oldest first")
+	for _, h := range habits {
+		days, err := habitDays(h.name, database)
+		if err != nil {
+			return err
+		}
+		created := time.Unix(h.created, 0)
+		since := time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.Local)
+		for day := range days {
+			if d, err := time.ParseInLocation("2006-01-02", day, time.Local); err == nil && d.Before(since) {
+				since = d
+			}
+		}
+		current, longest := habitStreaks(days, since)
+		var recent []string
+		for i := 13; i >= 0; i-- {
+			if days[today.AddDate(0, 0, -i).Format("2006-01-02")] {
+				recent = append(recent, glyph("✓", "x"))
+			} else {
+				recent = append(recent, glyph("·", "-"))
+			}
+		}
+		fmt.Printf("%-16s %s  streak: %d, longest: %d\n", h.name, strings.Join(recent, ""), current, longest)
+	}
+	return nil
+}
+
+// runHabit dispatches the "habit add", "habit done" and "habit status" subcommands.
+func runHabit(args []string, database *sql.DB) error {
+	usage := "usage: notectl habit <add <name>|done <name>|status>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	if err := createHabitTableIfNotExist(database); err != nil {
+		return err
+	}
+	switch {
+	case args[0] == "add" && len(args) == 2:
+		return addHabit(args[1], database)
+	case args[0] == "done" && len(args) == 2:
+		return completeHabit(args[1], database)
+	case args[0] == "status" && len(args) == 1:
+		return showHabitStatus(database)
+	}
+	return errors.New(usage)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// projectKey is the metadata key holding the directory a note belongs to.
+const projectKey = "project"
+
+// projectDir returns the root of the git repository containing the working
+// directory, or the working directory itself outside a repository.
+func projectDir() (string, error) {
+	if output, err := exec.Command("git", "rev-parse", "--show-toplevel").Output(); err == nil {
+		if root := strings.TrimSpace(string(output)); root != "" {
+			return filepath.Clean(root), nil
+		}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(dir), nil
+}
+
+// addHereFilter restricts filter to the notes saved with -here for the
+// current project.
+func addHereFilter(filter *queryFilter) error {
+	dir, err := projectDir()
+	if err != nil {
+		return err
+	}
+	filter.Add("id IN (SELECT note_id FROM metadata WHERE key = (?) AND value = (?))", projectKey, dir)
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// messageCatalog maps English messages to their translation. Messages are
+// looked up by their English text, so anything missing from a catalog is
+// shown in English.
+type messageCatalog map[string]string
+
+// catalogs are the translations built into notectl. More languages, or
+// overrides for these, are read from <lang>.json files in the directory named
+// by the messages.dir setting, $HOME/.notectl/messages by default.
+var catalogs = map[string]messageCatalog{
+	"de": {
+		"Usage of %s:":          "Verwendung von %s:",
+		"subcommand required":   "Unterbefehl erforderlich",
+		"unknown subcommand %q": "unbekannter Unterbefehl %q",
+		"no notes matched":      "keine passenden Notizen gefunden",
+		"y":                     "j",
+		"yes":                   "ja",
+		"n":                     "n",
+		"Are you sure you want to delete all notes?":                                     "Wirklich alle Notizen löschen?",
+		"Deleting all notes...":                                                          "Alle Notizen werden gelöscht...",
+		"Not deleting notes, everything is still there.":                                 "Es wurde nichts gelöscht, alle Notizen sind noch da.",
+		"confirmation needed but standard input is not a terminal, pass -yes to confirm": "Bestätigung nötig, aber die Standardeingabe ist kein Terminal; mit -yes bestätigen",
+		"Store the text as an attachment with a summary note instead?":                   "Den Text stattdessen als Anhang mit einer Zusammenfassung speichern?",
+		"Tag %q is not in the vocabulary, add it?":                                       "Das Tag %q ist nicht im Vokabular, hinzufügen?",
+		"No notes are tagged %q, use %q instead?":                                        "Keine Notiz hat das Tag %q, stattdessen %q verwenden?",
+		"Configuration file to read, or - for standard input.":                           "Zu lesende Konfigurationsdatei, oder - für die Standardeingabe.",
+		"Answer yes to every confirmation instead of asking.":                            "Alle Rückfragen ohne Nachfrage mit Ja beantworten.",
+		"Shorthand for -yes.":                                                            "Kurzform von -yes.",
+		"Show all notes.":                                                                "Alle Notizen anzeigen.",
+		"Print the notes as a JSON array.":                                               "Die Notizen als JSON-Array ausgeben.",
+		"Delete all stored notes.":                                                       "Alle gespeicherten Notizen löschen.",
+		"Delete note %d, %q?":                                                            "Notiz %d, %q, löschen?",
+		"Delete %d notes tagged %q?":                                                     "%d Notizen mit dem Tag %q löschen?",
+		"Delete %d notes written before %s?":                                             "%d vor dem %s geschriebene Notizen löschen?",
+		"Delete %d notes tagged %q written before %s?":                                   "%d Notizen mit dem Tag %q löschen, die vor dem %s geschrieben wurden?",
+		"Deleted %d notes.":                                                              "%d Notizen gelöscht.",
+		"A comma-delimited list of extra tags.":                                          "Eine kommagetrennte Liste zusätzlicher Tags.",
+	},
+	"fr": {
+		"Usage of %s:":          "Utilisation de %s :",
+		"subcommand required":   "sous-commande requise",
+		"unknown subcommand %q": "sous-commande inconnue %q",
+		"no notes matched":      "aucune note ne correspond",
+		"y":                     "o",
+		"yes":                   "oui",
+		"n":                     "n",
+		"Are you sure you want to delete all notes?":                                     "Voulez-vous vraiment supprimer toutes les notes ?",
+		"Deleting all notes...":                                                          "Suppression de toutes les notes...",
+		"Not deleting notes, everything is still there.":                                 "Rien n'a été supprimé, toutes les notes sont toujours là.",
+		"confirmation needed but standard input is not a terminal, pass -yes to confirm": "confirmation requise mais l'entrée standard n'est pas un terminal, utilisez -yes pour confirmer",
+		"Store the text as an attachment with a summary note instead?":                   "Enregistrer plutôt le texte en pièce jointe avec une note de résumé ?",
+		"Tag %q is not in the vocabulary, add it?":                                       "Le tag %q ne fait pas partie du vocabulaire, l'ajouter ?",
+		"No notes are tagged %q, use %q instead?":                                        "Aucune note n'a le tag %q, utiliser %q à la place ?",
+		"Configuration file to read, or - for standard input.":                           "Fichier de configuration à lire, ou - pour l'entrée standard.",
+		"Answer yes to every confirmation instead of asking.":                            "Répondre oui à toutes les confirmations sans demander.",
+		"Shorthand for -yes.":                                                            "Forme courte de -yes.",
+		"Show all notes.":                                                                "Afficher toutes les notes.",
+		"Print the notes as a JSON array.":                                               "Afficher les notes sous forme de tableau JSON.",
+		"Delete all stored notes.":                                                       "Supprimer toutes les notes enregistrées.",
+		"Delete note %d, %q?":                                                            "Supprimer la note %d, %q ?",
+		"Delete %d notes tagged %q?":                                                     "Supprimer %d notes avec le tag %q ?",
+		"Delete %d notes written before %s?":                                             "Supprimer %d notes écrites avant le %s ?",
+		"Delete %d notes tagged %q written before %s?":                                   "Supprimer %d notes avec le tag %q écrites avant le %s ?",
+		"Deleted %d notes.":                                                              "%d notes supprimées.",
+		"A comma-delimited list of extra tags.":                                          "Une liste de tags supplémentaires séparés par des virgules.",
+	},
+}
+
+var messages struct {
+	once    sync.Once
+	catalog messageCatalog
+}
+
+// messageLanguage returns the language for messages from the
+// messages.lang or locale setting or, failing that, LC_ALL, LC_MESSAGES or
+// LANG.
+func messageLanguage() string {
+	locale := configValue("messages.lang", configValue("locale", ""))
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale != "" {
+			break
+		}
+		locale = os.Getenv(env)
+	}
+	locale = strings.SplitN(strings.SplitN(locale, ".", 2)[0], "@", 2)[0]
+	return strings.ToLower(strings.SplitN(strings.Replace(locale, "-", "_", 1), "_", 2)[0])
+}
+
+// loadCatalog combines the built-in catalog for the message language with a
+// <lang>.json file from the messages directory, which takes precedence.
+func loadCatalog() messageCatalog {
+	language := messageLanguage()
+	catalog := make(messageCatalog)
+	for english, translated := range catalogs[language] {
+		catalog[english] = translated
+	}
+	if language == "" || language == "en" || language == "c" || language == "posix" {
+		return catalog
+	}
+	dir := configValue("messages.dir", filepath.Join(os.Getenv("HOME"), ".notectl", "messages"))
+	data, err := ioutil.ReadFile(filepath.Join(dir, language+".json"))
+	if err != nil {
+		return catalog
+	}
+	var custom messageCatalog
+	if err := json.Unmarshal(data, &custom); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filepath.Join(dir, language+".json"), err)
+		return catalog
+	}
+	for english, translated := range custom {
+		catalog[english] = translated
+	}
+	return catalog
+}
+
+// tr translates a user-facing message, returning it unchanged when there is
+// no translation. Messages with verbs are translated before formatting:
+// fmt.Sprintf(tr("Tag %q is not allowed"), tag).
+func tr(message string) string {
+	messages.once.Do(func() { messages.catalog = loadCatalog() })
+	if translated, ok := messages.catalog[message]; ok && translated != "" {
+		return translated
+	}
+	return message
+}
+
+// newFlagSet returns a flag set for a command or subcommand whose help is
+// shown in the user's language. Parse it with parseFlags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), tr("Usage of %s:")+"\n", name)
+		fs.VisitAll(func(f *flag.Flag) { f.Usage = tr(f.Usage) })
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// flagError is returned by parseFlags when the flags were invalid or help was
+// asked for. The flag set has already printed the problem and its usage.
+type flagError struct {
+	err error
+}
+
+func (e flagError) Error() string {
+	return e.err.Error()
+}
+
+// parseFlags parses args into fs, so a subcommand can return a bad flag as an
+// error rather than exiting before the deferred cleanup has run.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return flagError{err}
+	}
+	return nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Importers for the notes of jrnl, nb and dnote. Their journals, notebooks
+// and books become tags, and every imported note is linked to where it came
+// from in its metadata, so importing again only picks up what is new.
+
+// importedNote is a note read from another tool, with the key identifying it
+// there and the journal, notebook or book it was in.
+type importedNote struct {
+	Source string
+	Book   string
+	note
+}
+
+// saveImportedNotes saves notes read from tool that were not imported before.
+func saveImportedNotes(tool string, notes []importedNote, database *sql.DB) error {
+	key := "import:" + tool
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	imported, before := 0, 0
+	for _, n := range notes {
+		if interrupted(interrupt) {
+			return fmt.Errorf("interrupted after importing %d of %d notes, run the import again to continue", imported, len(notes))
+		}
+		var existing int
+		err := database.QueryRow("SELECT note_id FROM metadata WHERE key = (?) AND value = (?)", key, n.Source).Scan(&existing)
+		if err == nil {
+			before++
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+		if strings.TrimSpace(n.Text) == "" {
+			continue
+		}
+		n.Tags = addTags(tagList{"generic", personSlug(n.Book)}, n.Tags)
+		n.Meta = metaList{key: n.Source, "source": tool}
+		if err := n.Save(database); err != nil {
+			return err
+		}
+		imported++
+	}
+	fmt.Printf("Imported %d of %d %s notes, %d were imported before\n", imported, len(notes), tool, before)
+	return nil
+}
+
+// importFromTool imports the notes of jrnl, nb or dnote found at path. A tag
+// other than "" replaces the journal, notebook or book names as the tag.
+func importFromTool(tool string, path string, tag string, database *sql.DB) error {
+	var notes []importedNote
+	var err error
+	switch tool {
+	case "jrnl":
+		notes, err = readJrnl(path)
+	case "nb":
+		notes, err = readNb(path)
+	case "dnote":
+		notes, err = readDnote(path)
+	default:
+		return fmt.Errorf("unknown import source %q", tool)
+	}
+	if err != nil {
+		return err
+	}
+	if tag != "" {
+		for i := range notes {
+			notes[i].Book = tag
+		}
+	}
+	// Notes are saved oldest first, as they were written.
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Time.Before(notes[j].Time) })
+	return saveImportedNotes(tool, notes, database)
+}
+
+// jrnlTagPattern matches jrnl's @tags, and #tags when configured.
+var jrnlTagPattern = regexp.MustCompile(`(?:^|\s)[@#]([\pL\pN_-]+)`)
+
+// jrnlHeaderPattern matches the line starting a jrnl entry: its date, in the
+// default time formats of the jrnl versions, and title.
+var jrnlHeaderPattern = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2} \d{1,2}:\d{2}(?::\d{2})?(?: [AP]M)?)\]? ?(.*)$`)
+
+var jrnlTimeLayouts = []string{"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02 03:04 PM", "2006-01-02 03:04:05 PM"}
+
+func parseJrnlTime(s string) (time.Time, error) {
+	for _, layout := range jrnlTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized jrnl date %q", s)
+}
+
+// jrnlNote builds a note from a jrnl entry, tagged with its @tags and starred
+// when it was starred.
+func jrnlNote(journal string, when time.Time, title string, body string, starred bool) importedNote {
+	text := strings.TrimSpace(title)
+	if body = strings.TrimSpace(body); body != "" {
+		text += "\n\n" + body
+	}
+	var tags tagList
+	for _, match := range jrnlTagPattern.FindAllStringSubmatch(text, -1) {
+		tags = addTags(tags, tagList{strings.ToLower(match[1])})
+	}
+	if starred {
+		tags = addTags(tags, tagList{"starred"})
+	}
+	sum := sha256.Sum256([]byte(text))
+	source := fmt.Sprintf("%s/%s/%s", journal, when.Format("20060102150405"), hex.EncodeToString(sum[:8]))
+	return importedNote{source, journal, note{Time: when, Text: text, Tags: tags}}
+}
+
+// readJrnl reads a jrnl journal file, or the output of jrnl --export json.
+// The journal is named after the file.
+func readJrnl(path string) ([]importedNote, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	journal := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var notes []importedNote
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var export struct {
+			Entries []struct {
+				Date    string `json:"date"`
+				Time    string `json:"time"`
+				Title   string `json:"title"`
+				Body    string `json:"body"`
+				Starred bool   `json:"starred"`
+			} `json:"entries"`
+		}
+		if err := json.Unmarshal(data, &export); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		for _, e := range export.Entries {
+			when, err := parseJrnlTime(e.Date + " " + e.Time)
+			if err != nil {
+				return nil, err
+			}
+			notes = append(notes, jrnlNote(journal, when, e.Title, e.Body, e.Starred))
+		}
+		return notes, nil
+	}
+
+	var when time.Time
+	var title string
+	var body strings.Builder
+	started := false
+	flush := func() {
+		if started {
+			starred := strings.HasSuffix(title, " *")
+			notes = append(notes, jrnlNote(journal, when, strings.TrimSuffix(title, " *"), body.String(), starred))
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if match := jrnlHeaderPattern.FindStringSubmatch(text); match != nil {
+			if t, err := parseJrnlTime(match[1]); err == nil {
+				flush()
+				when, title, started = t, match[2], true
+				body.Reset()
+				continue
+			}
+		}
+		if !started {
+			if strings.TrimSpace(text) != "" {
+				return nil, fmt.Errorf("%s:%d: expected a jrnl entry starting with its date", path, line)
+			}
+			continue
+		}
+		body.WriteString(text + "\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return notes, nil
+}
+
+// nbNoteExtensions are the nb note files imported; encrypted notes and other
+// files are left out.
+var nbNoteExtensions = map[string]bool{".md": true, ".markdown": true, ".txt": true, ".org": true}
+
+// readNb reads an nb notebook directory, or nb's home directory holding
+// several. Notes are tagged with their #hashtags, and dated by
+// nb's timestamp file names or else their modification time.
+func readNb(path string) ([]importedNote, error) {
+	var notebooks []string
+	if _, err := os.Stat(filepath.Join(path, ".index")); err == nil {
+		notebooks = append(notebooks, path)
+	} else {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				notebooks = append(notebooks, filepath.Join(path, entry.Name()))
+			}
+		}
+		if len(notebooks) == 0 {
+			return nil, fmt.Errorf("%s is neither an nb notebook nor nb's home directory", path)
+		}
+	}
+	var notes []importedNote
+	for _, notebook := range notebooks {
+		name := filepath.Base(notebook)
+		err := filepath.Walk(notebook, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(info.Name(), ".") && file != notebook {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() || !nbNoteExtensions[strings.ToLower(filepath.Ext(file))] {
+				return nil
+			}
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			text := strings.TrimSpace(string(data))
+			if text == "" {
+				return nil
+			}
+			stem := strings.TrimSuffix(info.Name(), filepath.Ext(info.Name()))
+			when, err := time.ParseInLocation("20060102150405", stem, time.Local)
+			if err != nil {
+				when = info.ModTime()
+			}
+			rel, _ := filepath.Rel(notebook, file)
+			notes = append(notes, importedNote{name + "/" + filepath.ToSlash(rel), name, note{Time: when, Text: text, Tags: parseHashtags(text)}})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+// sqliteMagic starts every SQLite database file.
+var sqliteMagic = []byte("SQLite format 3\x00")
+
+// readDnote reads dnote's database, or the JSON file older dnote versions
+// kept notes in.
+func readDnote(path string) ([]importedNote, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var notes []importedNote
+	if !bytes.HasPrefix(data, sqliteMagic) {
+		var books map[string]struct {
+			Name  string `json:"name"`
+			Notes []struct {
+				UUID    string `json:"uuid"`
+				Content string `json:"content"`
+				AddedOn int64  `json:"added_on"`
+			} `json:"notes"`
+		}
+		if err := json.Unmarshal(data, &books); err != nil {
+			return nil, fmt.Errorf("%s is neither a dnote database nor a dnote file: %s", path, err)
+		}
+		for book, b := range books {
+			if b.Name != "" {
+				book = b.Name
+			}
+			for _, n := range b.Notes {
+				notes = append(notes, importedNote{n.UUID, book, note{Time: time.Unix(n.AddedOn, 0), Text: strings.TrimSpace(n.Content)}})
+			}
+		}
+		return notes, nil
+	}
+
+	// dnote's own database is read with the plain SQLite driver, whatever
+	// notectl's database uses.
+	dnote, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	defer dnote.Close()
+	rows, err := dnote.Query("SELECT notes.uuid, books.label, notes.body, notes.added_on FROM notes JOIN books ON books.uuid = notes.book_uuid WHERE NOT notes.deleted AND NOT books.deleted ORDER BY notes.added_on")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var uuid, book, body string
+		var added int64
+		if err := rows.Scan(&uuid, &book, &body, &added); err != nil {
+			return nil, err
+		}
+		notes = append(notes, importedNote{uuid, book, note{Time: time.Unix(0, added), Text: strings.TrimSpace(body)}})
+	}
+	return notes, rows.Err()
+}
+
+// runImport imports notes from a portable file, an archive, Notion or
+// another note taking tool.
+func runImport(args []string) error {
+	importCommand := newFlagSet("import")
+	importFromPtr := importCommand.String("from", "file", "Where to import from: file, notion, jsonl, jrnl, nb or dnote.")
+	importTagPtr := importCommand.String("tag", "", "With -from jrnl, nb or dnote, tag the notes with this instead of their journal, notebook or book.")
+	importDatabasePtr := importCommand.String("database", configValue("notion.database", ""), "With -from notion, the ID of the Notion database to import pages from.")
+	if err := parseFlags(importCommand, args); err != nil {
+		return err
+	}
+	usage := "usage: notectl import <file> | notectl import -from notion -database <id> | notectl import -from jsonl <archive> | notectl import -from jrnl|nb|dnote [-tag tag] <path>"
+	switch *importFromPtr {
+	case "file", "jsonl", "jrnl", "nb", "dnote":
+		if importCommand.NArg() != 1 {
+			return errors.New(usage)
+		}
+	case "notion":
+		if *importDatabasePtr == "" {
+			return errors.New(usage)
+		}
+	default:
+		return fmt.Errorf("unknown import source %q, expected file, notion, jsonl, jrnl, nb or dnote", *importFromPtr)
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	switch *importFromPtr {
+	case "notion":
+		err = importFromNotion(*importDatabasePtr, database)
+	case "file":
+		err = importNote(importCommand.Arg(0), database)
+	case "jsonl":
+		err = importArchive(importCommand.Arg(0), database)
+	default:
+		err = importFromTool(*importFromPtr, importCommand.Arg(0), *importTagPtr, database)
+	}
+	return err
+}
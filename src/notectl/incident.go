@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// incidentTag is added to every incident note.
+const incidentTag = "incident"
+
+// incidentStampFormat starts each timeline line, so entries can be parsed
+// back when the incident is closed.
+const incidentStampFormat = "2006-01-02 15:04:05"
+
+var incidentEntry = regexp.MustCompile(`^- (\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) (.*)$`)
+
+// openIncident returns the given incident, or the most recently started one
+// that is still open when id is zero.
+func openIncident(id int, database *sql.DB) (int, error) {
+	if id > 0 {
+		var status string
+		err := database.QueryRow("SELECT status FROM notes WHERE id = (?) AND "+tagMatchClause, id, incidentTag).Scan(&status)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("note %d is not an incident", id)
+		}
+		if err == nil && status == "done" {
+			err = fmt.Errorf("incident %d is already closed", id)
+		}
+		return id, err
+	}
+	err := database.QueryRow("SELECT id FROM notes WHERE status = 'doing' AND "+tagMatchClause+" ORDER BY timestamp DESC LIMIT 1", incidentTag).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("no open incident, start one with notectl incident start <title>")
+	}
+	return id, err
+}
+
+func startIncident(title string, tags tagList, database *sql.DB) error {
+	start := time.Now()
+	n := note{
+		Time:   start,
+		Text:   fmt.Sprintf("# Incident: %s\n\n## Timeline\n\n- %s Incident started\n", title, start.Format(incidentStampFormat)),
+		Tags:   append(tagList{incidentTag}, tags...),
+		Status: "doing",
+		Title:  title,
+		Meta:   metaList{"title": title, "start": start.Format(time.RFC3339)},
+	}
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	fmt.Printf("Started incident %d: %s\n", n.ID, title)
+	return nil
+}
+
+// logIncident appends a timestamped line to an incident's timeline.
+func logIncident(id int, entry string, database *sql.DB) error {
+	id, err := openIncident(id, database)
+	if err != nil {
+		return err
+	}
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("- %s %s", time.Now().Format(incidentStampFormat), entry)
+	if err := updateNoteText(id, strings.TrimRight(text, "\n")+"\n"+line+"\n", database); err != nil {
+		return err
+	}
+	fmt.Println(line)
+	return nil
+}
+
+// closeIncident ends an incident, records its duration, and prints its
+// timeline with each entry's offset from the start, ready for a postmortem.
+func closeIncident(id int, database *sql.DB) error {
+	id, err := openIncident(id, database)
+	if err != nil {
+		return err
+	}
+	end := time.Now()
+	if err := logIncident(id, "Incident closed", database); err != nil {
+		return err
+	}
+	meta, err := getNoteMeta(id, database)
+	if err != nil {
+		return err
+	}
+	start, err := time.Parse(time.RFC3339, meta["start"])
+	if err != nil {
+		return fmt.Errorf("incident %d has no valid start time", id)
+	}
+	duration := end.Sub(start).Round(time.Second)
+	for key, value := range map[string]string{"end": end.Format(time.RFC3339), "duration": duration.String()} {
+		if err := setNoteMeta(id, key, value, database); err != nil {
+			return err
+		}
+	}
+	if err := setNoteStatus(id, "done", database); err != nil {
+		return err
+	}
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n# Postmortem: %s\n\n", meta["title"])
+	fmt.Printf("- Started: %s\n- Resolved: %s\n- Duration: %s\n\n## Timeline\n\n", formatDateTime(start), formatDateTime(end), duration)
+	fmt.Println("| Time | +Elapsed | Event |")
+	fmt.Println("|------|----------|-------|")
+	for _, line := range strings.Split(text, "\n") {
+		m := incidentEntry.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		at, err := time.ParseInLocation(incidentStampFormat, m[1], time.Local)
+		if err != nil {
+			continue
+		}
+		elapsed := at.Sub(start).Round(time.Second)
+		fmt.Printf("| %s | +%s | %s |\n", at.Format("15:04:05"), elapsed, strings.Replace(m[2], "|", "\\|", -1))
+	}
+	return nil
+}
+
+// runIncident dispatches the "incident start", "incident log" and "incident
+// close" subcommands.
+func runIncident(args []string, database *sql.DB) error {
+	usage := "usage: notectl incident <start [-t tags] <title>|log [-i id] <entry>|close [-i id]>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "start":
+		startCommand := newFlagSet("incident start")
+		var tags tagList
+		startCommand.Var(&tags, "t", "A comma-delimited list of extra tags.")
+		positional, err := parseInterspersed(startCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		title := strings.Join(positional, " ")
+		if title == "" {
+			return errors.New(usage)
+		}
+		return startIncident(title, tags, database)
+	case "log":
+		logCommand := newFlagSet("incident log")
+		idPtr := logCommand.Int("i", 0, "The incident to log to, defaults to the latest open one.")
+		positional, err := parseInterspersed(logCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		entry := strings.Join(positional, " ")
+		if entry == "" {
+			return errors.New(usage)
+		}
+		return logIncident(*idPtr, entry, database)
+	case "close":
+		closeCommand := newFlagSet("incident close")
+		idPtr := closeCommand.Int("i", 0, "The incident to close, defaults to the latest open one.")
+		if err := parseFlags(closeCommand, args[1:]); err != nil {
+			return err
+		}
+		return closeIncident(*idPtr, database)
+	default:
+		return errors.New(usage)
+	}
+}
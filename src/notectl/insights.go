@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// insightsBarWidth is the length of the longest bar in insight charts.
+const insightsBarWidth = 40
+
+// bar draws a horizontal bar scaled against max.
+// Plain output leaves the bars out, the counts say the same.
+func bar(value int, max int) string {
+	if max == 0 || plainOutput {
+		return ""
+	}
+	n := value * insightsBarWidth / max
+	if n == 0 && value > 0 {
+		return "▏"
+	}
+	return strings.Repeat("█", n)
+}
+
+type insightNote struct {
+	Time time.Time
+	Tags tagList
+}
+
+func loadInsightNotes(since time.Time, database *sql.DB) ([]insightNote, error) {
+	rows, err := database.Query("SELECT timestamp, tags FROM notes WHERE timestamp >= (?) ORDER BY timestamp", since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notes []insightNote
+	var timestamp int64
+	var tags string
+	for rows.Next() {
+		if err := rows.Scan(&timestamp, &tags); err != nil {
+			return nil, err
+		}
+		notes = append(notes, insightNote{Time: time.Unix(timestamp, 0), Tags: parseTags(tags)})
+	}
+	return notes, rows.Err()
+}
+
+type tagCount struct {
+	Name  string
+	Count int
+}
+
+// topTags returns the n most used tags, most used first.
+func topTags(notes []insightNote, n int) []tagCount {
+	counts := make(map[string]int)
+	for _, note := range notes {
+		for _, tag := range note.Tags {
+			counts[tag]++
+		}
+	}
+	totals := sortTagCounts(counts)
+	if len(totals) > n {
+		totals = totals[:n]
+	}
+	return totals
+}
+
+// showInsights summarises capture habits over a period: when notes are
+// written, how many per month, and how tag use shifted between the first and
+// second half of the period. Everything is computed from the local database.
+func showInsights(since time.Time, database *sql.DB) error {
+	notes, err := loadInsightNotes(since, database)
+	if err != nil {
+		return err
+	}
+	if len(notes) == 0 {
+		fmt.Println("No notes in this period.")
+		return nil
+	}
+	fmt.Printf("%d notes since %s\n", len(notes), formatLocal(since, "2 Jan 2006"))
+
+	var hours [24]int
+	var weekdays [7]int
+	months := make(map[string]int)
+	var monthKeys []string
+	for _, n := range notes {
+		hours[n.Time.Hour()]++
+		weekdays[n.Time.Weekday()]++
+		key := n.Time.Format("2006-01")
+		if months[key] == 0 {
+			monthKeys = append(monthKeys, key)
+		}
+		months[key]++
+	}
+
+	max := 0
+	for _, c := range hours {
+		if c > max {
+			max = c
+		}
+	}
+	fmt.Println("\nTime of day")
+	for hour, c := range hours {
+		if c > 0 {
+			fmt.Printf("  %02d:00 %5d %s\n", hour, c, bar(c, max))
+		}
+	}
+
+	max = 0
+	for _, c := range weekdays {
+		if c > max {
+			max = c
+		}
+	}
+	names, _ := currentLocaleNames()
+	fmt.Println("\nDay of week")
+	for i := 0; i < 7; i++ {
+		day := (int(weekStart()) + i) % 7
+		fmt.Printf("  %s %5d %s\n", padRight(names.ShortDays[day], 5), weekdays[day], bar(weekdays[day], max))
+	}
+
+	max = 0
+	for _, c := range months {
+		if c > max {
+			max = c
+		}
+	}
+	fmt.Println("\nNotes per month")
+	for _, key := range monthKeys {
+		t, _ := time.Parse("2006-01", key)
+		fmt.Printf("  %s %5d %s\n", formatLocal(t, "Jan 2006"), months[key], bar(months[key], max))
+	}
+
+	middle := since.Add(time.Since(since) / 2)
+	split := sort.Search(len(notes), func(i int) bool { return !notes[i].Time.Before(middle) })
+	earlier, later := topTags(notes[:split], 10), topTags(notes[split:], 10)
+	rank := func(totals []tagCount) map[string]int {
+		r := make(map[string]int)
+		for i, t := range totals {
+			r[t.Name] = i + 1
+		}
+		return r
+	}
+	earlierRank := rank(earlier)
+	fmt.Printf("\nTag drift, top tags since %s compared with before\n", formatLocal(middle, "2 Jan 2006"))
+	for i, t := range later {
+		change := "new"
+		if r, ok := earlierRank[t.Name]; ok {
+			switch {
+			case r > i+1:
+				change = fmt.Sprintf("up from #%d", r)
+			case r < i+1:
+				change = fmt.Sprintf("down from #%d", r)
+			default:
+				change = "same"
+			}
+		}
+		fmt.Printf("  #%-2d %-20s %5d notes (%s)\n", i+1, t.Name, t.Count, change)
+	}
+	laterRank := rank(later)
+	for _, t := range earlier {
+		if _, ok := laterRank[t.Name]; !ok {
+			fmt.Printf("  dropped: %s (%d notes before)\n", t.Name, t.Count)
+		}
+	}
+	return nil
+}
+
+// runInsights summarises writing habits over a period.
+func runInsights(args []string) error {
+	insightsCommand := newFlagSet("insights")
+	insightsSincePtr := insightsCommand.String("since", "1y", "Period to analyse, e.g. 90d, 6m or 1y.")
+	if err := parseFlags(insightsCommand, args); err != nil {
+		return err
+	}
+	since, err := parseSince(*insightsSincePtr)
+	if err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return showInsights(since, database)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyInterrupt returns a channel receiving SIGINT and SIGTERM and a
+// function restoring the default handling. Long operations check it between
+// steps so an interrupt stops them at a consistent point, rolling back or
+// keeping what was completed, instead of killing them halfway through a
+// write.
+func notifyInterrupt() (chan os.Signal, func()) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	return interrupt, func() { signal.Stop(interrupt) }
+}
+
+// interrupted reports, without blocking, whether a signal has arrived on
+// interrupt.
+func interrupted(interrupt chan os.Signal) bool {
+	select {
+	case <-interrupt:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// journalEntry is one line of the journal, recording a single change to the
+// notes. Which fields are set depends on Op.
+type journalEntry struct {
+	Op     string     `json:"op"`
+	At     time.Time  `json:"at"`
+	ID     int        `json:"id,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+	Text   *string    `json:"text,omitempty"`
+	Tags   tagList    `json:"tags,omitempty"`
+	Status *string    `json:"status,omitempty"`
+	UUID   string     `json:"uuid,omitempty"`
+	Title  string     `json:"title,omitempty"`
+	Key    string     `json:"key,omitempty"`
+	Value  *string    `json:"value,omitempty"`
+}
+
+// Journal operations.
+const (
+	journalCreate     = "create"
+	journalText       = "text"
+	journalStatus     = "status"
+	journalTags       = "tags"
+	journalMeta       = "meta"
+	journalMetaDelete = "meta-delete"
+	journalDelete     = "delete"
+	journalDeleteAll  = "delete-all"
+)
+
+// replaying is set while the journal is replayed, so replayed changes are not
+// journaled again.
+var replaying bool
+
+// journalPending holds the entries of transactions that have not been
+// committed yet, so rolled back changes never reach the journal.
+var journalPending = make(map[*sql.Tx][]journalEntry)
+
+// journalPath returns the journal file from the journal setting, or "" when
+// journaling is off.
+func journalPath() string {
+	if replaying {
+		return ""
+	}
+	return configValue("journal", "")
+}
+
+// journal records a change after it was written to the database. Changes
+// made in a transaction are held until commitJournaled. Attachments are not
+// journaled.
+func journal(database execer, entry journalEntry) error {
+	if journalPath() == "" {
+		return nil
+	}
+	entry.At = time.Now()
+	if tx, ok := database.(*sql.Tx); ok {
+		journalPending[tx] = append(journalPending[tx], entry)
+		return nil
+	}
+	return appendJournal(entry)
+}
+
+// appendJournal appends entries to the journal file, one JSON object per
+// line, and syncs it to disk.
+func appendJournal(entries ...journalEntry) error {
+	file, err := os.OpenFile(journalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("journal: %s", err)
+	}
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return fmt.Errorf("journal: %s", err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("journal: %s", err)
+	}
+	return file.Close()
+}
+
+// commitJournaled commits a transaction and journals the changes made in it.
+func commitJournaled(tx *sql.Tx) error {
+	entries := journalPending[tx]
+	delete(journalPending, tx)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return appendJournal(entries...)
+}
+
+// rollbackJournaled rolls back a transaction and drops its journal entries.
+func rollbackJournaled(tx *sql.Tx) error {
+	delete(journalPending, tx)
+	return tx.Rollback()
+}
+
+func stringPtr(s string) *string { return &s }
+
+// replayJournal rebuilds a database from a journal by applying its changes in
+// order. The database is written to a new file, which can replace the
+// configured database once checked.
+func replayJournal(path string, output string) error {
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf("%s already exists, replay into a new file", output)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	database, err := connectToDatabase(output)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	if err := createTableIfNotExist(database); err != nil {
+		return err
+	}
+	replaying = true
+	defer func() { replaying = false }()
+
+	scanner := bufio.NewScanner(file)
+	// Lines hold whole notes, which can be far longer than the default limit.
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	line, applied := 0, 0
+	for scanner.Scan() {
+		line++
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		if err := applyJournalEntry(entry, database); err != nil {
+			return fmt.Errorf("%s:%d: %s", path, line, err)
+		}
+		applied++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	fmt.Printf("Replayed %d changes from %s into %s\n", applied, path, output)
+	return nil
+}
+
+func applyJournalEntry(entry journalEntry, database *sql.DB) error {
+	switch entry.Op {
+	case journalCreate:
+		if entry.Time == nil || entry.Text == nil || entry.Status == nil {
+			return errors.New("create without time, text or status")
+		}
+		n := note{ID: entry.ID, Time: *entry.Time, Text: *entry.Text, Tags: entry.Tags, Status: *entry.Status, UUID: entry.UUID, Title: entry.Title}
+		return n.Save(database)
+	case journalText:
+		if entry.Text == nil {
+			return errors.New("text change without text")
+		}
+		return updateNoteText(entry.ID, *entry.Text, database)
+	case journalStatus:
+		if entry.Status == nil {
+			return errors.New("status change without status")
+		}
+		return setNoteStatus(entry.ID, *entry.Status, database)
+	case journalTags:
+		return setNoteTags(entry.ID, entry.Tags, database)
+	case journalMeta:
+		if entry.Value == nil {
+			return errors.New("metadata change without value")
+		}
+		return setNoteMeta(entry.ID, entry.Key, *entry.Value, database)
+	case journalMetaDelete:
+		return deleteNoteMeta(entry.ID, entry.Key, database)
+	case journalDelete:
+		return deleteNote(entry.ID, database)
+	case journalDeleteAll:
+		return dropAllNotes(database)
+	}
+	return fmt.Errorf("unknown journal operation %q", entry.Op)
+}
+
+// runReplay rebuilds the notes into a new database from the journal.
+func runReplay(args []string) error {
+	replayCommand := newFlagSet("replay")
+	replayJournalPtr := replayCommand.String("journal", configValue("journal", ""), "The journal file to replay.")
+	replayOutputPtr := replayCommand.String("o", "", "The new database file to rebuild the notes into.")
+	if err := parseFlags(replayCommand, args); err != nil {
+		return err
+	}
+	if *replayJournalPtr == "" || *replayOutputPtr == "" {
+		return errors.New("usage: notectl replay -o <new database> [-journal file]")
+	}
+	return replayJournal(*replayJournalPtr, *replayOutputPtr)
+}
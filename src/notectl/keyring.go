@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service name secrets are stored under in the OS
+// keyring. Each secret's account is the configuration key it replaces.
+const keyringService = "notectl"
+
+// secretKeys are the settings that hold secrets and can live in the keyring.
+var secretKeys = []string{"passphrase", "db.key", "notion.token", "bot.telegram.token", "mailgate.password", "attachments.s3.secret_key"}
+
+// windowsVault loads the Windows Credential Locker, which backs the Windows
+// credential manager, into a PowerShell session.
+const windowsVault = "$v = New-Object Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime; "
+
+// keyringCommand returns the command that stores, looks up or removes a
+// secret in the OS keyring: the keychain on macOS, the credential manager on
+// Windows and the secret service (via secret-tool) elsewhere. Secrets being
+// stored are passed on stdin so they never show up in the process list.
+func keyringCommand(action string, key string, secret string) (*exec.Cmd, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		switch action {
+		case "store":
+			// security only takes the password as an argument, so the command
+			// is fed to its interactive mode instead.
+			quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+			cmd := exec.Command("security", "-i")
+			cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a \"%s\" -w \"%s\"\n", keyringService, quote.Replace(key), quote.Replace(secret)))
+			return cmd, nil
+		case "lookup":
+			return exec.Command("security", "find-generic-password", "-s", keyringService, "-a", key, "-w"), nil
+		case "clear":
+			return exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", key), nil
+		}
+	case "windows":
+		scripts := map[string]string{
+			"store":  "$v.Add((New-Object Windows.Security.Credentials.PasswordCredential($env:NOTECTL_KEYRING_SERVICE, $env:NOTECTL_KEYRING_KEY, [Console]::In.ReadLine())))",
+			"lookup": "$c = $v.Retrieve($env:NOTECTL_KEYRING_SERVICE, $env:NOTECTL_KEYRING_KEY); $c.RetrievePassword(); $c.Password",
+			"clear":  "$v.Remove($v.Retrieve($env:NOTECTL_KEYRING_SERVICE, $env:NOTECTL_KEYRING_KEY))",
+		}
+		cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsVault+scripts[action])
+		cmd.Env = append(os.Environ(), "NOTECTL_KEYRING_SERVICE="+keyringService, "NOTECTL_KEYRING_KEY="+key)
+		cmd.Stdin = strings.NewReader(secret + "\n")
+		return cmd, nil
+	default:
+		path, err := exec.LookPath("secret-tool")
+		if err != nil {
+			return nil, errors.New("no keyring tool found, install secret-tool (libsecret)")
+		}
+		switch action {
+		case "store":
+			cmd := exec.Command(path, "store", "--label", keyringService+" "+key, "service", keyringService, "key", key)
+			cmd.Stdin = strings.NewReader(secret)
+			return cmd, nil
+		case "lookup":
+			return exec.Command(path, "lookup", "service", keyringService, "key", key), nil
+		case "clear":
+			return exec.Command(path, "clear", "service", keyringService, "key", key), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown keyring action %q", action)
+}
+
+func keyringStore(key string, secret string) error {
+	cmd, err := keyringCommand("store", key, secret)
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("storing %s in the keyring: %s %s", key, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// keyringLookup returns a secret from the keyring, or an empty string when it
+// is not stored there or no keyring is available.
+func keyringLookup(key string) string {
+	cmd, err := keyringCommand("lookup", key, "")
+	if err != nil {
+		return ""
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(output), "\r\n")
+}
+
+func keyringClear(key string) error {
+	cmd, err := keyringCommand("clear", key, "")
+	if err != nil {
+		return err
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("removing %s from the keyring: %s %s", key, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// secretValue returns a secret setting from the environment or configuration
+// file like configValue, falling back to the OS keyring.
+func secretValue(key string) string {
+	if value := configValue(key, ""); value != "" {
+		return value
+	}
+	return keyringLookup(key)
+}
+
+func knownSecret(key string) bool {
+	for _, k := range secretKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// storeSecret reads a secret from the terminal without echo, saves it in the
+// keyring and removes any plaintext copy from the configuration file.
+func storeSecret(key string, force bool) error {
+	if !knownSecret(key) && !force {
+		return fmt.Errorf("%s is not a known secret (%s), use -force to store it anyway", key, strings.Join(secretKeys, ", "))
+	}
+	secret, err := promptSecret(key + ": ")
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return errors.New("no secret given")
+	}
+	if err := keyringStore(key, secret); err != nil {
+		return err
+	}
+	fmt.Printf("Stored %s in the keyring\n", key)
+	if _, ok := config[key]; ok {
+		if err := setConfigValue(key, ""); err != nil {
+			return err
+		}
+		fmt.Printf("Removed the plaintext %s from %s\n", key, configFile)
+	}
+	return nil
+}
+
+// showSecretStatus lists where each known secret is read from, without
+// printing the secrets themselves.
+func showSecretStatus() {
+	for _, key := range secretKeys {
+		source := "not set"
+		switch {
+		case os.Getenv(configEnv(key)) != "":
+			source = "environment (" + configEnv(key) + ")"
+		case config[key] != "":
+			source = "config file (plaintext)"
+		case keyringLookup(key) != "":
+			source = "keyring"
+		}
+		fmt.Printf("%s %s\n", padRight(key, 20), source)
+	}
+}
+
+// runAuth dispatches the "auth set", "auth rm" and "auth status" subcommands.
+func runAuth(args []string) error {
+	usage := "usage: notectl auth <set <key> [-force]|rm <key>|status>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "set":
+		setCommand := newFlagSet("auth set")
+		forcePtr := setCommand.Bool("force", false, "Store a key that notectl does not know as a secret.")
+		keys, err := parseInterspersed(setCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(keys) != 1 {
+			return errors.New(usage)
+		}
+		return storeSecret(keys[0], *forcePtr)
+	case "rm":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		if err := keyringClear(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s from the keyring\n", args[1])
+		return nil
+	case "status":
+		showSecretStatus()
+		return nil
+	}
+	return errors.New(usage)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// langKey is the metadata key holding a note's detected language.
+const langKey = "lang"
+
+// langMinWords is the fewest stopword hits needed before a language is
+// trusted; shorter notes are left undetected.
+const langMinWords = 2
+
+// stopwords are frequent short words that tell languages apart, keyed by
+// ISO 639-1 code.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "was", "of", "to", "in", "that", "it", "for", "with", "on", "this", "not", "be", "have", "you", "at", "what"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ich", "ein", "eine", "zu", "mit", "den", "von", "sich", "auf", "auch", "es", "dem", "wir", "noch"},
+	"fr": {"le", "la", "les", "et", "est", "une", "des", "du", "que", "pour", "pas", "dans", "qui", "sur", "avec", "ce", "je", "nous", "sont", "mais"},
+	"es": {"el", "los", "las", "y", "es", "una", "del", "que", "para", "por", "con", "no", "se", "su", "al", "lo", "como", "pero", "muy", "está"},
+	"it": {"il", "lo", "gli", "e", "è", "una", "della", "che", "per", "non", "con", "sono", "del", "di", "ma", "come", "anche", "questo", "nel", "alla"},
+	"nl": {"de", "het", "een", "en", "is", "van", "dat", "niet", "ik", "op", "te", "zijn", "met", "voor", "er", "maar", "ook", "wat", "nog", "bij"},
+	"pt": {"o", "os", "as", "e", "é", "um", "uma", "do", "da", "que", "para", "com", "não", "se", "por", "mas", "como", "mais", "muito", "está"},
+}
+
+// stopwordLangs maps each stopword to the languages it belongs to.
+var stopwordLangs = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, word := range words {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// detectLanguage guesses the language of text by counting stopwords, returning
+// an empty string when there is too little evidence or a tie.
+func detectLanguage(text string) string {
+	scores := make(map[string]int)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		for _, lang := range stopwordLangs[word] {
+			scores[lang]++
+		}
+	}
+	best, bestScore, tie := "", 0, false
+	for lang, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = lang, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if bestScore < langMinWords || tie {
+		return ""
+	}
+	return best
+}
+
+// updateLanguage stores the detected language of a note's text, clearing it
+// when the language cannot be told.
+func updateLanguage(id int, text string, database execer) error {
+	if lang := detectLanguage(text); lang != "" {
+		return setNoteMeta(id, langKey, lang, database)
+	}
+	return deleteNoteMeta(id, langKey, database)
+}
+
+// detectMissingLanguages detects the language of notes saved before
+// detection existed.
+func detectMissingLanguages(database *sql.DB) error {
+	rows, err := database.Query("SELECT id, "+notes.TextColumn+" FROM notes WHERE id NOT IN (SELECT note_id FROM metadata WHERE key = (?))", langKey)
+	if err != nil {
+		return err
+	}
+	found := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var text noteText
+		if err := rows.Scan(&id, &text); err != nil {
+			rows.Close()
+			return err
+		}
+		if lang := detectLanguage(string(text)); lang != "" {
+			found[id] = lang
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, lang := range found {
+		if err := setNoteMeta(id, langKey, lang, database); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLanguageFilter restricts filter to the notes written in the given
+// language, detecting the language of notes saved before it was recorded.
+func addLanguageFilter(filter *queryFilter, lang string, database *sql.DB) error {
+	if err := detectMissingLanguages(database); err != nil {
+		return err
+	}
+	filter.Add("id IN (SELECT note_id FROM metadata WHERE key = (?) AND value = (?))", langKey, strings.ToLower(lang))
+	return nil
+}
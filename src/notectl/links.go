@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// urlPattern finds http and https links in note text.
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// maxArchiveSize caps how much of a single page is archived.
+const maxArchiveSize = 20 << 20
+
+var headPattern = regexp.MustCompile(`(?i)<head[^>]*>`)
+
+func noteURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?")
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// archiveName derives an attachment file name for a snapshot of link.
+func archiveName(link string, contentType string) string {
+	u, _ := url.Parse(link)
+	base := strings.Trim(path.Base(u.Path), "/.")
+	if base == "" {
+		base = "index"
+	}
+	name := u.Host + "-" + base
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/html":
+		if !strings.HasSuffix(name, ".html") && !strings.HasSuffix(name, ".htm") {
+			name += ".html"
+		}
+	case "application/pdf":
+		if !strings.HasSuffix(name, ".pdf") {
+			name += ".pdf"
+		}
+	}
+	return name
+}
+
+// fetchSnapshot downloads a page. HTML gets a <base> element so relative
+// links and assets still resolve against the original site when opened later.
+func fetchSnapshot(link string) ([]byte, string, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(link)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s: %s", link, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxArchiveSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxArchiveSize {
+		return nil, "", fmt.Errorf("%s is larger than %d bytes", link, maxArchiveSize)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	if strings.HasPrefix(contentType, "text/html") {
+		base := fmt.Sprintf(`<base href="%s">`, resp.Request.URL.String())
+		if loc := headPattern.FindIndex(data); loc != nil {
+			data = append(data[:loc[1]:loc[1]], append([]byte(base), data[loc[1]:]...)...)
+		}
+	}
+	return data, contentType, nil
+}
+
+// archiveLinks stores a snapshot of every URL in a note as an attachment.
+// Failures are reported per link and do not stop the remaining downloads.
+func archiveLinks(id int, database *sql.DB) error {
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	urls := noteURLs(text)
+	if len(urls) == 0 {
+		fmt.Printf("Note %d has no links to archive.\n", id)
+		return nil
+	}
+	failed := 0
+	for _, link := range urls {
+		data, contentType, err := fetchSnapshot(link)
+		if err != nil {
+			fmt.Printf("Could not archive %s: %v\n", link, err)
+			failed++
+			continue
+		}
+		attachmentID, err := addAttachment(id, archiveName(link, contentType), contentType, data, database)
+		if err != nil {
+			return err
+		}
+		if err := setNoteMeta(id, "archived:"+link, fmt.Sprintf("%d", attachmentID), database); err != nil {
+			return err
+		}
+		fmt.Printf("Archived %s as attachment %d (%d bytes)\n", link, attachmentID, len(data))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d links could not be archived", failed, len(urls))
+	}
+	return nil
+}
+
+func listLinks(id int, database *sql.DB) error {
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	meta, err := getNoteMeta(id, database)
+	if err != nil {
+		return err
+	}
+	for _, link := range noteURLs(text) {
+		if attachmentID, ok := meta["archived:"+link]; ok {
+			fmt.Printf("%s (archived as attachment %s)\n", link, attachmentID)
+		} else {
+			fmt.Println(link)
+		}
+	}
+	return nil
+}
+
+// runLinks dispatches the "links list" and "links archive" subcommands.
+func runLinks(args []string, database *sql.DB) error {
+	usage := "usage: notectl links <list|archive> -i <id>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	linksCommand := newFlagSet("links " + args[0])
+	idPtr := linksCommand.Int("i", -1, "ID of the note whose links to use.")
+	if err := parseFlags(linksCommand, args[1:]); err != nil {
+		return err
+	}
+	if *idPtr == -1 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "list":
+		return listLinks(*idPtr, database)
+	case "archive":
+		return archiveLinks(*idPtr, database)
+	}
+	return errors.New(usage)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// localeNames holds month and weekday names, January and Sunday first.
+type localeNames struct {
+	Months      []string
+	ShortMonths []string
+	Days        []string
+	ShortDays   []string
+	DateTime    string
+	Date        string
+}
+
+var locales = map[string]localeNames{
+	"en": {
+		Months:      []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		ShortMonths: []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		Days:        []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		ShortDays:   []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		DateTime:    "Mon 2 Jan 2006 15:04",
+		Date:        "Monday, 2 January 2006",
+	},
+	"de": {
+		Months:      []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths: []string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		Days:        []string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		ShortDays:   []string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+		DateTime:    "Mon 02.01.2006 15:04",
+		Date:        "Monday, 2. January 2006",
+	},
+	"fr": {
+		Months:      []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		ShortMonths: []string{"janv", "févr", "mars", "avr", "mai", "juin", "juil", "août", "sept", "oct", "nov", "déc"},
+		Days:        []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		ShortDays:   []string{"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+		DateTime:    "Mon 02/01/2006 15:04",
+		Date:        "Monday 2 January 2006",
+	},
+	"es": {
+		Months:      []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		ShortMonths: []string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sep", "oct", "nov", "dic"},
+		Days:        []string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		ShortDays:   []string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		DateTime:    "Mon 02/01/2006 15:04",
+		Date:        "Monday, 2 de January de 2006",
+	},
+	"it": {
+		Months:      []string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		ShortMonths: []string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		Days:        []string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		ShortDays:   []string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+		DateTime:    "Mon 02/01/2006 15:04",
+		Date:        "Monday 2 January 2006",
+	},
+	"nl": {
+		Months:      []string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		ShortMonths: []string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		Days:        []string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		ShortDays:   []string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+		DateTime:    "Mon 02-01-2006 15:04",
+		Date:        "Monday 2 January 2006",
+	},
+	"pt": {
+		Months:      []string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		ShortMonths: []string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		Days:        []string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		ShortDays:   []string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+		DateTime:    "Mon 02/01/2006 15:04",
+		Date:        "Monday, 2 de January de 2006",
+	},
+}
+
+// sundayRegions start their week on Sunday, everywhere else starts on Monday.
+var sundayRegions = map[string]bool{"US": true, "CA": true, "MX": true, "BR": true, "JP": true, "IL": true, "PH": true, "ZA": true}
+
+// currentLocale returns the language and region from the locale config
+// setting or, failing that, LC_ALL, LC_TIME or LANG. Both are empty for the
+// C and POSIX locales.
+func currentLocale() (string, string) {
+	locale := configValue("locale", "")
+	for _, env := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if locale != "" {
+			break
+		}
+		locale = os.Getenv(env)
+	}
+	locale = strings.SplitN(strings.SplitN(locale, ".", 2)[0], "@", 2)[0]
+	if locale == "C" || locale == "POSIX" {
+		return "", ""
+	}
+	parts := strings.SplitN(strings.Replace(locale, "-", "_", 1), "_", 2)
+	language := strings.ToLower(parts[0])
+	region := ""
+	if len(parts) == 2 {
+		region = strings.ToUpper(parts[1])
+	}
+	return language, region
+}
+
+func currentLocaleNames() (localeNames, bool) {
+	language, _ := currentLocale()
+	names, ok := locales[language]
+	if !ok {
+		return locales["en"], false
+	}
+	return names, true
+}
+
+// weekStart returns the first day of the week from the week.start setting
+// or the locale's region.
+func weekStart() time.Weekday {
+	switch strings.ToLower(configValue("week.start", "")) {
+	case "sunday":
+		return time.Sunday
+	case "monday":
+		return time.Monday
+	case "saturday":
+		return time.Saturday
+	}
+	if _, region := currentLocale(); sundayRegions[region] {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+// startOfWeek returns midnight on the first day of t's week.
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) - int(weekStart()) + 7) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// localizeLayout swaps month and weekday names in a layout for placeholders
+// that survive time.Format, so they can be replaced with localized names.
+var localizeLayout = strings.NewReplacer("January", "\x01", "Jan", "\x02", "Monday", "\x03", "Mon", "\x04")
+
+// formatLocal formats t with a Go layout, rendering month and weekday names
+// in the current locale.
+func formatLocal(t time.Time, layout string) string {
+	names, _ := currentLocaleNames()
+	formatted := t.Format(localizeLayout.Replace(layout))
+	return strings.NewReplacer(
+		"\x01", names.Months[t.Month()-1],
+		"\x02", names.ShortMonths[t.Month()-1],
+		"\x03", names.Days[t.Weekday()],
+		"\x04", names.ShortDays[t.Weekday()],
+	).Replace(formatted)
+}
+
+// formatDateTime renders a note timestamp using the date.format setting, the
+// locale's conventions, or RFC 822 when no locale is known.
+func formatDateTime(t time.Time) string {
+	if layout := configValue("date.format", ""); layout != "" {
+		return formatLocal(t, layout)
+	}
+	language, region := currentLocale()
+	if language == "en" && region == "US" {
+		return formatLocal(t, "Mon Jan 2 2006 3:04 PM")
+	}
+	if names, ok := currentLocaleNames(); ok {
+		return formatLocal(t, names.DateTime)
+	}
+	return t.Format(time.RFC822)
+}
+
+// formatDate renders a day heading, e.g. "Monday, 2 January 2006".
+func formatDate(t time.Time) string {
+	if layout := configValue("date.day_format", ""); layout != "" {
+		return formatLocal(t, layout)
+	}
+	language, region := currentLocale()
+	if language == "en" && region == "US" {
+		return formatLocal(t, "Monday, January 2, 2006")
+	}
+	names, _ := currentLocaleNames()
+	return formatLocal(t, names.Date)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapConn is a minimal IMAP4rev1 client, just enough to fetch and file
+// messages from one mailbox.
+type imapConn struct {
+	conn   *tls.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// imapResponse is one untagged response line, with any literals it carried.
+type imapResponse struct {
+	Line     string
+	Literals [][]byte
+}
+
+var imapLiteral = regexp.MustCompile(`\{(\d+)\}$`)
+
+func dialIMAP(server string) (*imapConn, error) {
+	if !strings.Contains(server, ":") {
+		server += ":993"
+	}
+	conn, err := tls.Dial("tcp", server, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &imapConn{conn: conn, reader: bufio.NewReader(conn)}
+	if _, err := c.readLine(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// readResponse reads one response, following any literals into the lines
+// that continue after them.
+func (c *imapConn) readResponse() (imapResponse, error) {
+	var response imapResponse
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return response, err
+		}
+		response.Line += line
+		match := imapLiteral.FindStringSubmatch(line)
+		if match == nil {
+			return response, nil
+		}
+		size, _ := strconv.Atoi(match[1])
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, literal); err != nil {
+			return response, err
+		}
+		response.Literals = append(response.Literals, literal)
+	}
+}
+
+// command sends a command and returns its untagged responses, failing unless
+// the server answers OK.
+func (c *imapConn) command(format string, args ...interface{}) ([]imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	c.conn.SetDeadline(time.Now().Add(2 * time.Minute))
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+	var untagged []imapResponse
+	for {
+		response, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(response.Line, tag+" ") {
+			status := strings.TrimPrefix(response.Line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("imap: %s", status)
+			}
+			return untagged, nil
+		}
+		untagged = append(untagged, response)
+	}
+}
+
+func (c *imapConn) close() {
+	c.command("LOGOUT")
+	c.conn.Close()
+}
+
+// imapQuote quotes s as an IMAP quoted string.
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// plusTags returns the tags encoded in +addressing, so mail sent to
+// notes+work+ideas@example.com is tagged work and ideas.
+func plusTags(header mail.Header) tagList {
+	var tags tagList
+	for _, field := range []string{"To", "Cc", "Delivered-To"} {
+		addresses, err := header.AddressList(field)
+		if err != nil {
+			continue
+		}
+		for _, address := range addresses {
+			local := strings.SplitN(address.Address, "@", 2)[0]
+			parts := strings.Split(local, "+")
+			for _, tag := range parts[1:] {
+				if tag != "" {
+					tags = append(tags, strings.ToLower(tag))
+				}
+			}
+		}
+	}
+	return tags
+}
+
+// decodeTransfer undoes a part's Content-Transfer-Encoding.
+func decodeTransfer(body io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	}
+	return body
+}
+
+// plainBody returns the first text/plain part of a message.
+func plainBody(contentType string, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return "", errors.New("message has no plain text part")
+			}
+			if err != nil {
+				return "", err
+			}
+			text, err := plainBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err == nil {
+				return text, nil
+			}
+		}
+	}
+	if mediaType != "text/plain" {
+		return "", fmt.Errorf("unsupported content type %s", mediaType)
+	}
+	text, err := ioutil.ReadAll(decodeTransfer(body, encoding))
+	return strings.Replace(string(text), "\r\n", "\n", -1), err
+}
+
+// mailToNote converts a raw RFC 5322 message into a note titled by its
+// subject.
+func mailToNote(raw []byte) (note, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return note{}, err
+	}
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		subject = message.Header.Get("Subject")
+	}
+	body, err := plainBody(message.Header.Get("Content-Type"), message.Header.Get("Content-Transfer-Encoding"), message.Body)
+	if err != nil {
+		return note{}, err
+	}
+	text := strings.TrimSpace(body)
+	if subject != "" {
+		text = "# " + subject + "\n\n" + text
+	}
+	created, err := message.Header.Date()
+	if err != nil {
+		created = time.Now()
+	}
+	meta := metaList{"source": "mail"}
+	if from := message.Header.Get("From"); from != "" {
+		meta["from"] = from
+	}
+	return note{
+		Time: created,
+		Text: text,
+		Tags: addTags(tagList{"generic"}, plusTags(message.Header)),
+		Meta: meta,
+	}, nil
+}
+
+type mailgate struct {
+	Server   string
+	User     string
+	Password string
+	Mailbox  string
+	Archive  string
+}
+
+// poll converts every unseen message in the mailbox into a note, then moves
+// it to the archive mailbox or deletes it.
+func (gate mailgate) poll(database *sql.DB) error {
+	c, err := dialIMAP(gate.Server)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	if _, err := c.command("LOGIN %s %s", imapQuote(gate.User), imapQuote(gate.Password)); err != nil {
+		return err
+	}
+	if _, err := c.command("SELECT %s", imapQuote(gate.Mailbox)); err != nil {
+		return err
+	}
+	responses, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return err
+	}
+	var uids []string
+	for _, response := range responses {
+		if strings.HasPrefix(response.Line, "* SEARCH") {
+			uids = append(uids, strings.Fields(strings.TrimPrefix(response.Line, "* SEARCH"))...)
+		}
+	}
+	processed := 0
+	for _, uid := range uids {
+		responses, err := c.command("UID FETCH %s (BODY.PEEK[])", uid)
+		if err != nil {
+			return err
+		}
+		if len(responses) == 0 || len(responses[0].Literals) == 0 {
+			continue
+		}
+		n, err := mailToNote(responses[0].Literals[0])
+		if err != nil {
+			fmt.Printf("Skipping message %s: %s\n", uid, err)
+			continue
+		}
+		if err := n.Save(database); err != nil {
+			return err
+		}
+		fmt.Printf("Saved note %d from message %s\n", n.ID, uid)
+		if gate.Archive != "" {
+			if _, err := c.command("UID COPY %s %s", uid, imapQuote(gate.Archive)); err != nil {
+				return err
+			}
+		}
+		if _, err := c.command(`UID STORE %s +FLAGS.SILENT (\Seen \Deleted)`, uid); err != nil {
+			return err
+		}
+		processed++
+	}
+	if processed > 0 {
+		_, err = c.command("EXPUNGE")
+	}
+	return err
+}
+
+// run polls the mailbox every interval until interrupted, or once when
+// interval is zero.
+func (gate mailgate) run(interval time.Duration, database *sql.DB) error {
+	if interval == 0 {
+		return gate.poll(database)
+	}
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	fmt.Printf("Polling %s every %s, Ctrl-C to stop\n", gate.Mailbox, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := gate.poll(database); err != nil {
+			fmt.Println(err)
+		}
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runMailgate saves mail arriving in a mailbox as notes.
+func runMailgate(args []string) error {
+	mailgateCommand := newFlagSet("mailgate")
+	mailgateServerPtr := mailgateCommand.String("server", configValue("mailgate.server", ""), "IMAP server as host[:port], using TLS.")
+	mailgateUserPtr := mailgateCommand.String("user", configValue("mailgate.user", ""), "IMAP user name.")
+	mailgateMailboxPtr := mailgateCommand.String("mailbox", configValue("mailgate.mailbox", "INBOX"), "Mailbox to read new messages from.")
+	mailgateArchivePtr := mailgateCommand.String("archive", configValue("mailgate.archive", ""), "Mailbox to move processed messages to. Processed messages are deleted when empty.")
+	mailgateIntervalPtr := mailgateCommand.Duration("interval", 5*time.Minute, "How often to poll the mailbox, or 0 to poll once and exit.")
+	if err := parseFlags(mailgateCommand, args); err != nil {
+		return err
+	}
+	gate := mailgate{
+		Server:   *mailgateServerPtr,
+		User:     *mailgateUserPtr,
+		Password: secretValue("mailgate.password"),
+		Mailbox:  *mailgateMailboxPtr,
+		Archive:  *mailgateArchivePtr,
+	}
+	if gate.Server == "" || gate.User == "" || gate.Password == "" {
+		return errors.New("mailgate needs -server, -user and a password in mailgate.password or NOTECTL_MAILGATE_PASSWORD")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return gate.run(*mailgateIntervalPtr, database)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// The Markdown renderer covers what notes commonly use: headings, paragraphs,
+// lists and task lists, block quotes, fenced code, pipe tables, and inline
+// code, emphasis and links. Anything else is shown as escaped text.
+
+var (
+	markdownFence     = regexp.MustCompile("^\\s*```")
+	markdownOrdered   = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	markdownUnordered = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	markdownTask      = regexp.MustCompile(`^\[([ xX])\]\s+(.*)$`)
+	markdownTableRule = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	markdownCodeSpan  = regexp.MustCompile("`([^`]+)`")
+	markdownLink      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)|https?://[^\s<>"'()\[\]]+`)
+	markdownStrong    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	markdownEm        = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// safeHref allows only links that cannot run script when clicked.
+func safeHref(url string) bool {
+	lower := strings.ToLower(url)
+	for _, prefix := range []string{"http://", "https://", "mailto:", "#", "/"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return !strings.Contains(lower, ":")
+}
+
+// renderEmphasis escapes text and applies bold and italic markup.
+func renderEmphasis(text string) string {
+	text = html.EscapeString(text)
+	text = markdownStrong.ReplaceAllString(text, "<strong>$1$2</strong>")
+	return markdownEm.ReplaceAllString(text, "<em>$1</em>")
+}
+
+// renderLinks turns Markdown links and bare URLs into anchors.
+func renderLinks(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range markdownLink.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(renderEmphasis(text[last:m[0]]))
+		end := m[1]
+		label, url := text[m[0]:end], text[m[0]:end]
+		if m[2] >= 0 {
+			label, url = text[m[2]:m[3]], text[m[4]:m[5]]
+		} else {
+			// Punctuation ending a sentence is not part of a bare URL.
+			url = strings.TrimRight(url, ".,;:!?")
+			end = m[0] + len(url)
+			label = url
+		}
+		if safeHref(url) {
+			b.WriteString(`<a href="` + html.EscapeString(url) + `">` + renderEmphasis(label) + "</a>")
+		} else {
+			b.WriteString(renderEmphasis(text[m[0]:end]))
+		}
+		last = end
+	}
+	b.WriteString(renderEmphasis(text[last:]))
+	return b.String()
+}
+
+// renderInline renders a line of inline Markdown, leaving code spans as-is.
+func renderInline(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range markdownCodeSpan.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(renderLinks(text[last:m[0]]))
+		b.WriteString("<code>" + html.EscapeString(text[m[2]:m[3]]) + "</code>")
+		last = m[1]
+	}
+	b.WriteString(renderLinks(text[last:]))
+	return b.String()
+}
+
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+// renderMarkdown converts note text to an HTML fragment.
+func renderMarkdown(text string) string {
+	var b strings.Builder
+	var paragraph []string
+	list := ""
+	flush := func() {
+		if len(paragraph) > 0 {
+			b.WriteString("<p>" + strings.Join(paragraph, "<br>\n") + "</p>\n")
+			paragraph = nil
+		}
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+	openList := func(kind string) {
+		if list != kind {
+			flush()
+			b.WriteString("<" + kind + ">\n")
+			list = kind
+		}
+	}
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case markdownFence.MatchString(line):
+			flush()
+			b.WriteString("<pre><code>")
+			for i++; i < len(lines) && !markdownFence.MatchString(lines[i]); i++ {
+				b.WriteString(html.EscapeString(lines[i]) + "\n")
+			}
+			b.WriteString("</code></pre>\n")
+		case trimmed == "":
+			flush()
+		case markdownHeading.MatchString(trimmed):
+			flush()
+			m := markdownHeading.FindStringSubmatch(trimmed)
+			level := string('0' + rune(len(m[1])))
+			b.WriteString("<h" + level + ">" + renderInline(m[2]) + "</h" + level + ">\n")
+		case strings.HasPrefix(trimmed, "|") && i+1 < len(lines) && markdownTableRule.MatchString(lines[i+1]):
+			flush()
+			b.WriteString("<table>\n<tr>")
+			for _, cell := range tableCells(line) {
+				b.WriteString("<th>" + renderInline(cell) + "</th>")
+			}
+			b.WriteString("</tr>\n")
+			for i += 2; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				b.WriteString("<tr>")
+				for _, cell := range tableCells(lines[i]) {
+					b.WriteString("<td>" + renderInline(cell) + "</td>")
+				}
+				b.WriteString("</tr>\n")
+			}
+			i--
+			b.WriteString("</table>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			b.WriteString("<blockquote>" + renderInline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))) + "</blockquote>\n")
+		case markdownUnordered.MatchString(line):
+			openList("ul")
+			item := markdownUnordered.FindStringSubmatch(line)[1]
+			if task := markdownTask.FindStringSubmatch(item); task != nil {
+				checked := ""
+				if task[1] != " " {
+					checked = " checked"
+				}
+				b.WriteString(`<li><input type="checkbox" disabled` + checked + "> " + renderInline(task[2]) + "</li>\n")
+			} else {
+				b.WriteString("<li>" + renderInline(item) + "</li>\n")
+			}
+		case markdownOrdered.MatchString(line):
+			openList("ol")
+			b.WriteString("<li>" + renderInline(markdownOrdered.FindStringSubmatch(line)[1]) + "</li>\n")
+		default:
+			if list != "" {
+				flush()
+			}
+			paragraph = append(paragraph, renderInline(trimmed))
+		}
+	}
+	flush()
+	return b.String()
+}
+
+// markdownStylesheet keeps rendered notes readable without external assets.
+const markdownStylesheet = `body { max-width: 46em; margin: 2em auto; padding: 0 1em; font: 16px/1.5 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #222; }
+header { color: #666; font-size: 0.9em; border-bottom: 1px solid #ddd; margin-bottom: 1em; }
+pre { background: #f5f5f5; padding: 0.8em; overflow-x: auto; }
+code { font-family: Menlo, Consolas, monospace; font-size: 0.9em; }
+blockquote { border-left: 3px solid #ccc; margin-left: 0; padding-left: 1em; color: #555; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; }
+li { list-style-position: outside; }`
+
+// renderHTMLPage wraps rendered note text in a standalone HTML document.
+func renderHTMLPage(title string, header string, text string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	b.WriteString("<style>\n" + markdownStylesheet + "\n</style>\n</head>\n<body>\n")
+	if header != "" {
+		b.WriteString("<header>" + html.EscapeString(header) + "</header>\n")
+	}
+	b.WriteString(renderMarkdown(text))
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
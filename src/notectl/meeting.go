@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// meetingTag is added to every note captured with "meeting start".
+const meetingTag = "meeting"
+
+func meetingTemplate(title string, attendees tagList, start time.Time) string {
+	var mentions []string
+	for _, a := range attendees {
+		mentions = append(mentions, "@"+personSlug(a))
+	}
+	return fmt.Sprintf("# %s\n\nDate: %s\nAttendees: %s\n\n## Agenda\n\n- \n\n## Notes\n\n\n## Action items\n\n- [ ] \n",
+		title, formatDateTime(start), strings.Join(mentions, " "))
+}
+
+// startMeeting captures meeting notes in the editor, recording when the
+// meeting started and ended and who attended.
+func startMeeting(title string, attendees tagList, tags tagList, database *sql.DB) error {
+	start := time.Now()
+	text, err := captureFromEditorWithTemplate(meetingTemplate(title, attendees, start))
+	if err != nil {
+		return err
+	}
+	end := time.Now()
+	var slugs []string
+	for _, a := range attendees {
+		slugs = append(slugs, personSlug(a))
+	}
+	n := note{
+		Time: start,
+		Text: string(text),
+		Tags: append(tagList{meetingTag}, tags...),
+		Meta: metaList{
+			"title":     title,
+			"attendees": strings.Join(slugs, ","),
+			"start":     start.Format(time.RFC3339),
+			"end":       end.Format(time.RFC3339),
+		},
+	}
+	// Attendees count as mentioned even if the template line is edited away.
+	for _, slug := range slugs {
+		n.Meta[notes.MentionKeyPrefix+slug] = slug
+	}
+	n.PrintConsole()
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	fmt.Printf("Meeting lasted %s\n", end.Sub(start).Round(time.Minute))
+	return nil
+}
+
+func listMeetings(person string, database *sql.DB) error {
+	var filter queryFilter
+	filter.Add(tagMatchClause, meetingTag)
+	if person != "" {
+		filter.Add("id IN (SELECT note_id FROM mentions WHERE person = (?))", strings.TrimPrefix(strings.ToLower(person), "@"))
+	}
+	rows, err := database.Query("SELECT id, timestamp FROM notes"+filter.Where()+" ORDER BY timestamp", filter.Args...)
+	if err != nil {
+		return err
+	}
+	type meeting struct {
+		id        int
+		timestamp int64
+	}
+	var meetings []meeting
+	for rows.Next() {
+		var m meeting
+		if err := rows.Scan(&m.id, &m.timestamp); err != nil {
+			rows.Close()
+			return err
+		}
+		meetings = append(meetings, m)
+	}
+	rows.Close()
+	for _, m := range meetings {
+		meta, err := getNoteMeta(m.id, database)
+		if err != nil {
+			return err
+		}
+		duration := ""
+		start, err1 := time.Parse(time.RFC3339, meta["start"])
+		end, err2 := time.Parse(time.RFC3339, meta["end"])
+		if err1 == nil && err2 == nil {
+			duration = fmt.Sprintf(" (%s)", end.Sub(start).Round(time.Minute))
+		}
+		fmt.Printf("%d - %s: %s%s, attendees: %s\n", m.id, formatDateTime(time.Unix(m.timestamp, 0)), meta["title"], duration, meta["attendees"])
+	}
+	return nil
+}
+
+// runMeeting dispatches the "meeting start" subcommand.
+func runMeeting(args []string, database *sql.DB) error {
+	usage := "usage: notectl meeting start <title> [-attendees a,b] [-t tags]"
+	if len(args) == 0 || args[0] != "start" {
+		return errors.New(usage)
+	}
+	startCommand := newFlagSet("meeting start")
+	var attendees, tags tagList
+	startCommand.Var(&attendees, "attendees", "A comma-delimited list of attendees.")
+	startCommand.Var(&tags, "t", "A comma-delimited list of extra tags.")
+	title, err := parseInterspersed(startCommand, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(title) == 0 {
+		return errors.New(usage)
+	}
+	return startMeeting(strings.Join(title, " "), attendees, tags, database)
+}
+
+// runMeetings lists meeting notes, optionally those a person attended.
+func runMeetings(args []string) error {
+	meetingsCommand := newFlagSet("meetings")
+	meetingsPersonPtr := meetingsCommand.String("person", "", "Only show meetings this person attended.")
+	if err := parseFlags(meetingsCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return listMeetings(*meetingsPersonPtr, database)
+}
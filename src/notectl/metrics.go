@@ -0,0 +1,271 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricTag marks notes recorded with "notectl log". The metric name, value
+// and unit are kept in the note's metadata.
+const metricTag = "metric"
+
+// sparkBlocks are the bar heights used to draw sparklines and charts.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+type metricPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+func logMetric(name string, value string, unit string, comment string, tags tagList, database *sql.DB) error {
+	name = strings.ToLower(name)
+	v, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s, expected a number", value, name)
+	}
+	value = strconv.FormatFloat(v, 'f', -1, 64)
+	if unit == "" {
+		unit = configValue("metric."+name+".unit", "")
+	}
+	text := strings.TrimSpace(fmt.Sprintf("%s: %s %s", name, value, unit))
+	if comment != "" {
+		text += "\n\n" + comment
+	}
+	meta := metaList{"type": metricTag, "metric": name, "value": value}
+	if unit != "" {
+		meta["unit"] = unit
+	}
+	n := note{Time: time.Now(), Text: text, Tags: append(tagList{metricTag}, tags...), Meta: meta}
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	fmt.Printf("Logged %s (%d)\n", firstLine(text, 70), n.ID)
+	return nil
+}
+
+// metricPoints loads the values recorded for a metric since the given time,
+// oldest first.
+func metricPoints(name string, since time.Time, database *sql.DB) ([]metricPoint, string, error) {
+	rows, err := database.Query(`SELECT notes.timestamp,
+		MAX(CASE WHEN metadata.key = 'value' THEN metadata.value END),
+		MAX(CASE WHEN metadata.key = 'unit' THEN metadata.value END)
+		FROM notes JOIN metadata ON metadata.note_id = notes.id
+		WHERE notes.timestamp >= (?)
+		AND notes.id IN (SELECT note_id FROM metadata WHERE key = 'metric' AND value = (?))
+		GROUP BY notes.id ORDER BY notes.timestamp`, since.Unix(), strings.ToLower(name))
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	var points []metricPoint
+	var unit string
+	for rows.Next() {
+		var timestamp int64
+		var value, u sql.NullString
+		if err := rows.Scan(&timestamp, &value, &u); err != nil {
+			return nil, "", err
+		}
+		v, err := strconv.ParseFloat(value.String, 64)
+		if err != nil {
+			continue
+		}
+		points = append(points, metricPoint{Time: time.Unix(timestamp, 0), Value: v})
+		if u.String != "" {
+			unit = u.String
+		}
+	}
+	return points, unit, rows.Err()
+}
+
+// bucketPoints averages the values into at most width columns so long
+// histories still fit the terminal.
+func bucketPoints(points []metricPoint, width int) []float64 {
+	if len(points) <= width {
+		values := make([]float64, len(points))
+		for i, p := range points {
+			values[i] = p.Value
+		}
+		return values
+	}
+	values := make([]float64, width)
+	for i := range values {
+		start := i * len(points) / width
+		end := (i + 1) * len(points) / width
+		var sum float64
+		for _, p := range points[start:end] {
+			sum += p.Value
+		}
+		values[i] = sum / float64(end-start)
+	}
+	return values
+}
+
+func valueRange(values []float64) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	return lo, hi
+}
+
+// sparkline draws the values as a single row of block characters.
+func sparkline(values []float64) string {
+	lo, hi := valueRange(values)
+	var b strings.Builder
+	for _, v := range values {
+		level := len(sparkBlocks) - 1
+		if hi > lo {
+			level = int((v - lo) / (hi - lo) * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// chartRows draws the values as a chart height rows tall, top row first.
+func chartRows(values []float64, height int) []string {
+	lo, hi := valueRange(values)
+	steps := height * len(sparkBlocks)
+	rows := make([]string, height)
+	for row := range rows {
+		var b strings.Builder
+		floor := (height - 1 - row) * len(sparkBlocks)
+		for _, v := range values {
+			level := steps
+			if hi > lo {
+				level = 1 + int((v-lo)/(hi-lo)*float64(steps-1))
+			}
+			switch {
+			case level >= floor+len(sparkBlocks):
+				b.WriteRune(sparkBlocks[len(sparkBlocks)-1])
+			case level > floor:
+				b.WriteRune(sparkBlocks[level-floor-1])
+			default:
+				b.WriteRune(' ')
+			}
+		}
+		rows[row] = b.String()
+	}
+	return rows
+}
+
+func plotMetric(name string, since time.Time, width int, height int, database *sql.DB) error {
+	points, unit, err := metricPoints(name, since, database)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("no values logged for %s since %s", name, formatDate(since))
+	}
+	format := func(v float64) string {
+		return strings.TrimSpace(strconv.FormatFloat(v, 'f', -1, 64) + " " + unit)
+	}
+	values := bucketPoints(points, width)
+	lo, hi := valueRange(values)
+	first, last := points[0], points[len(points)-1]
+	fmt.Printf("%s, %s to %s (%d values)\n\n", name, formatDate(first.Time), formatDate(last.Time), len(points))
+	switch {
+	case plainOutput:
+		for _, p := range points {
+			fmt.Printf("%s: %s\n", formatDate(p.Time), format(p.Value))
+		}
+		fmt.Println()
+	case height <= 1:
+		fmt.Printf("  %s\n\n", sparkline(values))
+	default:
+		labelWidth := len(format(hi))
+		if len(format(lo)) > labelWidth {
+			labelWidth = len(format(lo))
+		}
+		for i, row := range chartRows(values, height) {
+			label := ""
+			switch i {
+			case 0:
+				label = format(hi)
+			case height - 1:
+				label = format(lo)
+			}
+			fmt.Printf("  %*s │%s\n", labelWidth, label, row)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("  min %s, max %s, latest %s, change %+g\n", format(lo), format(hi), format(last.Value), last.Value-first.Value)
+	return nil
+}
+
+func listMetrics(database *sql.DB) error {
+	rows, err := database.Query(`SELECT value, COUNT(*), MAX(notes.timestamp)
+		FROM metadata JOIN notes ON notes.id = metadata.note_id
+		WHERE key = 'metric' GROUP BY value ORDER BY value`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		var count int
+		var latest int64
+		if err := rows.Scan(&name, &count, &latest); err != nil {
+			return err
+		}
+		fmt.Printf("%s %4d values, last logged %s\n", padRight(name, 16), count, formatDate(time.Unix(latest, 0)))
+	}
+	return rows.Err()
+}
+
+// runMetrics dispatches the "metrics plot" and "metrics list" subcommands.
+func runMetrics(args []string, database *sql.DB) error {
+	usage := "usage: notectl metrics <plot <metric> [-since 90d] [-width n] [-height n]|list>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "plot":
+		plotCommand := newFlagSet("metrics plot")
+		sincePtr := plotCommand.String("since", "90d", "Period to plot, e.g. 30d, 6m or 1y.")
+		widthPtr := plotCommand.Int("width", 60, "Maximum number of columns in the chart.")
+		heightPtr := plotCommand.Int("height", 1, "Rows in the chart, 1 draws a sparkline.")
+		names, err := parseInterspersed(plotCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(names) != 1 || *widthPtr < 1 {
+			return errors.New(usage)
+		}
+		since, err := parseSince(*sincePtr)
+		if err != nil {
+			return err
+		}
+		return plotMetric(names[0], since, *widthPtr, *heightPtr, database)
+	case "list":
+		return listMetrics(database)
+	}
+	return errors.New(usage)
+}
+
+// runLog records a value of a metric.
+func runLog(args []string) error {
+	logCommand := newFlagSet("log")
+	logUnitPtr := logCommand.String("unit", "", "Unit of the value, e.g. kg. Defaults to the metric.<name>.unit setting.")
+	logCommentPtr := logCommand.String("m", "", "A comment to keep with the value.")
+	var logTagList tagList
+	logCommand.Var(&logTagList, "t", "A comma-delimited list of extra tags.")
+	args, err := parseInterspersed(logCommand, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return errors.New("usage: notectl log <metric> <value> [-unit unit] [-m comment] [-t tags]")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return logMetric(args[0], args[1], *logUnitPtr, *logCommentPtr, logTagList, database)
+}
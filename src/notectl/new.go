@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// runNew saves a note given on the command line or written in the editor.
+func runNew(args []string) error {
+	newCommand := newFlagSet("new")
+	var newTagList tagList
+	newNotePtr := newCommand.String("n", "", "Note text.")
+	newEditorNotePtr := newCommand.Bool("e", false, "Create a new file with a text editor.")
+	newCommand.Var(&newTagList, "t", "A comma-delimited list of tags.")
+	newStatusPtr := newCommand.String("s", configValue("new.status", ""), "Optional status: inbox, todo, doing, done or archived.")
+	newDuePtr := newCommand.String("due", "", "Optional due date in the format <yyyy>-<mm>-<dd>.")
+	newSpellPtr := newCommand.Bool("spell", configValue("spellcheck", "") == "on", "Spellcheck notes written in the editor before saving.")
+	newContextPtr := newCommand.Bool("context", configValue("context", "") == "on", "Stamp the note with the hostname, OS, git branch and weather.")
+	newTypePtr := newCommand.String("type", "", "Note type defined in the config, which sets required metadata, tags and a template.")
+	var newMetaList metaList
+	newCommand.Var(&newMetaList, "meta", "Metadata in the form key=value, may be repeated.")
+	newHerePtr := newCommand.Bool("here", false, "Associate the note with the current git repository, or directory outside one.")
+	newTitlePtr := newCommand.String("title", "", "Optional title, generated from the note text when not given.")
+	if err := parseFlags(newCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	if *newNotePtr == "" && newCommand.NFlag() > 0 && !*newEditorNotePtr && *newTypePtr == "" {
+		newCommand.PrintDefaults()
+		return exitStatus(1)
+	}
+	newMeta := make(metaList)
+	for key, value := range newMetaList {
+		newMeta[key] = value
+	}
+	var newType noteType
+	if *newTypePtr != "" {
+		if newType, err = loadNoteType(*newTypePtr); err != nil {
+			return err
+		}
+		if err := newType.requireFields(newMeta, interactiveReader()); err != nil {
+			return err
+		}
+		newTagList = addTags(newTagList, newType.Tags)
+	}
+	if len(newTagList) == 0 {
+		newTagList.Set("generic")
+	}
+	if err := checkTagVocabulary(newTagList, interactiveReader()); err != nil {
+		return err
+	}
+	if *newStatusPtr != "" && !validStatus(*newStatusPtr) {
+		return fmt.Errorf("unknown status %q, expected one of %s", *newStatusPtr, strings.Join(noteStatuses, ", "))
+	}
+	if *newDuePtr != "" {
+		due, err := parseDueDate(*newDuePtr)
+		if err != nil {
+			return err
+		}
+		newMeta["due"] = due.Format(dueDateFormat)
+	}
+	if *newHerePtr {
+		dir, err := projectDir()
+		if err != nil {
+			return err
+		}
+		newMeta[projectKey] = dir
+	}
+	if *newContextPtr {
+		stampContext(newMeta)
+	}
+	// We default to opening a text editor if there are no flags and no extra args
+	if newCommand.NFlag() == 0 || *newEditorNotePtr || (*newTypePtr != "" && *newNotePtr == "") {
+		if newCommand.NArg() == 0 || *newEditorNotePtr {
+			noteValBytes, err := captureFromEditorWithTemplate(newType.fillTemplate(newMeta))
+			if err != nil {
+				return err
+			}
+			noteValString := bytes.NewBuffer(noteValBytes).String()
+			if *newSpellPtr {
+				if noteValString, err = fixSpelling(noteValString); err != nil {
+					fmt.Println(err)
+				}
+			}
+			*newNotePtr = noteValString
+		} else {
+			noteVal := strings.Join(newCommand.Args(), " ")
+			*newNotePtr = noteVal
+		}
+	}
+	timeStamp := time.Now()
+	note := note{Time: timeStamp, Text: *newNotePtr, Tags: newTagList, Status: *newStatusPtr, Meta: newMeta, Title: *newTitlePtr}
+	return saveNote(&note, database)
+}
@@ -1,24 +1,18 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/exec"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/hsnodgrass/notectl/pkg/notes"
 )
 
-// DefaultEditor Default text editor for notes
-const DefaultEditor = "vi"
-
 type tagList []string
 
 func (s *tagList) String() string {
@@ -30,273 +24,414 @@ func (s *tagList) Set(value string) error {
 	return nil
 }
 
+// metaList collects repeated key=value flags into note metadata.
+type metaList map[string]string
+
+func (m *metaList) String() string {
+	return fmt.Sprintf("%v", map[string]string(*m))
+}
+
+func (m *metaList) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("metadata must be in the form key=value, got %q", value)
+	}
+	if *m == nil {
+		*m = make(metaList)
+	}
+	(*m)[kv[0]] = kv[1]
+	return nil
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments, returning the positional arguments in order.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := parseFlags(fs, args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// tagMatchClause matches a single tag against the tags column, which holds the
+// "[tag1 tag2]" form produced by tagList.String.
+const tagMatchClause = notes.TagMatchClause
+
+// noteColumns lists the columns printRows expects, in order.
+const noteColumns = "id, day, month, year, timestamp, " + notes.TextColumn + ", tags, status, title, textsize"
+
+// noteListColumns returns noteColumns for list views, which only read as much
+// of each note's text as listPreview shows, so notes holding pasted logs do
+// not have to be loaded in full. Compressed notes are read whole as they can
+// only be decompressed whole.
+func noteListColumns() string {
+	length := listPreview.readLength()
+	if length == 0 {
+		return noteColumns
+	}
+	return fmt.Sprintf("id, day, month, year, timestamp, CASE WHEN compressed = 1 THEN X'01' || notetext ELSE X'00' || substr(notetext, 1, %d) END, tags, status, title, textsize", length)
+}
+
+// noteStatuses are the values accepted for a note's optional status.
+var noteStatuses = []string{"inbox", "todo", "doing", "done", "archived"}
+
+// parseTags reverses tagList.String for values read back from the database.
+func parseTags(s string) tagList {
+	return notes.ParseTags(s)
+}
+
 type note struct {
-	Time time.Time
-	Text string
-	Tags tagList
+	ID     int
+	Time   time.Time
+	Text   string
+	Tags   tagList
+	Status string
+	Meta   metaList
+	UUID   string
+	Title  string
+}
+
+func validStatus(status string) bool {
+	for _, s := range noteStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
 }
 
 func (n *note) PrintConsole() error {
-	fmt.Printf("%s : Saving note \"%s\", tags: %s\n", n.Time.Format(time.RFC822), n.Text, n.Tags.String())
+	fmt.Printf("%s : Saving note \"%s\", tags: %s\n", formatDateTime(n.Time), n.Text, n.Tags.String())
 	return nil
 }
 
 func connectToDatabase(path string) (*sql.DB, error) {
-	database, err := sql.Open("sqlite3", path)
+	defer profileSince("open", time.Now())
+	database, err := sql.Open(sqliteDriver, path)
 	if err != nil {
 		panic(err)
 	}
 	return database, nil
 }
 
-func createTableIfNotExist(database *sql.DB) error {
-	statement, _ := database.Prepare("CREATE TABLE IF NOT EXISTS notes (id INTEGER PRIMARY KEY, day INTEGER, month INTEGER, year INTEGER, timestamp INTEGER, notetext BLOB, tags TEXT)")
-	statement.Exec()
-	return nil
+// shared is the database handle every command of an invocation uses. It is
+// opened on first use and closed when main returns; database/sql pools the
+// underlying connections behind it.
+var shared struct {
+	once     sync.Once
+	database *sql.DB
+	err      error
 }
 
-func (n *note) Save(database *sql.DB) error {
-	statement, _ := database.Prepare("INSERT INTO notes (day, month, year, timestamp, notetext, tags) VALUES (?, ?, ?, ?, ?, ?)")
-	statement.Exec(n.Time.Day(), n.Time.Month(), n.Time.Year(), n.Time.Unix(), n.Text, n.Tags.String())
-	return nil
+// openDatabase returns the shared database handle, connecting and creating
+// or migrating the schema the first time it is called.
+func openDatabase(path string) (*sql.DB, error) {
+	shared.once.Do(func() {
+		shared.database, shared.err = connectToDatabase(path)
+		if shared.err == nil {
+			shared.err = createTableIfNotExist(shared.database)
+		}
+	})
+	return shared.database, shared.err
 }
 
-func printRows(rows *sql.Rows) error {
-	var id int
-	var day int
-	var month string
-	var year int
-	var timestamp int
-	var notetext string
-	var tags string
-	for rows.Next() {
-		rows.Scan(&id, &day, &month, &year, &timestamp, &notetext, &tags)
-		fmt.Printf("%d - %s: %s, tags: %s\n", id, time.Unix(int64(timestamp), 0).Format(time.RFC822), notetext, tags)
+// closeDatabase closes the shared handle if a command opened it.
+func closeDatabase() {
+	if shared.database != nil {
+		shared.database.Close()
 	}
-	return nil
 }
 
-func showAllNotes(database *sql.DB) error {
-	rows, _ := database.Query("SELECT * FROM notes")
-	printRows(rows)
-	return nil
+func createTableIfNotExist(database *sql.DB) error {
+	defer profileSince("open", time.Now())
+	return notes.CreateSchema(database)
 }
 
-func showNoteByID(id int, database *sql.DB) error {
-	rows, _ := database.Query("SELECT * FROM notes WHERE id = (?)", id)
-	printRows(rows)
-	return nil
+// noteStore returns the note storage over database, compressing note text as
+// configured.
+func noteStore(database *sql.DB) *notes.SQLiteStore {
+	return &notes.SQLiteStore{DB: database, CompressionThreshold: compressionThreshold()}
 }
 
-// Defaults to this month and this year
-func showNoteByDay(day int, database *sql.DB) error {
-	rows, _ := database.Query("SELECT * FROM notes WHERE day = (?) AND month = (?) AND year = (?)", day, time.Now().Month(), time.Now().Year())
-	printRows(rows)
-	return nil
+func noteFromStore(n notes.Note) note {
+	return note{ID: n.ID, Time: n.Time, Text: n.Text, Tags: n.Tags, Status: n.Status, Meta: n.Meta, UUID: n.UUID, Title: n.Title}
 }
 
-// Defaults to this year
-func showNoteByMonth(month int, database *sql.DB) error {
-	rows, _ := database.Query("SELECT * FROM notes WHERE month = (?) AND year = (?)", month, time.Now().Year())
-	printRows(rows)
-	return nil
+// Save stores a new note, refusing text larger than note.max_size.
+func (n *note) Save(database *sql.DB) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	if err := n.insert(tx); err != nil {
+		rollbackJournaled(tx)
+		return err
+	}
+	return commitJournaled(tx)
 }
 
-func showNoteByYear(year int, database *sql.DB) error {
-	rows, _ := database.Query("SELECT * FROM notes WHERE year = (?)", year)
-	printRows(rows)
+// insert is Save within a transaction the caller commits.
+func (n *note) insert(database execer) error {
+	if err := checkNoteSize(n.Text); err != nil {
+		return err
+	}
+	if n.Title == "" {
+		n.Title = generateTitle(n.Text)
+	}
+	// Metadata is set below, where it is journaled and privacy mode applies.
+	// A note with an ID keeps it, as when replaying the journal.
+	stored := notes.Note{ID: n.ID, Time: n.Time, Text: n.Text, Tags: n.Tags, Status: n.Status, UUID: n.UUID, Title: n.Title}
+	if err := notes.InsertNote(database, &stored, compressionThreshold()); err != nil {
+		return err
+	}
+	n.ID, n.UUID = stored.ID, stored.UUID
+	created := journalEntry{Op: journalCreate, ID: n.ID, Time: &n.Time, Text: stringPtr(n.Text), Tags: n.Tags, Status: stringPtr(n.Status), UUID: n.UUID, Title: n.Title}
+	if err := journal(database, created); err != nil {
+		return err
+	}
+	for key, value := range n.Meta {
+		if err := setNoteMeta(n.ID, key, value, database); err != nil {
+			return err
+		}
+	}
+	if _, ok := n.Meta[langKey]; !ok {
+		return updateLanguage(n.ID, n.Text, database)
+	}
 	return nil
 }
 
-func showNoteByDate(date string, usa bool, database *sql.DB) error {
-	d := strings.Split(date, "/")
-	var day int
-	var month int
-	var year int
-	if usa {
-		day, _ = strconv.Atoi(d[1])
-		month, _ = strconv.Atoi(d[0])
-		year, _ = strconv.Atoi(d[2])
-	} else {
-		day, _ = strconv.Atoi(d[0])
-		month, _ = strconv.Atoi(d[1])
-		year, _ = strconv.Atoi(d[2])
-	}
-	rows, _ := database.Query("SELECT * FROM notes WHERE day = (?) AND month = (?) AND year = (?)", day, month, year)
-	printRows(rows)
-	return nil
+func getNoteText(id int, database *sql.DB) (string, error) {
+	var text noteText
+	err := database.QueryRow("SELECT "+notes.TextColumn+" FROM notes WHERE id = (?)", id).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no note with ID %d", id)
+	}
+	return string(text), err
 }
 
-func deleteAll(database *sql.DB) error {
-	fmt.Println("Are you sure you want to delete all notes? (y/n)")
-	reader := bufio.NewReader(os.Stdin)
-	char, _, err := reader.ReadRune()
-	if err != nil {
-		panic(err)
+// execer is satisfied by both *sql.DB and *sql.Tx, so updates can run inside
+// a transaction when a caller needs several to apply together.
+type execer = notes.Execer
+
+func setNoteMeta(id int, key string, value string, database execer) error {
+	if privacyMode() && identifyingMetaKey(key) {
+		return nil
 	}
-	if char == 'y' || char == 'Y' {
-		fmt.Println("Deleting all notes...")
-		statement, _ := database.Prepare("DROP TABLE notes")
-		statement.Exec()
-		createTableIfNotExist(database)
-	} else {
-		fmt.Println("Not deleting notes, everything is still there.")
+	if err := notes.SetMeta(database, id, key, value); err != nil {
+		return err
 	}
-	return nil
+	return journal(database, journalEntry{Op: journalMeta, ID: id, Key: key, Value: stringPtr(value)})
 }
 
-func openFileInEditor(filename string) error {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = DefaultEditor
-	}
-
-	executable, err := exec.LookPath(editor)
-	if err != nil {
+func deleteNoteMeta(id int, key string, database execer) error {
+	if err := notes.DeleteMeta(database, id, key); err != nil {
 		return err
 	}
+	return journal(database, journalEntry{Op: journalMetaDelete, ID: id, Key: key})
+}
 
-	cmd := exec.Command(executable, filename)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
+func getNoteMeta(id int, database *sql.DB) (metaList, error) {
+	meta, err := notes.GetMeta(database, id)
+	return metaList(meta), err
 }
 
-func captureFromEditor() ([]byte, error) {
-	file, err := ioutil.TempFile(os.TempDir(), "*")
-	if err != nil {
-		return []byte{}, err
+// updateNoteText replaces a note's text, keeping compression, size and
+// mentions in step with it. Text larger than note.max_size is refused.
+func updateNoteText(id int, text string, database execer) error {
+	if err := checkNoteSize(text); err != nil {
+		return err
+	}
+	if err := notes.UpdateText(database, id, text, compressionThreshold()); err != nil {
+		return err
 	}
+	if err := journal(database, journalEntry{Op: journalText, ID: id, Text: stringPtr(text)}); err != nil {
+		return err
+	}
+	return updateLanguage(id, text, database)
+}
 
-	filename := file.Name()
+// errNoMatch is returned when a listing matched no notes. It is reported on
+// stderr with the exitNoMatch status, so scripts can tell an empty result
+// from a failure.
+var errNoMatch = errors.New("no notes matched")
 
-	defer os.Remove(filename)
+// exitNoMatch is the exit status when nothing matched. Errors exit with 1 and
+// invalid flags with 2.
+const exitNoMatch = 3
 
-	if err = file.Close(); err != nil {
-		return []byte{}, err
-	}
+// exitStatus is returned by a command that has already reported why it
+// failed, to exit with the status without printing anything more.
+type exitStatus int
 
-	if err = openFileInEditor(filename); err != nil {
-		return []byte{}, err
-	}
-
-	bytes, err := ioutil.ReadFile(filename)
+func (s exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(s))
+}
 
-	return bytes, nil
+// exitCode reports err and returns the status to exit with: exitNoMatch for
+// errNoMatch, 2 for invalid flags, which the flag set has already reported, 0
+// when help was asked for and 1 for anything else.
+func exitCode(err error) int {
+	if status, ok := err.(exitStatus); ok {
+		return int(status)
+	}
+	if invalid, ok := err.(flagError); ok {
+		if invalid.err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if err == errNoMatch {
+		fmt.Fprintln(os.Stderr, tr(err.Error()))
+		return exitNoMatch
+	}
+	fmt.Println(err)
+	return 1
 }
 
 func main() {
-	dbpath := fmt.Sprintf("%s/notectl.db", os.Getenv("HOME"))
-
-	newCommand := flag.NewFlagSet("new", flag.ExitOnError)
-	showCommand := flag.NewFlagSet("show", flag.ExitOnError)
-	deleteCommand := flag.NewFlagSet("delete", flag.ExitOnError)
+	os.Exit(run())
+}
 
-	var newTagList tagList
-	newNotePtr := newCommand.String("n", "", "Note text.")
-	newEditorNotePtr := newCommand.Bool("e", false, "Create a new file with a text editor.")
-	newCommand.Var(&newTagList, "t", "A comma-delimited list of tags.")
+// run carries out the command line and returns the exit status, so main only
+// exits once the deferred cleanup has run.
+func run() int {
+	globalFlags := newFlagSet("notectl")
+	configPathPtr := globalFlags.String("config", configPath(), "Configuration file to read, or - for standard input.")
+	globalFlags.BoolVar(&assumeYes, "yes", false, "Answer yes to every confirmation instead of asking.")
+	globalFlags.BoolVar(&assumeYes, "y", false, "Shorthand for -yes.")
+	plainPtr := globalFlags.Bool("plain", false, "Plain output for screen readers: no color, symbols or side by side layouts. Set plain = on to make it the default.")
+	profilePtr := globalFlags.Bool("profile", false, "Report on stderr where the time went: opening the database, fetching rows and rendering.")
+	pprofPtr := globalFlags.String("pprof", "", "Write CPU and heap profiles to <prefix>.cpu.pprof and <prefix>.heap.pprof.")
+	if err := parseFlags(globalFlags, os.Args[1:]); err != nil {
+		return exitCode(err)
+	}
+	os.Args = append(os.Args[:1], globalFlags.Args()...)
 
-	showAllPtr := showCommand.Bool("all", false, "Show all notes.")
-	showByIDPtr := showCommand.Int("i", -1, "Show a note based of the ID it has assigned to it.")
-	showByDayPtr := showCommand.Int("day", -1, "Show notes from the specified day of the current month and year.")
-	showByMonthPtr := showCommand.Int("month", -1, "Show notes from the specified month of the current year.")
-	showByYearPtr := showCommand.Int("year", -1, "Show notes from the specified year.")
-	showByDatePtr := showCommand.String("date", "", "Show notes by date in the format <d>/<m>/<y>.")
-	showUSADatePtr := showCommand.Bool("usa", false, "Allows for searching by date in US format <m>/<d>/<y>.")
+	stopProfile, err := startProfile(*profilePtr, *pprofPtr)
+	if err != nil {
+		return exitCode(err)
+	}
+	defer stopProfile()
 
-	deleteAllPtr := deleteCommand.Bool("all", false, "Delete all stored notes.")
+	if err := loadConfig(*configPathPtr); err != nil {
+		return exitCode(err)
+	}
+	plainOutput = *plainPtr || configValue("plain", "off") == "on"
+	if plainOutput {
+		useColor = false
+	}
+	listPreview = previewFromConfig()
+	defer closeDatabase()
 
 	if len(os.Args) < 2 {
-		fmt.Println("subcommand required")
-		os.Exit(1)
+		fmt.Println(tr("subcommand required"))
+		return 1
 	}
-
-	switch os.Args[1] {
-	case "new":
-		newCommand.Parse(os.Args[2:])
-	case "show":
-		showCommand.Parse(os.Args[2:])
-	case "delete":
-		deleteCommand.Parse(os.Args[2:])
-	default:
-		flag.PrintDefaults()
-		os.Exit(1)
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Printf(tr("unknown subcommand %q")+"\n", os.Args[1])
+		return 1
 	}
-
-	if newCommand.Parsed() {
-		database, err := connectToDatabase(dbpath)
-		if err != nil {
-			panic(err)
-		}
-		createTableIfNotExist(database)
-		if *newNotePtr == "" && newCommand.NFlag() > 0 && !*newEditorNotePtr {
-			newCommand.PrintDefaults()
-			os.Exit(1)
-		}
-		if len(newTagList) == 0 {
-			newTagList.Set("generic")
-		}
-		// We default to opening a text editor if there are no flags and no extra args
-		if newCommand.NFlag() == 0 || *newEditorNotePtr {
-			if len(os.Args[2:]) == 0 || *newEditorNotePtr {
-				noteValBytes, err := captureFromEditor()
-				if err != nil {
-					panic(err)
-				}
-				noteValString := bytes.NewBuffer(noteValBytes).String()
-				*newNotePtr = noteValString
-			} else {
-				noteVal := strings.Join(newCommand.Args(), " ")
-				*newNotePtr = noteVal
-			}
-		}
-		timeStamp := time.Now()
-		note := note{Time: timeStamp, Text: *newNotePtr, Tags: newTagList}
-		note.PrintConsole()
-		note.Save(database)
-		database.Close()
+	if err := command(os.Args[2:]); err != nil {
+		return exitCode(err)
 	}
+	return 0
+}
 
-	if showCommand.Parsed() {
-		database, err := connectToDatabase(dbpath)
-		if err != nil {
-			panic(err)
-		}
-		createTableIfNotExist(database)
-		if *showAllPtr {
-			showAllNotes(database)
-		} else if *showByIDPtr != -1 {
-			showNoteByID(*showByIDPtr, database)
-		} else if *showByDayPtr != -1 {
-			showNoteByDay(*showByDayPtr, database)
-		} else if *showByMonthPtr != -1 {
-			showNoteByMonth(*showByMonthPtr, database)
-		} else if *showByYearPtr != -1 {
-			showNoteByYear(*showByYearPtr, database)
-		} else if *showByDatePtr != "" {
-			showNoteByDate(*showByDatePtr, *showUSADatePtr, database)
-		} else {
-			showCommand.PrintDefaults()
-			os.Exit(1)
+// commands are the subcommands, each given the arguments after its name.
+var commands = map[string]func(args []string) error{
+	"new":           runNew,
+	"show":          runShow,
+	"delete":        runDelete,
+	"calendar":      runCalendar,
+	"timeline":      runTimeline,
+	"status":        runStatus,
+	"board":         runBoard,
+	"clock":         withDatabase("clock", runClock),
+	"pomo":          runPomo,
+	"habit":         withDatabase("habit", runHabit),
+	"person":        withDatabase("person", runPerson),
+	"meeting":       withDatabase("meeting", runMeeting),
+	"meetings":      runMeetings,
+	"agenda":        runAgenda,
+	"read":          withDatabase("read", runRead),
+	"quote":         runQuote,
+	"quotes":        runQuotes,
+	"cards":         withDatabase("cards", runCards),
+	"links":         withDatabase("links", runLinks),
+	"attachments":   withDatabase("attachments", runAttachments),
+	"stats":         runStats,
+	"ping":          runPing,
+	"version":       runVersion,
+	"insights":      runInsights,
+	"graph":         withDatabase("graph", runGraph),
+	"tags":          withDatabase("tags", runTags),
+	"inbox":         runInbox,
+	"triage":        runTriage,
+	"bulk-edit":     runBulkEdit,
+	"diff":          runDiff,
+	"verify":        runVerify,
+	"import":        runImport,
+	"export":        runExport,
+	"print":         runPrint,
+	"qr":            runQR,
+	"bot":           withDatabase("bot", runBot),
+	"mailgate":      runMailgate,
+	"bridge":        withDatabase("bridge", runBridge),
+	"tasks":         withDatabase("tasks", runTasks),
+	"capture":       runCapture,
+	"replace":       runReplace,
+	"alias":         withDatabase("alias", runAlias),
+	"open":          runOpen,
+	"copy":          runCopy,
+	"git":           withDatabase("git", runGit),
+	"worklog":       withDatabase("worklog", runWorklog),
+	"incident":      withDatabase("incident", runIncident),
+	"oneonone":      runOneOnOne,
+	"release-notes": runReleaseNotes,
+	"expenses":      withDatabase("expenses", runExpenses),
+	"log":           runLog,
+	"metrics":       withDatabase("metrics", runMetrics),
+	"auth":          withFlags("auth", runAuth),
+	"edit":          runEdit,
+	"append":        runAppend,
+	"replay":        runReplay,
+	"search":        runSearch,
+	"dev":           withDatabase("dev", runDev),
+}
+
+// withFlags adapts a subcommand that reads its arguments itself, so it still
+// answers -h.
+func withFlags(name string, run func(args []string) error) func(args []string) error {
+	return func(args []string) error {
+		flags := newFlagSet(name)
+		if err := parseFlags(flags, args); err != nil {
+			return err
 		}
-		database.Close()
+		return run(flags.Args())
 	}
+}
 
-	if deleteCommand.Parsed() {
-		database, err := connectToDatabase(dbpath)
+// withDatabase adapts a subcommand that reads its arguments itself and works
+// on the database.
+func withDatabase(name string, run func(args []string, database *sql.DB) error) func(args []string) error {
+	return withFlags(name, func(args []string) error {
+		database, err := openDatabase(databasePath())
 		if err != nil {
-			panic(err)
-		}
-		createTableIfNotExist(database)
-		if *deleteAllPtr {
-			deleteAll(database)
-		} else {
-			deleteCommand.PrintDefaults()
-			os.Exit(1)
+			return err
 		}
-		database.Close()
-	}
+		return run(args, database)
+	})
+}
+
+// databasePath is the database file to use, set by db in the configuration.
+func databasePath() string {
+	return configValue("db", fmt.Sprintf("%s/notectl.db", os.Getenv("HOME")))
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	quiet := newFlagSet("quiet")
+	quiet.SetOutput(ioutil.Discard)
+	quiet.Bool("v", false, "")
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"failure", errors.New("boom"), 1},
+		{"no match", errNoMatch, exitNoMatch},
+		{"bad flag", parseFlags(quiet, []string{"-bogus"}), 2},
+		{"help", parseFlags(quiet, []string{"-h"}), 0},
+	}
+	for _, test := range tests {
+		if got := exitCode(test.err); got != test.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", test.name, test.err, got, test.want)
+		}
+	}
+	if err := parseFlags(quiet, []string{"-v", "rest"}); err != nil {
+		t.Errorf("parseFlags rejected valid flags: %s", err)
+	}
+}
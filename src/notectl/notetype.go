@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// noteType is a kind of note defined in the config by type.<name>.fields,
+// type.<name>.tags and type.<name>.template settings.
+type noteType struct {
+	Name     string
+	Fields   []string
+	Tags     tagList
+	Template string
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// configuredTypes lists the note types defined in the config.
+func configuredTypes() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for key := range config {
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) == 3 && parts[0] == "type" && !seen[parts[1]] {
+			seen[parts[1]] = true
+			names = append(names, parts[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadNoteType reads a note type's definition from the config. The template
+// setting names a file, with ~ standing for the home directory.
+func loadNoteType(name string) (noteType, error) {
+	t := noteType{Name: name}
+	prefix := "type." + name + "."
+	defined := false
+	for key := range config {
+		if strings.HasPrefix(key, prefix) {
+			defined = true
+		}
+	}
+	if !defined && name == expenseType.Name {
+		return expenseType, nil
+	}
+	if !defined {
+		return t, fmt.Errorf("unknown note type %q, configured types: %s", name, strings.Join(configuredTypes(), ", "))
+	}
+	t.Fields = splitList(configValue(prefix+"fields", ""))
+	t.Tags = splitList(configValue(prefix+"tags", ""))
+	if path := configValue(prefix+"template", ""); path != "" {
+		if strings.HasPrefix(path, "~/") {
+			path = filepath.Join(os.Getenv("HOME"), path[2:])
+		}
+		template, err := ioutil.ReadFile(path)
+		if err != nil {
+			return t, fmt.Errorf("template for %s notes: %s", name, err)
+		}
+		t.Template = string(template)
+	}
+	return t, nil
+}
+
+// fillTemplate replaces {{field}} placeholders with metadata values.
+func (t noteType) fillTemplate(meta metaList) string {
+	text := t.Template
+	for key, value := range meta {
+		text = strings.Replace(text, "{{"+key+"}}", value, -1)
+	}
+	return text
+}
+
+// requireFields checks that meta has every field the type requires, asking
+// for missing ones when reader is not nil.
+func (t noteType) requireFields(meta metaList, reader *bufio.Reader) error {
+	for _, field := range t.Fields {
+		for meta[field] == "" {
+			if reader == nil {
+				return fmt.Errorf("%s notes require %s, pass -meta %s=<value>", t.Name, field, field)
+			}
+			value, err := prompt(reader, field+": ")
+			if err != nil {
+				return err
+			}
+			meta[field] = value
+		}
+	}
+	meta["type"] = t.Name
+	return nil
+}
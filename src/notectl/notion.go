@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	notionAPI     = "https://api.notion.com/v1"
+	notionVersion = "2022-06-28"
+	// notionTextLimit is the most characters Notion accepts in one rich text
+	// object.
+	notionTextLimit = 2000
+	// notionBlockLimit is the most blocks Notion accepts in one request.
+	notionBlockLimit = 100
+	// notionPageKey is the metadata key linking a note to its Notion page.
+	notionPageKey = "notion:page"
+)
+
+type notionClient struct {
+	Token  string
+	Client *http.Client
+}
+
+func newNotionClient() (notionClient, error) {
+	token := secretValue("notion.token")
+	if token == "" {
+		return notionClient{}, errors.New("set notion.token or NOTECTL_NOTION_TOKEN to an integration token, or store it with notectl auth set notion.token")
+	}
+	return notionClient{Token: token, Client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// call sends a request to the Notion API and decodes the reply into result.
+func (c notionClient) call(method string, path string, body interface{}, result interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, notionAPI+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Notion-Version", notionVersion)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("notion: %s: %s", resp.Status, failure.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+type notionRichText struct {
+	PlainText string `json:"plain_text"`
+}
+
+type notionBlock struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	HasChildren bool   `json:"has_children"`
+	Content     map[string]json.RawMessage
+}
+
+// notionText builds rich text objects for s, split to Notion's length limit.
+func notionText(s string) []interface{} {
+	var texts []interface{}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		size := len(runes)
+		if size > notionTextLimit {
+			size = notionTextLimit
+		}
+		texts = append(texts, map[string]interface{}{"type": "text", "text": map[string]string{"content": string(runes[:size])}})
+		runes = runes[size:]
+	}
+	return texts
+}
+
+// notionBlocks converts Markdown note text into Notion blocks, one per line.
+func notionBlocks(text string) []interface{} {
+	var blocks []interface{}
+	for _, line := range strings.Split(text, "\n") {
+		kind, content := "paragraph", line
+		switch {
+		case strings.HasPrefix(line, "### "):
+			kind, content = "heading_3", line[4:]
+		case strings.HasPrefix(line, "## "):
+			kind, content = "heading_2", line[3:]
+		case strings.HasPrefix(line, "# "):
+			kind, content = "heading_1", line[2:]
+		case strings.HasPrefix(line, "- [ ] "), strings.HasPrefix(line, "- [x] "):
+			blocks = append(blocks, map[string]interface{}{
+				"type":  "to_do",
+				"to_do": map[string]interface{}{"rich_text": notionText(line[6:]), "checked": line[3] == 'x'},
+			})
+			continue
+		case strings.HasPrefix(line, "- "), strings.HasPrefix(line, "* "):
+			kind, content = "bulleted_list_item", line[2:]
+		case strings.HasPrefix(line, "> "):
+			kind, content = "quote", line[2:]
+		}
+		blocks = append(blocks, map[string]interface{}{
+			"type": kind,
+			kind:   map[string]interface{}{"rich_text": notionText(content)},
+		})
+	}
+	return blocks
+}
+
+// notionTitle splits a note into the page title, taken from its first line,
+// and the remaining text.
+func notionTitle(text string) (string, string) {
+	lines := strings.SplitN(strings.TrimSpace(text), "\n", 2)
+	title := strings.TrimSpace(strings.TrimLeft(lines[0], "# "))
+	if len(lines) == 1 {
+		return title, ""
+	}
+	return title, strings.TrimLeft(lines[1], "\n")
+}
+
+// exportToNotion creates a page in a Notion database for every note matching
+// query that has not been exported before. Tags become a multi-select property.
+func exportToNotion(query noteQuery, databaseID string, database *sql.DB) error {
+	client, err := newNotionClient()
+	if err != nil {
+		return err
+	}
+	query.Filter.Add("id NOT IN (SELECT note_id FROM metadata WHERE key = '" + notionPageKey + "')")
+	notes, err := findNotes(query, database)
+	if err != nil {
+		return err
+	}
+	titleProperty := configValue("notion.title_property", "Name")
+	tagsProperty := configValue("notion.tags_property", "Tags")
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	for i, n := range notes {
+		// Exported notes are linked to their pages, so a rerun picks up
+		// where an interrupted export stopped.
+		if interrupted(interrupt) {
+			return fmt.Errorf("interrupted after exporting %d of %d notes, run the export again to continue", i, len(notes))
+		}
+		title, body := notionTitle(n.Text)
+		var tags []interface{}
+		for _, tag := range n.Tags {
+			// Notion does not allow commas in select option names.
+			tags = append(tags, map[string]string{"name": strings.Replace(tag, ",", " ", -1)})
+		}
+		var blocks []interface{}
+		if body != "" {
+			blocks = notionBlocks(body)
+		}
+		first := blocks
+		if len(first) > notionBlockLimit {
+			first = first[:notionBlockLimit]
+		}
+		page := map[string]interface{}{
+			"parent": map[string]string{"database_id": databaseID},
+			"properties": map[string]interface{}{
+				titleProperty: map[string]interface{}{"title": notionText(title)},
+				tagsProperty:  map[string]interface{}{"multi_select": tags},
+			},
+			"children": first,
+		}
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := client.call("POST", "/pages", page, &created); err != nil {
+			return fmt.Errorf("note %d: %s", n.ID, err)
+		}
+		for start := len(first); start < len(blocks); start += notionBlockLimit {
+			end := start + notionBlockLimit
+			if end > len(blocks) {
+				end = len(blocks)
+			}
+			if err := client.call("PATCH", "/blocks/"+created.ID+"/children", map[string]interface{}{"children": blocks[start:end]}, nil); err != nil {
+				return fmt.Errorf("note %d: %s", n.ID, err)
+			}
+		}
+		if err := setNoteMeta(n.ID, notionPageKey, created.ID, database); err != nil {
+			return err
+		}
+		fmt.Printf("Exported note %d to Notion page %s\n", n.ID, created.ID)
+	}
+	return nil
+}
+
+// blockText renders a Notion block back into a line of Markdown.
+func blockText(block notionBlock) string {
+	var content struct {
+		RichText []notionRichText `json:"rich_text"`
+		Checked  bool             `json:"checked"`
+	}
+	json.Unmarshal(block.Content[block.Type], &content)
+	var text strings.Builder
+	for _, rt := range content.RichText {
+		text.WriteString(rt.PlainText)
+	}
+	switch block.Type {
+	case "heading_1":
+		return "# " + text.String()
+	case "heading_2":
+		return "## " + text.String()
+	case "heading_3":
+		return "### " + text.String()
+	case "bulleted_list_item":
+		return "- " + text.String()
+	case "numbered_list_item":
+		return "1. " + text.String()
+	case "quote":
+		return "> " + text.String()
+	case "code":
+		return "```\n" + text.String() + "\n```"
+	case "to_do":
+		if content.Checked {
+			return "- [x] " + text.String()
+		}
+		return "- [ ] " + text.String()
+	}
+	return text.String()
+}
+
+// pageText fetches every top level block of a page as Markdown.
+func (c notionClient) pageText(pageID string) (string, error) {
+	var lines []string
+	cursor := ""
+	for {
+		path := fmt.Sprintf("/blocks/%s/children?page_size=%d", pageID, notionBlockLimit)
+		if cursor != "" {
+			path += "&start_cursor=" + cursor
+		}
+		var page struct {
+			Results    []json.RawMessage `json:"results"`
+			HasMore    bool              `json:"has_more"`
+			NextCursor string            `json:"next_cursor"`
+		}
+		if err := c.call("GET", path, nil, &page); err != nil {
+			return "", err
+		}
+		for _, raw := range page.Results {
+			var block notionBlock
+			json.Unmarshal(raw, &block)
+			json.Unmarshal(raw, &block.Content)
+			lines = append(lines, blockText(block))
+		}
+		if !page.HasMore {
+			return strings.Join(lines, "\n"), nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// importFromNotion saves every page of a Notion database that is not already
+// linked to a note.
+func importFromNotion(databaseID string, database *sql.DB) error {
+	client, err := newNotionClient()
+	if err != nil {
+		return err
+	}
+	titleProperty := configValue("notion.title_property", "Name")
+	tagsProperty := configValue("notion.tags_property", "Tags")
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	imported := 0
+	cursor := ""
+	for {
+		body := map[string]interface{}{"page_size": notionBlockLimit}
+		if cursor != "" {
+			body["start_cursor"] = cursor
+		}
+		var result struct {
+			Results []struct {
+				ID          string    `json:"id"`
+				CreatedTime time.Time `json:"created_time"`
+				Properties  map[string]struct {
+					Title       []notionRichText `json:"title"`
+					MultiSelect []struct {
+						Name string `json:"name"`
+					} `json:"multi_select"`
+				} `json:"properties"`
+			} `json:"results"`
+			HasMore    bool   `json:"has_more"`
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := client.call("POST", "/databases/"+databaseID+"/query", body, &result); err != nil {
+			return err
+		}
+		for _, page := range result.Results {
+			// Imported pages are linked to their notes, so a rerun picks up
+			// where an interrupted import stopped.
+			if interrupted(interrupt) {
+				return fmt.Errorf("interrupted after importing %d pages, run the import again to continue", imported)
+			}
+			var existing int
+			err := database.QueryRow("SELECT note_id FROM metadata WHERE key = (?) AND value = (?)", notionPageKey, page.ID).Scan(&existing)
+			if err == nil {
+				continue
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+			var title strings.Builder
+			for _, rt := range page.Properties[titleProperty].Title {
+				title.WriteString(rt.PlainText)
+			}
+			var tags tagList
+			for _, option := range page.Properties[tagsProperty].MultiSelect {
+				tags = append(tags, strings.Replace(strings.ToLower(option.Name), " ", "-", -1))
+			}
+			text, err := client.pageText(page.ID)
+			if err != nil {
+				return err
+			}
+			if title.Len() > 0 {
+				text = strings.TrimRight("# "+title.String()+"\n\n"+text, "\n")
+			}
+			n := note{
+				Time: page.CreatedTime,
+				Text: text,
+				Tags: addTags(tagList{"generic"}, tags),
+				Meta: metaList{notionPageKey: page.ID},
+			}
+			if err := n.Save(database); err != nil {
+				return err
+			}
+			fmt.Printf("Imported Notion page %s as note %d\n", page.ID, n.ID)
+			imported++
+		}
+		if !result.HasMore {
+			return nil
+		}
+		cursor = result.NextCursor
+	}
+}
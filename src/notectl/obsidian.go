@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// obsidianSyncKey is the metadata key holding the hash of a note as of its
+// last sync with the vault, used to tell which side changed since.
+const obsidianSyncKey = "obsidian:synced"
+
+var unsafeFileChars = regexp.MustCompile(`[^\pL\pN _-]+`)
+
+// vaultNote is a note as stored in a vault file.
+type vaultNote struct {
+	Path   string
+	UUID   string
+	Tags   tagList
+	Status string
+	Text   string
+}
+
+// syncHash identifies the parts of a note that are kept in step with the
+// vault. Trailing newlines are ignored since files always end with one.
+func syncHash(text string, tags tagList, status string) string {
+	return notes.Checksum(strings.TrimRight(text, "\n") + "\x00" + tags.String() + "\x00" + status)
+}
+
+// vaultFileName names a note's file after its first line and ID.
+func vaultFileName(n note) string {
+	title, _ := notionTitle(n.Text)
+	title = strings.TrimSpace(unsafeFileChars.ReplaceAllString(title, " "))
+	if runes := []rune(title); len(runes) > 60 {
+		title = strings.TrimSpace(string(runes[:60]))
+	}
+	if title == "" {
+		title = "note"
+	}
+	return fmt.Sprintf("%s-%d.md", title, n.ID)
+}
+
+// formatVaultNote writes a note with YAML front matter that Obsidian shows as
+// properties and that links the file back to the note.
+func formatVaultNote(n note) string {
+	var b strings.Builder
+	b.WriteString("---\n")
+	b.WriteString("notectl_uuid: " + n.UUID + "\n")
+	b.WriteString("notectl_id: " + strconv.Itoa(n.ID) + "\n")
+	b.WriteString("created: " + n.Time.Format(time.RFC3339) + "\n")
+	b.WriteString("tags: [" + strings.Join(n.Tags, ", ") + "]\n")
+	if n.Status != "" {
+		b.WriteString("status: " + n.Status + "\n")
+	}
+	b.WriteString("---\n")
+	b.WriteString(n.Text)
+	if !strings.HasSuffix(n.Text, "\n") {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// parseVaultNote reads a vault file, splitting off its front matter. Files
+// created in Obsidian have no UUID yet.
+func parseVaultNote(path string) (vaultNote, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return vaultNote{}, err
+	}
+	v := vaultNote{Path: path, Text: string(data)}
+	if !strings.HasPrefix(v.Text, "---\n") {
+		return v, nil
+	}
+	end := strings.Index(v.Text[4:], "\n---\n")
+	if end < 0 {
+		return v, nil
+	}
+	scanner := bufio.NewScanner(strings.NewReader(v.Text[4 : 4+end]))
+	v.Text = v.Text[4+end+5:]
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case "notectl_uuid":
+			v.UUID = value
+		case "status":
+			v.Status = value
+		case "tags":
+			for _, tag := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					v.Tags = append(v.Tags, tag)
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+// syncVault brings the vault folder and the database in step. Notes changed
+// in the database are rewritten to their files, files edited in Obsidian are
+// written back to their notes, and new files become notes. When both sides
+// changed, the note is left alone and the conflict reported.
+//
+// A note synced before whose file is gone was deleted in Obsidian, and is
+// deleted too unless it changed in the database since, in which case its file
+// is written again. A folder without any synced files is taken to be new, so
+// pointing the bridge at an empty folder fills it rather than deleting notes.
+func syncVault(folder string, database *sql.DB) error {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	files, err := filepath.Glob(filepath.Join(folder, "*.md"))
+	if err != nil {
+		return err
+	}
+	vault := make(map[string]vaultNote)
+	linked := 0
+	for _, path := range files {
+		v, err := parseVaultNote(path)
+		if err != nil {
+			return err
+		}
+		if v.UUID == "" {
+			n := note{Time: time.Now(), Text: v.Text, Tags: addTags(tagList{"generic"}, v.Tags), Status: v.Status}
+			if err := n.Save(database); err != nil {
+				return err
+			}
+			if err := setNoteMeta(n.ID, obsidianSyncKey, syncHash(n.Text, n.Tags, n.Status), database); err != nil {
+				return err
+			}
+			// Rewrite the file so it carries the new note's UUID.
+			if err := writeFileAtomic(path, []byte(formatVaultNote(n))); err != nil {
+				return err
+			}
+			fmt.Printf("Created note %d from %s\n", n.ID, filepath.Base(path))
+			v = vaultNote{Path: path, UUID: n.UUID, Tags: n.Tags, Status: n.Status, Text: n.Text}
+		} else {
+			linked++
+		}
+		vault[v.UUID] = v
+	}
+
+	found, err := findNotes(noteQuery{}, database)
+	if err != nil {
+		return err
+	}
+	var deleted []int
+	for _, n := range found {
+		if n.UUID == "" {
+			// Notes created before UUIDs were stored get one now.
+			if n.UUID, err = noteUUID(n.ID, database); err != nil {
+				return err
+			}
+		}
+		meta, err := getNoteMeta(n.ID, database)
+		if err != nil {
+			return err
+		}
+		synced := meta[obsidianSyncKey]
+		current := syncHash(n.Text, n.Tags, n.Status)
+		v, inVault := vault[n.UUID]
+		if !inVault && synced != "" && synced == current && linked > 0 {
+			deleted = append(deleted, n.ID)
+			fmt.Printf("Deleting note %d, its file was removed from the vault\n", n.ID)
+			continue
+		}
+		if !inVault {
+			path := filepath.Join(folder, vaultFileName(n))
+			if err := writeFileAtomic(path, []byte(formatVaultNote(n))); err != nil {
+				return err
+			}
+		} else {
+			edited := syncHash(v.Text, v.Tags, v.Status)
+			switch {
+			case edited == synced && current != synced:
+				if err := writeFileAtomic(v.Path, []byte(formatVaultNote(n))); err != nil {
+					return err
+				}
+				fmt.Printf("Updated %s from note %d\n", filepath.Base(v.Path), n.ID)
+			case edited != synced && current == synced && edited != current:
+				if v.Status != "" && !validStatus(v.Status) {
+					fmt.Printf("Skipping %s: unknown status %q\n", filepath.Base(v.Path), v.Status)
+					continue
+				}
+				if err := updateNoteText(n.ID, v.Text, database); err != nil {
+					return err
+				}
+				if err := setNoteTags(n.ID, v.Tags, database); err != nil {
+					return err
+				}
+				if err := setNoteStatus(n.ID, v.Status, database); err != nil {
+					return err
+				}
+				current = edited
+				fmt.Printf("Updated note %d from %s\n", n.ID, filepath.Base(v.Path))
+			case edited != synced && current != synced && edited != current:
+				fmt.Printf("Conflict: note %d and %s both changed, resolve by editing one to match the other\n", n.ID, filepath.Base(v.Path))
+				continue
+			}
+		}
+		if current != synced {
+			if err := setNoteMeta(n.ID, obsidianSyncKey, current, database); err != nil {
+				return err
+			}
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+	return deleteNotes(deleted, database)
+}
+
+// runBridge dispatches the "bridge obsidian" subcommand.
+func runBridge(args []string, database *sql.DB) error {
+	usage := "usage: notectl bridge obsidian -vault <dir> [-folder name] [-interval 5s]"
+	if len(args) == 0 || args[0] != "obsidian" {
+		return errors.New(usage)
+	}
+	obsidianCommand := newFlagSet("bridge obsidian")
+	vaultPtr := obsidianCommand.String("vault", configValue("obsidian.vault", ""), "Path to the Obsidian vault.")
+	folderPtr := obsidianCommand.String("folder", configValue("obsidian.folder", "notectl"), "Folder inside the vault that holds notes.")
+	intervalPtr := obsidianCommand.Duration("interval", 5*time.Second, "How often to check for changes, or 0 to sync once and exit.")
+	if err := parseFlags(obsidianCommand, args[1:]); err != nil {
+		return err
+	}
+	if *vaultPtr == "" {
+		return errors.New(usage)
+	}
+	folder := filepath.Join(*vaultPtr, *folderPtr)
+	if *intervalPtr == 0 {
+		return syncVault(folder, database)
+	}
+
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	fmt.Printf("Syncing notes with %s every %s, Ctrl-C to stop\n", folder, *intervalPtr)
+	ticker := time.NewTicker(*intervalPtr)
+	defer ticker.Stop()
+	for {
+		if err := syncVault(folder, database); err != nil {
+			return err
+		}
+		select {
+		case <-interrupt:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
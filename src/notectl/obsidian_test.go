@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// vaultFiles returns the Markdown files in a vault folder.
+func vaultFiles(t *testing.T, folder string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(folder, "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestSyncVaultImportsNewFilesOnce(t *testing.T) {
+	database := openTestDatabase(t)
+	folder, err := ioutil.TempDir("", "notectl-vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	saveTestNote(t, database, time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC), "from notectl")
+	if err := ioutil.WriteFile(filepath.Join(folder, "from obsidian.md"), []byte("from obsidian\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := syncVault(folder, database); err != nil {
+			t.Fatal(err)
+		}
+		if files := vaultFiles(t, folder); len(files) != 2 {
+			t.Fatalf("sync %d left %d files, want 2: %v", i+1, len(files), files)
+		}
+		found, err := findNotes(noteQuery{}, database)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 2 {
+			t.Fatalf("sync %d left %d notes, want 2", i+1, len(found))
+		}
+	}
+}
+
+func TestSyncVaultDeletesNotesRemovedInObsidian(t *testing.T) {
+	database := openTestDatabase(t)
+	folder, err := ioutil.TempDir("", "notectl-vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	at := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	removed := saveTestNote(t, database, at, "removed in obsidian")
+	edited := saveTestNote(t, database, at, "edited in notectl")
+	saveTestNote(t, database, at, "kept")
+	if err := syncVault(folder, database); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []note{removed, edited} {
+		if err := os.Remove(filepath.Join(folder, vaultFileName(n))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := updateNoteText(edited.ID, "edited in notectl, again", database); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncVault(folder, database); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getNoteText(removed.ID, database); err == nil {
+		t.Error("note whose file was removed was kept")
+	}
+	if _, err := getNoteText(edited.ID, database); err != nil {
+		t.Errorf("note changed since its file was removed was deleted: %s", err)
+	}
+	if files := vaultFiles(t, folder); len(files) != 2 {
+		t.Errorf("%d files after sync, want 2: %v", len(files), files)
+	}
+
+	// An empty folder is filled rather than taken as every note deleted.
+	empty := filepath.Join(folder, "new")
+	if err := syncVault(empty, database); err != nil {
+		t.Fatal(err)
+	}
+	if files := vaultFiles(t, empty); len(files) != 2 {
+		t.Errorf("%d files in a new folder, want 2", len(files))
+	}
+}
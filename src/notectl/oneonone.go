@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// oneOnOneTag marks notes taken in 1:1s, which bound the next prep document.
+const oneOnOneTag = "oneonone"
+
+// oneOnOnePrep compiles what happened since the last 1:1 with person: notes
+// mentioning them and open checkboxes assigned to them with an @mention.
+func oneOnOnePrep(person string, database *sql.DB) (string, error) {
+	slug := personSlug(strings.TrimPrefix(person, "@"))
+	var since int64
+	err := database.QueryRow("SELECT COALESCE(MAX(timestamp), 0) FROM notes WHERE "+tagMatchClause+" AND id IN (SELECT note_id FROM mentions WHERE person = (?))", oneOnOneTag, slug).Scan(&since)
+	if err != nil {
+		return "", err
+	}
+	var query noteQuery
+	query.Filter.Add("id IN (SELECT note_id FROM mentions WHERE person = (?))", slug)
+	found, err := findNotes(query, database)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# 1:1 with @%s, %s\n\n", slug, formatDate(time.Now()))
+	if since > 0 {
+		fmt.Fprintf(&b, "Last 1:1: %s\n\n", formatDate(time.Unix(since, 0)))
+	}
+	b.WriteString("## Open action items\n\n")
+	items := 0
+	for _, n := range found {
+		if n.Status == "done" || n.Status == "archived" {
+			continue
+		}
+		for _, line := range strings.Split(n.Text, "\n") {
+			m := openCheckboxPattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			for _, mentioned := range notes.ParseMentions(m[1]) {
+				if mentioned == slug {
+					fmt.Fprintf(&b, "- [ ] %s (#%d)\n", m[1], n.ID)
+					items++
+					break
+				}
+			}
+		}
+	}
+	if items == 0 {
+		b.WriteString("None.\n")
+	}
+	b.WriteString("\n## Since last time\n\n")
+	recent := 0
+	for _, n := range found {
+		if n.Time.Unix() <= since || containsTag(n.Tags, oneOnOneTag) {
+			continue
+		}
+		fmt.Fprintf(&b, "- %s #%d: %s\n", formatDate(n.Time), n.ID, firstLine(n.Text, 80))
+		recent++
+	}
+	if recent == 0 {
+		b.WriteString("Nothing new.\n")
+	}
+	b.WriteString("\n## Notes\n\n\n## Action items\n\n- [ ] \n")
+	return b.String(), nil
+}
+
+func containsTag(tags tagList, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// oneOnOne prints the prep document, or with save opens it in the editor and
+// stores the result as the 1:1 note that the next prep starts from.
+func oneOnOne(person string, save bool, database *sql.DB) error {
+	prep, err := oneOnOnePrep(person, database)
+	if err != nil {
+		return err
+	}
+	if !save {
+		fmt.Print(prep)
+		return nil
+	}
+	text, err := captureFromEditorWithTemplate(prep)
+	if err != nil {
+		return err
+	}
+	slug := personSlug(strings.TrimPrefix(person, "@"))
+	// The 1:1 counts as mentioning the person even if the heading is edited.
+	n := note{Time: time.Now(), Text: string(text), Tags: tagList{oneOnOneTag}, Meta: metaList{"person": slug, notes.MentionKeyPrefix + slug: slug}}
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	fmt.Printf("Saved 1:1 with %s as note %d\n", slug, n.ID)
+	return nil
+}
+
+// runOneOnOne prepares, and with -save records, a 1:1 with a person.
+func runOneOnOne(args []string) error {
+	oneOnOneCommand := newFlagSet("oneonone")
+	oneOnOneSavePtr := oneOnOneCommand.Bool("save", false, "Open the prep document in the editor and save it as the 1:1 note.")
+	people, err := parseInterspersed(oneOnOneCommand, args)
+	if err != nil {
+		return err
+	}
+	if len(people) != 1 {
+		return errors.New("usage: notectl oneonone [-save] <person>")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return oneOnOne(people[0], *oneOnOneSavePtr, database)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// openInBrowser opens a file or URL with the desktop's default handler.
+func openInBrowser(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
+
+// openNote renders a note to a temporary HTML file and opens it in the
+// default browser.
+func openNote(id int, database *sql.DB) error {
+	n, _, err := loadNote(id, database)
+	if err != nil {
+		return err
+	}
+	header := fmt.Sprintf("Note %d - %s", n.ID, formatDateTime(n.Time))
+	if len(n.Tags) > 0 {
+		header += " - " + strings.Join(n.Tags, ", ")
+	}
+	title, _ := notionTitle(n.Text)
+	file, err := ioutil.TempFile("", fmt.Sprintf("notectl-%d-*.html", n.ID))
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(renderHTMLPage(title, header, n.Text)); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// The file is left behind for the browser to read; the OS cleans up
+	// its temporary directory.
+	return openInBrowser("file://" + file.Name())
+}
+
+// runOpen opens a note rendered as HTML in the browser.
+func runOpen(args []string) error {
+	openCommand := newFlagSet("open")
+	openIDPtr := openCommand.Int("i", -1, "The ID of the note to open in the browser.")
+	if err := parseFlags(openCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	if openCommand.NArg() == 1 && *openIDPtr == -1 {
+		if *openIDPtr, err = resolveNoteRef(openCommand.Arg(0), database); err != nil {
+			return err
+		}
+	}
+	if err := validateID(*openIDPtr); err != nil {
+		return err
+	}
+	return openNote(*openIDPtr, database)
+}
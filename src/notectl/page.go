@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// pageCursor marks the last note of a page by its timestamp and ID, written
+// as <timestamp>.<id>.
+type pageCursor struct {
+	Timestamp int64
+	ID        int
+}
+
+func parseCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	parts := strings.SplitN(s, ".", 2)
+	if len(parts) != 2 {
+		return c, fmt.Errorf("invalid cursor %q, expected <timestamp>.<id>", s)
+	}
+	var err1, err2 error
+	c.Timestamp, err1 = strconv.ParseInt(parts[0], 10, 64)
+	c.ID, err2 = strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return c, fmt.Errorf("invalid cursor %q, expected <timestamp>.<id>", s)
+	}
+	return c, nil
+}
+
+func (c pageCursor) String() string {
+	return fmt.Sprintf("%d.%d", c.Timestamp, c.ID)
+}
+
+// notePage selects one page of a note listing. Pages use keyset pagination:
+// each continues after the timestamp and ID of the last note shown instead of
+// skipping an OFFSET, so deep pages stay fast and notes added in the meantime
+// do not shift the pages that follow.
+type notePage struct {
+	Limit int
+	After *pageCursor
+}
+
+// afterCursor restricts a filter to the notes ordered after c.
+func afterCursor(filter *queryFilter, c pageCursor) {
+	filter.Add("(timestamp > (?) OR (timestamp = (?) AND id > (?)))", c.Timestamp, c.Timestamp, c.ID)
+}
+
+// listNotes prints the page of notes matching filter, oldest first. When the
+// page is full and more notes follow, the cursor to continue from is printed
+// on stderr.
+func listNotes(filter queryFilter, page notePage, database *sql.DB) error {
+	base := filter
+	if page.After != nil {
+		afterCursor(&filter, *page.After)
+	}
+	query := "SELECT " + noteListColumns() + " FROM notes" + filter.Where() + " ORDER BY timestamp, id"
+	if page.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", page.Limit)
+	}
+	rows, err := database.Query(query, filter.Args...)
+	if err != nil {
+		return err
+	}
+	last, count, err := printNoteRows(rows, listPreview)
+	rows.Close()
+	if err == nil && count == 0 {
+		return errNoMatch
+	}
+	if err != nil || page.Limit == 0 || count < page.Limit {
+		return err
+	}
+	afterCursor(&base, last)
+	var more bool
+	if err := database.QueryRow("SELECT EXISTS (SELECT 1 FROM notes"+base.Where()+")", base.Args...).Scan(&more); err != nil {
+		return err
+	}
+	if more {
+		fmt.Fprintf(os.Stderr, "More notes follow, continue with -after %s\n", last)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// personTag marks the note that holds a person's record.
+const personTag = "person"
+
+// personSlug is the handle used to @mention a person, e.g. "Alice Smith" is @alice-smith.
+func personSlug(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), "-"))
+}
+
+// findPerson returns the ID of the record note for the given slug.
+func findPerson(slug string, database *sql.DB) (int, error) {
+	var id int
+	err := database.QueryRow("SELECT note_id FROM metadata WHERE key = 'slug' AND value = (?) AND note_id IN (SELECT id FROM notes WHERE "+tagMatchClause+")", strings.ToLower(slug), personTag).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no person %q, add them with: notectl person add <name>", slug)
+	}
+	return id, err
+}
+
+func addPerson(name string, meta metaList, database *sql.DB) error {
+	slug := personSlug(name)
+	if _, err := findPerson(slug, database); err == nil {
+		return fmt.Errorf("person @%s already exists", slug)
+	}
+	if meta == nil {
+		meta = make(metaList)
+	}
+	meta["name"] = name
+	meta["slug"] = slug
+	n := note{Time: time.Now(), Text: name, Tags: tagList{personTag}, Meta: meta}
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	fmt.Printf("Added %s, mention them in notes as @%s\n", name, slug)
+	return nil
+}
+
+func showPerson(slug string, database *sql.DB) error {
+	slug = strings.TrimPrefix(strings.ToLower(slug), "@")
+	id, err := findPerson(slug, database)
+	if err != nil {
+		return err
+	}
+	meta, err := getNoteMeta(id, database)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s (@%s)\n", meta["name"], slug)
+	var keys []string
+	for key := range meta {
+		if key != "name" && key != "slug" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %s: %s\n", key, meta[key])
+	}
+	fmt.Println("\nInteractions:")
+	rows, err := database.Query("SELECT "+noteListColumns()+" FROM notes WHERE id IN (SELECT note_id FROM mentions WHERE person = (?)) ORDER BY timestamp", slug)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return printRows(rows)
+}
+
+func listPeople(database *sql.DB) error {
+	rows, err := database.Query("SELECT m.value, (SELECT COUNT(*) FROM mentions WHERE person = m.value) FROM metadata m JOIN notes ON notes.id = m.note_id WHERE m.key = 'slug' AND "+tagMatchClause+" ORDER BY m.value", personTag)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var slug string
+	var count int
+	for rows.Next() {
+		if err := rows.Scan(&slug, &count); err != nil {
+			return err
+		}
+		fmt.Printf("@%s (%d notes)\n", slug, count)
+	}
+	return rows.Err()
+}
+
+// runPerson dispatches the "person add", "person show" and "person list" subcommands.
+func runPerson(args []string, database *sql.DB) error {
+	usage := "usage: notectl person <add <name> [-meta key=value]...|show <name>|list>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "add":
+		addCommand := newFlagSet("person add")
+		var meta metaList
+		addCommand.Var(&meta, "meta", "Metadata in the form key=value, may be repeated.")
+		names, err := parseInterspersed(addCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return errors.New(usage)
+		}
+		return addPerson(strings.Join(names, " "), meta, database)
+	case "show":
+		if len(args) == 2 {
+			return showPerson(args[1], database)
+		}
+	case "list":
+		return listPeople(database)
+	}
+	return errors.New(usage)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// pingTimeout bounds how long ping waits for the database.
+const pingTimeout = 5 * time.Second
+
+type healthCheck struct {
+	Name  string
+	Check func(ctx context.Context, database *sql.DB) error
+}
+
+var healthChecks = []healthCheck{
+	{"database reachable", checkReachable},
+	{"migrations applied", checkMigrations},
+	{"integrity", checkIntegrity},
+}
+
+func checkReachable(ctx context.Context, database *sql.DB) error {
+	if err := database.PingContext(ctx); err != nil {
+		return err
+	}
+	var one int
+	return database.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
+
+// checkMigrations confirms the schema is current without upgrading it, so it
+// reports databases that no command has touched since notectl was updated.
+func checkMigrations(ctx context.Context, database *sql.DB) error {
+	rows, err := database.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		return err
+	}
+	tables := make(map[string]bool)
+	var name string
+	for rows.Next() {
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables[name] = true
+	}
+	rows.Close()
+	var missing []string
+	for _, table := range []string{"notes", "metadata", "mentions"} {
+		if !tables[table] {
+			missing = append(missing, "table "+table)
+		}
+	}
+	if tables["notes"] {
+		rows, err := database.QueryContext(ctx, "PRAGMA table_info(notes)")
+		if err != nil {
+			return err
+		}
+		columns := make(map[string]bool)
+		var cid, notnull, pk int
+		var ctype string
+		var dflt sql.NullString
+		for rows.Next() {
+			if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+				rows.Close()
+				return err
+			}
+			columns[name] = true
+		}
+		rows.Close()
+		for _, migration := range notes.ColumnMigrations {
+			if !columns[migration.Column] {
+				missing = append(missing, "column notes."+migration.Column)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s; any other notectl command will apply them", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func checkIntegrity(ctx context.Context, database *sql.DB) error {
+	var result string
+	if err := database.QueryRowContext(ctx, "PRAGMA quick_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("quick_check reported: %s", result)
+	}
+	return nil
+}
+
+// ping runs every health check against the database, printing one line per
+// check, and reports whether all of them passed.
+func ping(dbpath string, database *sql.DB) bool {
+	if _, err := os.Stat(dbpath); err != nil {
+		fmt.Printf("FAIL database file: %v\n", err)
+		return false
+	}
+	healthy := true
+	for _, check := range healthChecks {
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		start := time.Now()
+		err := check.Check(ctx, database)
+		cancel()
+		if err != nil {
+			fmt.Printf("FAIL %s: %v\n", check.Name, err)
+			healthy = false
+			continue
+		}
+		fmt.Printf("ok   %s (%s)\n", check.Name, time.Since(start).Round(time.Millisecond))
+	}
+	return healthy
+}
+
+// runPing checks that the database can be opened and is healthy.
+func runPing(args []string) error {
+	pingCommand := newFlagSet("ping")
+	if err := parseFlags(pingCommand, args); err != nil {
+		return err
+	}
+	database, err := connectToDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	healthy := ping(databasePath(), database)
+	database.Close()
+	if !healthy {
+		return exitStatus(1)
+	}
+	return nil
+}
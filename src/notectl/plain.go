@@ -0,0 +1,20 @@
+package main
+
+// plainOutput, set by the global -plain flag or plain = on, guarantees output
+// suited to screen readers and braille displays: no color, no box-drawing,
+// block or other symbol glyphs, and one item per line instead of side by
+// side layouts.
+var plainOutput bool
+
+// glyph returns fancy, or plain when plainOutput is set.
+func glyph(fancy string, plain string) string {
+	if plainOutput {
+		return plain
+	}
+	return fancy
+}
+
+// ellipsis marks text that was cut short.
+func ellipsis() string {
+	return glyph("…", "...")
+}
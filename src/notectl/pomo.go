@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// notify raises a desktop notification where a notifier is available and
+// always rings the terminal bell.
+func notify(title string, message string) {
+	fmt.Print("\a")
+	switch runtime.GOOS {
+	case "darwin":
+		exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", message, title)).Run()
+	case "linux":
+		if path, err := exec.LookPath("notify-send"); err == nil {
+			exec.Command(path, title, message).Run()
+		}
+	}
+}
+
+// runPomodoro counts down the given duration and saves a note recording the
+// task and whether the timer ran to completion or was interrupted.
+func runPomodoro(length time.Duration, task string, tags tagList, database *sql.DB) error {
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+
+	start := time.Now()
+	end := start.Add(length)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	completed := false
+	fmt.Printf("Working on %q for %s, Ctrl-C to stop early\n", task, length)
+loop:
+	for {
+		remaining := time.Until(end).Round(time.Second)
+		if remaining <= 0 {
+			completed = true
+			break
+		}
+		fmt.Printf("\r%s remaining ", remaining)
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			break loop
+		}
+	}
+	fmt.Println()
+
+	worked := time.Since(start).Round(time.Second)
+	n := note{Time: start, Tags: tags}
+	if completed {
+		notify("notectl", fmt.Sprintf("Pomodoro finished: %s", task))
+		n.Text = fmt.Sprintf("Pomodoro: %s (%s, completed)", task, length)
+		n.Status = "done"
+	} else {
+		n.Text = fmt.Sprintf("Pomodoro: %s (%s of %s, interrupted)", task, worked, length)
+		n.Status = "todo"
+	}
+	n.PrintConsole()
+	return n.Save(database)
+}
+
+// runPomo runs a pomodoro timer and logs it as a note.
+func runPomo(args []string) error {
+	pomoCommand := newFlagSet("pomo")
+	var pomoTagList tagList
+	pomoCommand.Var(&pomoTagList, "t", "A comma-delimited list of tags, defaults to pomodoro.")
+	if err := parseFlags(pomoCommand, args); err != nil {
+		return err
+	}
+	if pomoCommand.NArg() < 2 {
+		return errors.New("usage: notectl pomo [-t tags] <duration> <task>")
+	}
+	length, err := time.ParseDuration(pomoCommand.Arg(0))
+	if err != nil || length <= 0 {
+		return fmt.Errorf("invalid duration %q, expected something like 25m", pomoCommand.Arg(0))
+	}
+	if len(pomoTagList) == 0 {
+		pomoTagList.Set("pomodoro")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return runPomodoro(length, strings.Join(pomoCommand.Args()[1:], " "), pomoTagList, database)
+}
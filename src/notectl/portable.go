@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// portableFormat identifies single-note export files.
+const portableFormat = "notectl-note"
+
+// encryptedMagic starts every encrypted export file.
+var encryptedMagic = []byte("NOTECTL-ENC1\n")
+
+// pbkdf2Iterations is the work factor used to derive export encryption keys.
+const pbkdf2Iterations = 200000
+
+// portableNote is the JSON layout of a single exported note. Attachment data
+// is base64 encoded by encoding/json.
+type portableNote struct {
+	Format      string               `json:"format"`
+	Version     int                  `json:"version"`
+	UUID        string               `json:"uuid"`
+	Created     time.Time            `json:"created"`
+	Text        string               `json:"text"`
+	Tags        []string             `json:"tags"`
+	Status      string               `json:"status,omitempty"`
+	Meta        map[string]string    `json:"meta,omitempty"`
+	Attachments []portableAttachment `json:"attachments,omitempty"`
+}
+
+type portableAttachment struct {
+	Name    string    `json:"name"`
+	Mime    string    `json:"mime"`
+	Created time.Time `json:"created"`
+	Data    []byte    `json:"data"`
+}
+
+// noteUUID returns a note's UUID, assigning one to notes created before UUIDs
+// were stored.
+func noteUUID(id int, database *sql.DB) (string, error) {
+	var uuid sql.NullString
+	err := database.QueryRow("SELECT uuid FROM notes WHERE id = (?)", id).Scan(&uuid)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("no note with ID %d", id)
+	}
+	if err != nil {
+		return "", err
+	}
+	if uuid.String != "" {
+		return uuid.String, nil
+	}
+	uuid.String = notes.NewUUID()
+	return uuid.String, notes.UpdateUUID(database, id, uuid.String)
+}
+
+// exportNote writes a note, its metadata and attachments to path, encrypting
+// the file when a passphrase is given.
+func exportNote(id int, path string, passphrase string, database *sql.DB) error {
+	n, meta, err := loadNote(id, database)
+	if err != nil {
+		return err
+	}
+	uuid, err := noteUUID(id, database)
+	if err != nil {
+		return err
+	}
+	exported := portableNote{
+		Format:  portableFormat,
+		Version: 1,
+		UUID:    uuid,
+		Created: n.Time,
+		Text:    n.Text,
+		Tags:    n.Tags,
+		Status:  n.Status,
+		Meta:    scrubMeta(meta),
+	}
+	attachments, err := listAttachments(id, database)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		_, data, err := readAttachment(a.ID, database)
+		if err != nil {
+			return err
+		}
+		exported.Attachments = append(exported.Attachments, portableAttachment{a.Name, a.Mime, a.Created, scrubAttachment(a.Mime, data)})
+	}
+	data, err := json.MarshalIndent(exported, "", "  ")
+	if err != nil {
+		return err
+	}
+	if passphrase != "" {
+		if data, err = encryptExport(data, passphrase); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Exported note %d to %s\n", id, path)
+	return nil
+}
+
+// importNote reads a file written by exportNote and saves it as a new note,
+// keeping its UUID. Notes that already exist locally are not duplicated.
+func importNote(path string, database *sql.DB) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(data, encryptedMagic) {
+		passphrase, err := exportPassphrase()
+		if err != nil {
+			return err
+		}
+		if data, err = decryptExport(data, passphrase); err != nil {
+			return err
+		}
+	}
+	var imported portableNote
+	if err := json.Unmarshal(data, &imported); err != nil {
+		return err
+	}
+	if imported.Format != portableFormat {
+		return fmt.Errorf("%s is not a notectl note export", path)
+	}
+	if imported.UUID != "" {
+		var existing int
+		err := database.QueryRow("SELECT id FROM notes WHERE uuid = (?)", imported.UUID).Scan(&existing)
+		if err == nil {
+			return fmt.Errorf("note %s already exists with ID %d", imported.UUID, existing)
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+	n := note{
+		Time:   imported.Created,
+		Text:   imported.Text,
+		Tags:   imported.Tags,
+		Status: imported.Status,
+		Meta:   imported.Meta,
+		UUID:   imported.UUID,
+	}
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	for _, a := range imported.Attachments {
+		name, err := attachmentFileName(a.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := addAttachment(n.ID, name, a.Mime, a.Data, database); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Imported note %s as ID %d\n", n.UUID, n.ID)
+	return nil
+}
+
+// exportPassphrase reads the passphrase used to encrypt or decrypt export
+// files from NOTECTL_PASSPHRASE or the keyring, prompting on stdin when it is
+// in neither.
+func exportPassphrase() (string, error) {
+	if passphrase := secretValue("passphrase"); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase, err := promptSecret("Passphrase: ")
+	if err == nil && passphrase == "" {
+		err = errors.New("a passphrase is required")
+	}
+	return passphrase, err
+}
+
+// pbkdf2Key derives a 32 byte key with PBKDF2-HMAC-SHA256 (RFC 8018).
+func pbkdf2Key(passphrase string, salt []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(passphrase))
+	mac.Write(salt)
+	binary.Write(mac, binary.BigEndian, uint32(1))
+	u := mac.Sum(nil)
+	key := append([]byte(nil), u...)
+	for i := 1; i < pbkdf2Iterations; i++ {
+		mac.Reset()
+		mac.Write(u)
+		u = mac.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
+
+func exportCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2Key(passphrase, salt))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptExport seals data with AES-256-GCM. The output is the magic header
+// followed by the salt, nonce and ciphertext.
+func encryptExport(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := exportCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append(append([]byte(nil), encryptedMagic...), salt...), nonce...)
+	return gcm.Seal(out, nonce, data, encryptedMagic), nil
+}
+
+func decryptExport(data []byte, passphrase string) ([]byte, error) {
+	data = data[len(encryptedMagic):]
+	if len(data) < 16 {
+		return nil, errors.New("encrypted export is truncated")
+	}
+	salt, data := data[:16], data[16:]
+	gcm, err := exportCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted export is truncated")
+	}
+	nonce, data := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, data, encryptedMagic)
+	if err != nil {
+		return nil, errors.New("cannot decrypt export: wrong passphrase or corrupted file")
+	}
+	return plain, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAttachmentFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"photo.jpg", "photo.jpg", true},
+		{"../../.bashrc", ".bashrc", true},
+		{"/etc/cron.d/job", "job", true},
+		{`..\..\Startup\run.bat`, "run.bat", true},
+		{"dir/", "dir", true},
+		{"", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"../", "", false},
+		{"/", "", false},
+	}
+	for _, test := range tests {
+		got, err := attachmentFileName(test.name)
+		if (err == nil) != test.ok || got != test.want {
+			t.Errorf("attachmentFileName(%q) = %q, %v; want %q, ok %t", test.name, got, err, test.want, test.ok)
+		}
+	}
+}
+
+func TestImportNoteCleansAttachmentNames(t *testing.T) {
+	database := openTestDatabase(t)
+	dir, err := ioutil.TempDir("", "notectl-import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bundle, err := json.Marshal(portableNote{
+		Format:  portableFormat,
+		Version: 1,
+		Created: time.Date(2026, time.May, 1, 8, 0, 0, 0, time.UTC),
+		Text:    "note with a crafted attachment",
+		Attachments: []portableAttachment{
+			{Name: "../../../tmp/evil.sh", Mime: "text/plain", Data: []byte("echo owned")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "note.json")
+	if err := ioutil.WriteFile(path, bundle, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := importNote(path, database); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err := database.QueryRow("SELECT name FROM attachments").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	if name != "evil.sh" {
+		t.Errorf("imported attachment named %q, want evil.sh", name)
+	}
+}
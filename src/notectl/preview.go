@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// DefaultPreviewLength is how many characters of a note list views show
+// unless preview.length says otherwise.
+const DefaultPreviewLength = 200
+
+// preview is how much of each note list views show: its first Length
+// characters or, when Lines is set, its first Lines lines each cut to Length
+// characters. A zero Length leaves the text or lines whole.
+type preview struct {
+	Lines  int
+	Length int
+}
+
+// listPreview is the preview list views use, from the preview.length and
+// preview.lines settings and show -preview-lines.
+var listPreview = preview{Length: DefaultPreviewLength}
+
+// previewFromConfig reads the preview.length and preview.lines settings.
+func previewFromConfig() preview {
+	p := preview{Length: DefaultPreviewLength}
+	if n, err := strconv.Atoi(configValue("preview.length", "")); err == nil && n >= 0 {
+		p.Length = n
+	}
+	if n, err := strconv.Atoi(configValue("preview.lines", "")); err == nil && n >= 0 {
+		p.Lines = n
+	}
+	return p
+}
+
+// readLength is how many characters of a note the preview needs, or 0 when
+// it needs the whole note.
+func (p preview) readLength() int {
+	if p.Length == 0 {
+		return 0
+	}
+	if p.Lines > 0 {
+		// Each line can be up to Length characters plus its newline.
+		return p.Lines * (p.Length + 1)
+	}
+	return p.Length
+}
+
+// cut returns the part of text the preview shows and whether anything was
+// left out.
+func (p preview) cut(text string) (string, bool) {
+	if p.Lines == 0 {
+		return cutRunes(text, p.Length)
+	}
+	lines := strings.SplitN(text, "\n", p.Lines+1)
+	cut := len(lines) > p.Lines
+	if cut {
+		lines = lines[:p.Lines]
+	}
+	for i, line := range lines {
+		var lineCut bool
+		if lines[i], lineCut = cutRunes(line, p.Length); lineCut {
+			cut = true
+		}
+	}
+	return strings.Join(lines, "\n"), cut
+}
+
+// cutRunes cuts s to n characters, or leaves it whole when n is 0.
+func cutRunes(s string, n int) (string, bool) {
+	runes := []rune(s)
+	if n == 0 || len(runes) <= n {
+		return s, false
+	}
+	return string(runes[:n]), true
+}
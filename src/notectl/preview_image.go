@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Decoders for the formats previews can draw besides PNG.
+	_ "image/gif"
+	_ "image/jpeg"
+)
+
+// imagePreviewWidth is the widest, in pixels, a preview is drawn with sixels.
+// Kitty and iTerm2 scale images to the terminal themselves.
+const imagePreviewWidth = 800
+
+// kittyChunkSize is the most base64 data the kitty graphics protocol accepts
+// in one escape sequence.
+const kittyChunkSize = 4096
+
+// imageProtocol returns the inline image protocol the terminal understands,
+// "kitty", "iterm" or "sixel", or "" when it understands none. The
+// attachments.preview setting overrides detection; "off" always opens the
+// default viewer.
+func imageProtocol() string {
+	switch setting := strings.ToLower(configValue("attachments.preview", "auto")); setting {
+	case "kitty", "iterm", "sixel":
+		return setting
+	case "off":
+		return ""
+	}
+	if !stdoutIsTerminal() || plainOutput {
+		return ""
+	}
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || program == "ghostty":
+		return "kitty"
+	case program == "iTerm.app" || program == "WezTerm":
+		return "iterm"
+	case strings.Contains(term, "sixel") || term == "mlterm" || term == "foot" || strings.HasPrefix(term, "yaft"):
+		return "sixel"
+	}
+	return ""
+}
+
+// previewAttachment draws an image attachment in the terminal, or opens the
+// attachment in the default viewer when it is not an image or the terminal
+// cannot show images.
+func previewAttachment(a attachment, data []byte, viewer bool) error {
+	protocol := imageProtocol()
+	if viewer || protocol == "" || !strings.HasPrefix(a.Mime, "image/") {
+		return openAttachment(a, data)
+	}
+	var err error
+	switch protocol {
+	case "iterm":
+		err = writeITermImage(os.Stdout, a.Name, data)
+	case "kitty":
+		err = writeKittyImage(os.Stdout, data)
+	case "sixel":
+		err = writeSixelImage(os.Stdout, data)
+	}
+	if err == errUnsupportedImage {
+		return openAttachment(a, data)
+	}
+	if err == nil {
+		fmt.Println()
+	}
+	return err
+}
+
+// errUnsupportedImage is returned for images that cannot be decoded for
+// drawing, such as WebP, which are then opened in the default viewer.
+var errUnsupportedImage = errors.New("unsupported image format")
+
+// openAttachment writes an attachment to a temporary file and opens it with
+// the desktop's default handler.
+func openAttachment(a attachment, data []byte) error {
+	file, err := ioutil.TempFile("", fmt.Sprintf("notectl-attachment-%d-*%s", a.ID, filepath.Ext(a.Name)))
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// The file is left behind for the viewer to read; the OS cleans up its
+	// temporary directory.
+	return openInBrowser(file.Name())
+}
+
+// writeITermImage draws an image with the iTerm2 inline image protocol, which
+// takes the file as it is.
+func writeITermImage(w io.Writer, name string, data []byte) error {
+	_, err := fmt.Fprintf(w, "\x1b]1337;File=inline=1;size=%d;name=%s:%s\a", len(data), base64.StdEncoding.EncodeToString([]byte(name)), base64.StdEncoding.EncodeToString(data))
+	return err
+}
+
+// writeKittyImage draws an image with the kitty graphics protocol, sending it
+// as PNG in chunks.
+func writeKittyImage(w io.Writer, data []byte) error {
+	if _, format, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return errUnsupportedImage
+	} else if format != "png" {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return errUnsupportedImage
+		}
+		var converted bytes.Buffer
+		if err := png.Encode(&converted, img); err != nil {
+			return err
+		}
+		data = converted.Bytes()
+	}
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for first := true; len(encoded) > 0; first = false {
+		chunk := encoded
+		if len(chunk) > kittyChunkSize {
+			chunk = chunk[:kittyChunkSize]
+		}
+		encoded = encoded[len(chunk):]
+		more := 0
+		if len(encoded) > 0 {
+			more = 1
+		}
+		control := fmt.Sprintf("m=%d", more)
+		if first {
+			control = "a=T,f=100," + control
+		}
+		if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", control, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSixelImage draws an image as sixels, reduced to a 256 color palette
+// and scaled down to imagePreviewWidth.
+func writeSixelImage(w io.Writer, data []byte) error {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return errUnsupportedImage
+	}
+	img = shrinkImage(img, imagePreviewWidth)
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, bounds, img, bounds.Min)
+	width, height := bounds.Dx(), bounds.Dy()
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "\x1bPq\"1;1;%d;%d", width, height)
+	for i, c := range paletted.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(out, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+	sixels := make([]byte, width)
+	for top := 0; top < height; top += 6 {
+		var used [256]bool
+		for y := top; y < top+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[paletted.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+y)] = true
+			}
+		}
+		for index, ok := range used {
+			if !ok {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for row := 0; row < 6 && top+row < height; row++ {
+					if int(paletted.ColorIndexAt(bounds.Min.X+x, bounds.Min.Y+top+row)) == index {
+						bits |= 1 << uint(row)
+					}
+				}
+				sixels[x] = '?' + bits
+			}
+			fmt.Fprintf(out, "#%d", index)
+			writeSixelRuns(out, sixels)
+			// Return to the start of the band for the next color.
+			out.WriteByte('$')
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\")
+	return out.Flush()
+}
+
+// writeSixelRuns writes a band of sixels, run length encoding repeats.
+func writeSixelRuns(out *bufio.Writer, sixels []byte) {
+	for i := 0; i < len(sixels); {
+		run := 1
+		for i+run < len(sixels) && sixels[i+run] == sixels[i] {
+			run++
+		}
+		if run > 3 {
+			fmt.Fprintf(out, "!%d%c", run, sixels[i])
+		} else {
+			for j := 0; j < run; j++ {
+				out.WriteByte(sixels[i])
+			}
+		}
+		i += run
+	}
+}
+
+// shrinkImage scales an image down to at most width pixels wide, keeping its
+// aspect ratio.
+func shrinkImage(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height == 0 {
+		height = 1
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/width, bounds.Min.Y+y*bounds.Dy()/height))
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+const (
+	// printWidth is the line width used for ordinary printer pages.
+	printWidth = 80
+	// receiptWidth fits the 58mm paper of common thermal receipt printers.
+	receiptWidth = 32
+)
+
+var (
+	markdownHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	markdownListItem = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)])\s+(\[[ xX]\]\s+)?(.*)$`)
+	markdownEmphasis = regexp.MustCompile("\\*\\*([^*]+)\\*\\*|__([^_]+)__|`([^`]+)`")
+)
+
+// stripEmphasis removes bold and inline code markers, keeping their text.
+func stripEmphasis(line string) string {
+	return markdownEmphasis.ReplaceAllString(line, "$1$2$3")
+}
+
+// wrapText breaks text into lines of at most width runes, prefixing the first
+// line with first and the rest with rest.
+func wrapText(text string, width int, first string, rest string) []string {
+	var lines []string
+	line := first
+	empty := true
+	for _, word := range strings.Fields(text) {
+		if !empty && len([]rune(line))+1+len([]rune(word)) > width {
+			lines = append(lines, line)
+			line = rest
+			empty = true
+		}
+		if !empty {
+			line += " "
+		}
+		line += word
+		empty = false
+	}
+	return append(lines, line)
+}
+
+// renderPlainText formats Markdown note text as plain text wrapped to width,
+// suitable for printers that do not understand any markup.
+func renderPlainText(text string, width int) string {
+	var out []string
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.TrimRight(line, " \t")
+		if heading := markdownHeading.FindStringSubmatch(line); heading != nil {
+			title := stripEmphasis(heading[2])
+			underline := "-"
+			if len(heading[1]) == 1 {
+				title = strings.ToUpper(title)
+				underline = "="
+			}
+			out = append(out, wrapText(title, width, "", "")...)
+			length := len([]rune(title))
+			if length > width {
+				length = width
+			}
+			out = append(out, strings.Repeat(underline, length))
+			continue
+		}
+		if item := markdownListItem.FindStringSubmatch(line); item != nil {
+			marker := "- "
+			if item[2] != "" {
+				marker = strings.ToLower(strings.TrimSpace(item[2])) + " "
+			}
+			indent := strings.Repeat(" ", len(strings.Replace(item[1], "\t", "  ", -1)))
+			first := indent + marker
+			out = append(out, wrapText(stripEmphasis(item[3]), width, first, strings.Repeat(" ", len(first)))...)
+			continue
+		}
+		if line == "" {
+			out = append(out, "")
+			continue
+		}
+		out = append(out, wrapText(stripEmphasis(line), width, "", "")...)
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// printNote renders a note and sends it to lpr, or writes it to stdout when
+// dryRun is set.
+func printNote(id int, printer string, receipt bool, dryRun bool, database *sql.DB) error {
+	n, _, err := loadNote(id, database)
+	if err != nil {
+		return err
+	}
+	width := printWidth
+	if receipt {
+		width = receiptWidth
+	}
+	var page strings.Builder
+	page.WriteString(fmt.Sprintf("Note %d - %s\n", n.ID, formatDateTime(n.Time)))
+	if len(n.Tags) > 0 {
+		page.WriteString(strings.Join(wrapText(strings.Join(n.Tags, ", "), width, "Tags: ", "      "), "\n") + "\n")
+	}
+	page.WriteString(strings.Repeat("-", width) + "\n")
+	page.WriteString(renderPlainText(n.Text, width))
+	if receipt {
+		// Feed enough paper to tear the receipt off below the text.
+		page.WriteString("\n\n\n")
+	}
+	if dryRun {
+		fmt.Print(page.String())
+		return nil
+	}
+	lpr, err := exec.LookPath("lpr")
+	if err != nil {
+		return fmt.Errorf("lpr not found, is CUPS installed? (%s)", err)
+	}
+	var args []string
+	if printer != "" {
+		args = append(args, "-P", printer)
+	}
+	args = append(args, "-T", fmt.Sprintf("notectl note %d", n.ID))
+	cmd := exec.Command(lpr, args...)
+	cmd.Stdin = strings.NewReader(page.String())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runPrint sends a note to a printer.
+func runPrint(args []string) error {
+	printCommand := newFlagSet("print")
+	printIDPtr := printCommand.Int("i", -1, "The ID of the note to print.")
+	printPrinterPtr := printCommand.String("P", configValue("print.printer", ""), "The printer to send the note to. Defaults to the system default printer.")
+	printReceiptPtr := printCommand.Bool("receipt", false, "Format the note for a narrow thermal receipt printer.")
+	printDryRunPtr := printCommand.Bool("dry-run", false, "Write the formatted note to stdout instead of printing it.")
+	if err := parseFlags(printCommand, args); err != nil {
+		return err
+	}
+	if err := validateID(*printIDPtr); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return printNote(*printIDPtr, *printPrinterPtr, *printReceiptPtr, *printDryRunPtr, database)
+}
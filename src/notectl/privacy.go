@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// identifyingMetaKeys are metadata keys that describe the machine or
+// environment a note was written in rather than the note itself.
+var identifyingMetaKeys = []string{
+	contextHostKey, contextOSKey, contextBranchKey, contextWeatherKey,
+	projectKey, "host", "cwd", "user", "session", "window",
+}
+
+// privacyMode reports whether the privacy profile is enabled with
+// privacy = on. In privacy mode identifying metadata is never stored or
+// exported and image attachments are stripped of EXIF and similar metadata.
+func privacyMode() bool {
+	return configValue("privacy", "off") == "on"
+}
+
+func identifyingMetaKey(key string) bool {
+	for _, k := range identifyingMetaKeys {
+		if key == k {
+			return true
+		}
+	}
+	return strings.HasPrefix(key, "context:")
+}
+
+// scrubMeta returns meta without identifying keys when privacy mode is on.
+func scrubMeta(meta metaList) metaList {
+	if !privacyMode() {
+		return meta
+	}
+	scrubbed := make(metaList, len(meta))
+	for key, value := range meta {
+		if !identifyingMetaKey(key) {
+			scrubbed[key] = value
+		}
+	}
+	return scrubbed
+}
+
+// scrubAttachment strips embedded metadata from images when privacy mode is
+// on. Other attachments are returned unchanged.
+func scrubAttachment(mime string, data []byte) []byte {
+	if !privacyMode() {
+		return data
+	}
+	var err error
+	scrubbed := data
+	switch {
+	case strings.HasPrefix(mime, "image/jpeg"):
+		scrubbed, err = stripJPEGMetadata(data)
+	case strings.HasPrefix(mime, "image/png"):
+		scrubbed, err = stripPNGMetadata(data)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "privacy: could not strip image metadata:", err)
+		return data
+	}
+	return scrubbed
+}
+
+// stripJPEGMetadata drops the APP1-APP15 and comment segments of a JPEG,
+// which hold EXIF, XMP, IPTC and similar data. APP0 (JFIF) is kept as some
+// decoders require it.
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("not a JPEG image")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, fmt.Errorf("malformed JPEG segment at offset %d", i)
+		}
+		marker := data[i+1]
+		if marker == 0xDA {
+			// Start of scan: the compressed image data follows, copy the rest.
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("malformed JPEG segment at offset %d", i)
+		}
+		if !(marker >= 0xE1 && marker <= 0xEF) && marker != 0xFE {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	out.Write(data[i:])
+	return out.Bytes(), nil
+}
+
+// pngMetadataChunks are the PNG chunk types holding EXIF and text metadata.
+var pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "iTXt": true, "zTXt": true, "tIME": true}
+
+// stripPNGMetadata drops the EXIF, text and timestamp chunks of a PNG.
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return nil, fmt.Errorf("not a PNG image")
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(signature)
+	i := len(signature)
+	for i+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, fmt.Errorf("malformed PNG chunk at offset %d", i)
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
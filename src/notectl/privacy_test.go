@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// enablePrivacy turns privacy mode on for the rest of the test.
+func enablePrivacy(t *testing.T) {
+	t.Helper()
+	previous, had := config["privacy"]
+	config["privacy"] = "on"
+	t.Cleanup(func() {
+		if had {
+			config["privacy"] = previous
+		} else {
+			delete(config, "privacy")
+		}
+	})
+}
+
+// jpegSegment returns a JPEG marker segment holding payload.
+func jpegSegment(marker byte, payload string) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// pngChunk returns a PNG chunk holding payload. The CRC is not checked when
+// stripping, so it is left zero.
+func pngChunk(kind string, payload string) []byte {
+	chunk := make([]byte, 4, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, payload...)
+	return append(chunk, 0, 0, 0, 0)
+}
+
+func testJPEG() []byte {
+	var image []byte
+	image = append(image, 0xFF, 0xD8)
+	image = append(image, jpegSegment(0xE0, "JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00")...)
+	image = append(image, jpegSegment(0xE1, "Exif\x00\x00GPS 52.52N 13.40E")...)
+	image = append(image, jpegSegment(0xFE, "shot by jane")...)
+	image = append(image, jpegSegment(0xDB, "quantisation")...)
+	image = append(image, jpegSegment(0xDA, "scan")...)
+	return append(image, "compressed data\xFF\xD9"...)
+}
+
+func testPNG() []byte {
+	image := []byte("\x89PNG\r\n\x1a\n")
+	image = append(image, pngChunk("IHDR", "0123456789abc")...)
+	image = append(image, pngChunk("eXIf", "GPS 52.52N 13.40E")...)
+	image = append(image, pngChunk("tEXt", "Author\x00jane")...)
+	image = append(image, pngChunk("tIME", "\x07\xea\x0a\x10\x0c\x00\x00")...)
+	image = append(image, pngChunk("IDAT", "pixels")...)
+	return append(image, pngChunk("IEND", "")...)
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	stripped, err := stripJPEGMetadata(testJPEG())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{"Exif", "GPS", "jane"} {
+		if bytes.Contains(stripped, []byte(gone)) {
+			t.Errorf("stripped JPEG still holds %q", gone)
+		}
+	}
+	for _, kept := range []string{"JFIF", "quantisation", "scan", "compressed data\xFF\xD9"} {
+		if !bytes.Contains(stripped, []byte(kept)) {
+			t.Errorf("stripped JPEG lost %q", kept)
+		}
+	}
+	if _, err := stripJPEGMetadata([]byte("GIF89a")); err == nil {
+		t.Error("stripJPEGMetadata accepted a GIF")
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	stripped, err := stripPNGMetadata(testPNG())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{"eXIf", "tEXt", "tIME", "GPS", "jane"} {
+		if bytes.Contains(stripped, []byte(gone)) {
+			t.Errorf("stripped PNG still holds %q", gone)
+		}
+	}
+	for _, kept := range []string{"IHDR", "IDAT", "pixels", "IEND"} {
+		if !bytes.Contains(stripped, []byte(kept)) {
+			t.Errorf("stripped PNG lost %q", kept)
+		}
+	}
+	truncated := testPNG()
+	if _, err := stripPNGMetadata(truncated[:len(truncated)-3]); err == nil {
+		t.Error("stripPNGMetadata accepted a truncated PNG")
+	}
+}
+
+func TestPrivacyModeDropsIdentifyingMetadata(t *testing.T) {
+	enablePrivacy(t)
+	database := openTestDatabase(t)
+	meta := metaList{"mood": "calm"}
+	stampContext(meta)
+	if len(meta) != 1 {
+		t.Errorf("stampContext stamped %v in privacy mode", meta)
+	}
+	n := note{
+		Time: time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC),
+		Text: "session notes",
+		Meta: metaList{"mood": "calm", contextHostKey: "laptop", "user": "jane", "cwd": "/home/jane", "context:ip": "10.0.0.2"},
+	}
+	if err := n.Save(database); err != nil {
+		t.Fatal(err)
+	}
+	if err := setNoteMeta(n.ID, contextOSKey, "linux/amd64", database); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := getNoteMeta(n.ID, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key := range stored {
+		if identifyingMetaKey(key) {
+			t.Errorf("privacy mode stored %s = %q", key, stored[key])
+		}
+	}
+	if stored["mood"] != "calm" {
+		t.Errorf("privacy mode dropped mood, stored %v", stored)
+	}
+}
+
+func TestPrivacyModeScrubsAttachmentsAndExports(t *testing.T) {
+	database := openTestDatabase(t)
+	n := saveTestNote(t, database, time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC), "photos")
+	// Metadata and images stored before privacy mode was turned on.
+	if err := setNoteMeta(n.ID, contextHostKey, "laptop", database); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addAttachment(n.ID, "before.png", "image/png", testPNG(), database); err != nil {
+		t.Fatal(err)
+	}
+	enablePrivacy(t)
+	id, err := addAttachment(n.ID, "after.jpg", "image/jpeg", testJPEG(), database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := readAttachment(id, database); err != nil {
+		t.Fatal(err)
+	} else if bytes.Contains(data, []byte("Exif")) {
+		t.Error("privacy mode stored a JPEG with its EXIF")
+	}
+
+	dir, err := ioutil.TempDir("", "notectl-privacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "note.json")
+	if err := exportNote(n.ID, path, "", database); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported portableNote
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := exported.Meta[contextHostKey]; ok {
+		t.Error("privacy mode exported the host")
+	}
+	if len(exported.Attachments) != 2 {
+		t.Fatalf("exported %d attachments, want 2", len(exported.Attachments))
+	}
+	for _, a := range exported.Attachments {
+		for _, gone := range []string{"Exif", "eXIf", "GPS"} {
+			if strings.Contains(string(a.Data), gone) {
+				t.Errorf("privacy mode exported %s holding %q", a.Name, gone)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"time"
+)
+
+// profile accumulates where an invocation spends its time when -profile is
+// given. Stages are coarse on purpose: opening the database, fetching rows and
+// rendering them.
+var profile struct {
+	enabled bool
+	start   time.Time
+	stages  map[string]time.Duration
+}
+
+// profileSince adds the time elapsed since start to a stage. It is cheap
+// enough to call unconditionally, and does nothing unless profiling.
+func profileSince(stage string, start time.Time) {
+	if profile.enabled {
+		profile.stages[stage] += time.Since(start)
+	}
+}
+
+// startProfile enables stage timing and, when prefix is set, CPU profiling to
+// <prefix>.cpu.pprof. The returned function stops profiling, writes a heap
+// profile to <prefix>.heap.pprof and reports the stage timings on stderr.
+func startProfile(enabled bool, prefix string) (func(), error) {
+	profile.enabled = enabled || prefix != ""
+	profile.start = time.Now()
+	profile.stages = make(map[string]time.Duration)
+	var cpu *os.File
+	if prefix != "" {
+		var err error
+		if cpu, err = os.Create(prefix + ".cpu.pprof"); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, err
+		}
+	}
+	return func() {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			cpu.Close()
+			if err := writeHeapProfile(prefix + ".heap.pprof"); err != nil {
+				fmt.Fprintln(os.Stderr, "profile:", err)
+			}
+			fmt.Fprintf(os.Stderr, "profile: wrote %s.cpu.pprof and %s.heap.pprof, inspect them with go tool pprof\n", prefix, prefix)
+		}
+		if profile.enabled {
+			reportProfile()
+		}
+	}, nil
+}
+
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	runtime.GC()
+	return pprof.WriteHeapProfile(file)
+}
+
+func reportProfile() {
+	total := time.Since(profile.start)
+	var stages []string
+	other := total
+	for stage, d := range profile.stages {
+		stages = append(stages, stage)
+		other -= d
+	}
+	sort.Slice(stages, func(i, j int) bool { return profile.stages[stages[i]] > profile.stages[stages[j]] })
+	fmt.Fprintf(os.Stderr, "profile: %s total\n", total.Round(time.Microsecond))
+	line := func(stage string, d time.Duration) {
+		fmt.Fprintf(os.Stderr, "  %s %12s %5.1f%%\n", padRight(stage, 8), d.Round(time.Microsecond), 100*d.Seconds()/total.Seconds())
+	}
+	for _, stage := range stages {
+		line(stage, profile.stages[stage])
+	}
+	if other > 0 {
+		line("other", other)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// qrMaxBytes is the most data a version 40 QR code holds at low error
+// correction.
+const qrMaxBytes = 2953
+
+// showQRCode renders a note's text as a QR code in the terminal using
+// qrencode.
+func showQRCode(id int, database *sql.DB) error {
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	text = strings.TrimRight(text, "\n")
+	if len(text) > qrMaxBytes {
+		return fmt.Errorf("note %d is %d bytes, too long for a QR code (at most %d)", id, len(text), qrMaxBytes)
+	}
+	qrencode, err := exec.LookPath("qrencode")
+	if err != nil {
+		return fmt.Errorf("qrencode not found, install it to show QR codes (%s)", err)
+	}
+	cmd := exec.Command(qrencode, "-t", "ANSIUTF8", "-l", "L", "-8")
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// runQR shows a note as a QR code in the terminal.
+func runQR(args []string) error {
+	qrCommand := newFlagSet("qr")
+	qrIDPtr := qrCommand.Int("i", -1, "The ID of the note to show as a QR code.")
+	if err := parseFlags(qrCommand, args); err != nil {
+		return err
+	}
+	if err := validateID(*qrIDPtr); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return showQRCode(*qrIDPtr, database)
+}
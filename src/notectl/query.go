@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// queryFilter accumulates the conditions of a WHERE clause together with the
+// values bound to them.
+type queryFilter = notes.Filter
+
+// noteQuery is a parsed -q query. Structured terms become SQL conditions;
+// free text is matched after loading because note text may be compressed.
+type noteQuery = notes.Query
+
+// parseQuery reads a query made of space-separated terms, all of which must
+// match: tag:<tag>, status:<status>, id:<id>, person:<name> or @<name>,
+// after:<yyyy-mm-dd>, before:<yyyy-mm-dd>, lang:<code>, and plain words that
+// must appear in the note text, ignoring case. Double quotes group words into
+// a phrase.
+func parseQuery(q string) (noteQuery, error) {
+	var query noteQuery
+	for _, term := range splitQuery(q) {
+		key, value := "", term
+		if strings.HasPrefix(term, "@") && len(term) > 1 {
+			key, value = "person", term[1:]
+		} else if i := strings.Index(term, ":"); i > 0 {
+			switch term[:i] {
+			case "tag", "status", "id", "person", "after", "before", "lang":
+				key, value = term[:i], term[i+1:]
+			}
+		}
+		if key != "" && value == "" {
+			return query, fmt.Errorf("empty value for %s: in query", key)
+		}
+		switch key {
+		case "tag":
+			query.Filter.Add(tagMatchClause, value)
+		case "status":
+			if value == "none" {
+				value = ""
+			} else if !validStatus(value) {
+				return query, fmt.Errorf("unknown status %q in query", value)
+			}
+			query.Filter.Add("status = (?)", value)
+		case "id":
+			id, err := parseID(value)
+			if err != nil {
+				return query, err
+			}
+			query.Filter.Add("id = (?)", id)
+		case "lang":
+			query.Filter.Add("id IN (SELECT note_id FROM metadata WHERE key = '"+langKey+"' AND value = (?))", strings.ToLower(value))
+		case "person":
+			query.Filter.Add("id IN (SELECT note_id FROM mentions WHERE person = (?))", strings.ToLower(value))
+		case "after", "before":
+			day, err := parseDueDate(value)
+			if err != nil {
+				return query, err
+			}
+			if key == "after" {
+				query.Filter.Add("timestamp >= (?)", day.Unix())
+			} else {
+				query.Filter.Add("timestamp < (?)", day.Unix())
+			}
+		default:
+			if addDateSelector(&query.Filter, value) {
+				continue
+			}
+			query.Words = append(query.Words, strings.ToLower(value))
+		}
+	}
+	return query, nil
+}
+
+// splitQuery splits on spaces, keeping double-quoted phrases together.
+func splitQuery(q string) []string {
+	var terms []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms
+}
+
+// findNotes loads every note matching the query, oldest first.
+func findNotes(query noteQuery, database *sql.DB) ([]note, error) {
+	found, err := noteStore(database).List(query)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]note, len(found))
+	for i, n := range found {
+		list[i] = noteFromStore(n)
+	}
+	return list, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHostileQueries(t *testing.T) {
+	database := openTestDatabase(t)
+	at := time.Date(2026, time.February, 3, 10, 0, 0, 0, time.Local)
+	saveTestNote(t, database, at, "public release notes", "work")
+	saveTestNote(t, database, at.Add(time.Hour), "private diary", "private")
+
+	tests := []string{
+		`tag:"x' OR '1'='1"`,
+		`tag:"work') OR 1=1 --"`,
+		`"'; DROP TABLE notes; --"`,
+		`@"bob') OR ('1'='1"`,
+		`person:"x' UNION SELECT id, notetext FROM notes --"`,
+		`lang:"en') OR 1=1 --"`,
+		`status:none tag:"private' --"`,
+		`"private%"`,
+	}
+	for _, q := range tests {
+		query, err := parseQuery(q)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %s", q, err)
+			continue
+		}
+		found, err := findNotes(query, database)
+		if err != nil {
+			t.Errorf("%q: %s", q, err)
+			continue
+		}
+		if len(found) != 0 {
+			t.Errorf("%q matched %d notes", q, len(found))
+		}
+	}
+	all, err := findNotes(noteQuery{}, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("%d notes after hostile queries, want 2", len(all))
+	}
+}
+
+func TestGrepFilterBindsIDs(t *testing.T) {
+	database := openTestDatabase(t)
+	at := time.Date(2026, time.February, 3, 10, 0, 0, 0, time.Local)
+	first := saveTestNote(t, database, at, "alpha one")
+	saveTestNote(t, database, at, "beta two")
+	third := saveTestNote(t, database, at, "alpha three")
+
+	var filter queryFilter
+	if err := addGrepFilter(&filter, "^alpha", database); err != nil {
+		t.Fatal(err)
+	}
+	found, err := findNotes(noteQuery{Filter: filter}, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].ID != first.ID || found[1].ID != third.ID {
+		t.Errorf("grep filter matched %v, want notes %d and %d", found, first.ID, third.ID)
+	}
+	if err := addGrepFilter(&queryFilter{}, "nothing like this", database); err != errNoMatch {
+		t.Errorf("grep without matches returned %v, want errNoMatch", err)
+	}
+}
+
+func TestGrepFilterMatchesMoreNotesThanSQLiteVariables(t *testing.T) {
+	database := openTestDatabase(t)
+	tx, err := database.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const count = 40000
+	for i := 1; i <= count; i++ {
+		text := "even"
+		if i%2 == 1 {
+			text = "odd"
+		}
+		if _, err := tx.Exec("INSERT INTO notes (timestamp, notetext, tags) VALUES (?, ?, '[]')", i, text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var filter queryFilter
+	if err := addGrepFilter(&filter, "^odd$", database); err != nil {
+		t.Fatal(err)
+	}
+	var matched, odd int
+	if err := database.QueryRow("SELECT count(*), count(CASE WHEN id % 2 = 1 THEN 1 END) FROM notes"+filter.Where(), filter.Args...).Scan(&matched, &odd); err != nil {
+		t.Fatal(err)
+	}
+	if matched != count/2 || odd != count/2 {
+		t.Errorf("grep filter matched %d notes, %d of them odd, want %d odd notes", matched, odd, count/2)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// quoteTag marks notes captured with the quote subcommand.
+const quoteTag = "quote"
+
+func saveQuote(text string, source string, author string, page string, tags tagList, database *sql.DB) error {
+	meta := make(metaList)
+	if source != "" {
+		meta["source"] = source
+	}
+	if author != "" {
+		meta["author"] = author
+	}
+	if page != "" {
+		meta["page"] = page
+	}
+	n := note{Time: time.Now(), Text: text, Tags: append(tagList{quoteTag}, tags...), Meta: meta}
+	n.PrintConsole()
+	return n.Save(database)
+}
+
+// attribution formats the source metadata of a quote, e.g. "Author, Title, p. 42".
+func attribution(meta metaList) string {
+	var parts []string
+	for _, key := range []string{"author", "source"} {
+		if meta[key] != "" {
+			parts = append(parts, meta[key])
+		}
+	}
+	if meta["page"] != "" {
+		parts = append(parts, "p. "+meta["page"])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// listQuotes prints quotes, optionally only those whose source contains the
+// given text, ignoring case.
+func listQuotes(source string, database *sql.DB) error {
+	var filter queryFilter
+	filter.Add(tagMatchClause, quoteTag)
+	if source != "" {
+		filter.Add("id IN (SELECT note_id FROM metadata WHERE key = 'source' AND instr(lower(value), lower(?)) > 0)", source)
+	}
+	rows, err := database.Query("SELECT id, "+notes.TextColumn+" FROM notes"+filter.Where()+" ORDER BY timestamp", filter.Args...)
+	if err != nil {
+		return err
+	}
+	type quote struct {
+		id   int
+		text noteText
+	}
+	var quotes []quote
+	for rows.Next() {
+		var q quote
+		if err := rows.Scan(&q.id, &q.text); err != nil {
+			rows.Close()
+			return err
+		}
+		quotes = append(quotes, q)
+	}
+	rows.Close()
+	for _, q := range quotes {
+		meta, err := getNoteMeta(q.id, database)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d - \"%s\"\n", q.id, strings.TrimSpace(string(q.text)))
+		if a := attribution(meta); a != "" {
+			fmt.Printf("    %s %s\n", glyph("—", "-"), a)
+		}
+	}
+	return nil
+}
+
+// runQuote saves a quote with its source.
+func runQuote(args []string) error {
+	quoteCommand := newFlagSet("quote")
+	var quoteTagList tagList
+	quoteSourcePtr := quoteCommand.String("source", "", "Where the quote comes from, e.g. a book title or URL.")
+	quoteAuthorPtr := quoteCommand.String("author", "", "Who said or wrote it.")
+	quotePagePtr := quoteCommand.String("page", "", "Page or location within the source.")
+	quoteCommand.Var(&quoteTagList, "t", "A comma-delimited list of extra tags.")
+	positional, err := parseInterspersed(quoteCommand, args)
+	if err != nil {
+		return err
+	}
+	text := strings.Join(positional, " ")
+	if text == "" {
+		return errors.New("usage: notectl quote <text> [-source title] [-author name] [-page n] [-t tags]")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return saveQuote(text, *quoteSourcePtr, *quoteAuthorPtr, *quotePagePtr, quoteTagList, database)
+}
+
+// runQuotes lists the saved quotes.
+func runQuotes(args []string) error {
+	quotesCommand := newFlagSet("quotes")
+	quotesSourcePtr := quotesCommand.String("source", "", "Only list quotes whose source contains this text.")
+	if err := parseFlags(quotesCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return listQuotes(*quotesSourcePtr, database)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// readingTag marks notes that are entries on the reading list. The note's
+// status tracks progress: todo is unread, doing is in progress and done is finished.
+const readingTag = "reading"
+
+var readingStates = map[string]string{"todo": "unread", "doing": "reading", "done": "finished"}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func addReading(item string, tags tagList, database *sql.DB) error {
+	meta := metaList{"title": item}
+	if isURL(item) {
+		meta["url"] = item
+	}
+	n := note{Time: time.Now(), Text: item, Tags: append(tagList{readingTag}, tags...), Status: "todo", Meta: meta}
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	fmt.Printf("Added %d to the reading list: %s\n", n.ID, item)
+	return nil
+}
+
+// readingEntry checks that id refers to a reading list note.
+func readingEntry(arg string, database *sql.DB) (int, error) {
+	id, err := resolveNoteRef(arg, database)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err := database.QueryRow("SELECT COUNT(*) FROM notes WHERE id = (?) AND "+tagMatchClause, id, readingTag).Scan(&count); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("note %d is not on the reading list", id)
+	}
+	return id, nil
+}
+
+func setReadingState(id int, status string, stamp string, database *sql.DB) error {
+	if err := setNoteStatus(id, status, database); err != nil {
+		return err
+	}
+	if err := setNoteMeta(id, stamp, time.Now().Format(time.RFC3339), database); err != nil {
+		return err
+	}
+	fmt.Printf("Marked %d as %s\n", id, readingStates[status])
+	return nil
+}
+
+func listReading(unread bool, database *sql.DB) error {
+	var filter queryFilter
+	filter.Add(tagMatchClause, readingTag)
+	if unread {
+		filter.Add("status = 'todo'")
+	}
+	rows, err := database.Query("SELECT id, "+notes.TextColumn+", status FROM notes"+filter.Where()+" ORDER BY timestamp", filter.Args...)
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		id     int
+		text   noteText
+		status string
+	}
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.id, &e.text, &e.status); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	for _, e := range entries {
+		meta, err := getNoteMeta(e.id, database)
+		if err != nil {
+			return err
+		}
+		progress := ""
+		if meta["progress"] != "" && e.status == "doing" {
+			progress = fmt.Sprintf(" (%s)", meta["progress"])
+		}
+		fmt.Printf("%d - [%s]%s %s\n", e.id, readingStates[e.status], progress, firstLine(string(e.text), 70))
+	}
+	return nil
+}
+
+// runRead dispatches the reading list subcommands.
+func runRead(args []string, database *sql.DB) error {
+	usage := "usage: notectl read <add <url|title> [-t tags]|start <id>|progress <id> <progress>|finish <id>|list [-unread]>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "add":
+		addCommand := newFlagSet("read add")
+		var tags tagList
+		addCommand.Var(&tags, "t", "A comma-delimited list of extra tags.")
+		item, err := parseInterspersed(addCommand, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(item) == 0 {
+			return errors.New(usage)
+		}
+		return addReading(strings.Join(item, " "), tags, database)
+	case "start", "finish":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		id, err := readingEntry(args[1], database)
+		if err != nil {
+			return err
+		}
+		if args[0] == "start" {
+			return setReadingState(id, "doing", "started", database)
+		}
+		return setReadingState(id, "done", "finished", database)
+	case "progress":
+		if len(args) != 3 {
+			return errors.New(usage)
+		}
+		id, err := readingEntry(args[1], database)
+		if err != nil {
+			return err
+		}
+		if err := setNoteStatus(id, "doing", database); err != nil {
+			return err
+		}
+		return setNoteMeta(id, "progress", args[2], database)
+	case "list":
+		listCommand := newFlagSet("read list")
+		unreadPtr := listCommand.Bool("unread", false, "Only list entries not started yet.")
+		if err := parseFlags(listCommand, args[1:]); err != nil {
+			return err
+		}
+		return listReading(*unreadPtr, database)
+	}
+	return errors.New(usage)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// releaseSections group changelog notes by their other tags, in the order
+// they appear in the release notes.
+var releaseSections = []struct {
+	Heading string
+	Tags    []string
+}{
+	{"Features", []string{"feature", "features", "feat", "added"}},
+	{"Fixes", []string{"fix", "fixes", "bug", "bugfix"}},
+	{"Security", []string{"security"}},
+	{"Deprecations", []string{"deprecation", "deprecated", "removed"}},
+}
+
+// parseReleasePoint reads the start of a release range: a date, a period
+// such as 2w, or a git tag whose commit date is used.
+func parseReleasePoint(since string) (time.Time, error) {
+	if day, err := parseDueDate(since); err == nil {
+		return day, nil
+	}
+	if t, err := parseSince(since); err == nil {
+		return t, nil
+	}
+	output, err := exec.Command("git", "log", "-1", "--format=%ct", since).Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date, period or git tag", since)
+	}
+	stamp, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(stamp, 0), nil
+}
+
+// releaseNotes prints the notes tagged tag since the given point as a
+// Markdown changelog with a section per kind of change.
+func releaseNotes(since string, tag string, title string, database *sql.DB) error {
+	start, err := parseReleasePoint(since)
+	if err != nil {
+		return err
+	}
+	var query noteQuery
+	query.Filter.Add(tagMatchClause, tag)
+	query.Filter.Add("timestamp >= (?)", start.Unix())
+	query.Filter.Add("status != 'archived'")
+	notes, err := findNotes(query, database)
+	if err != nil {
+		return err
+	}
+	if len(notes) == 0 {
+		return fmt.Errorf("no notes tagged %s since %s", tag, formatDate(start))
+	}
+	sections := make([][]note, len(releaseSections)+1)
+	for _, n := range notes {
+		section := len(releaseSections)
+	find:
+		for i, s := range releaseSections {
+			for _, t := range s.Tags {
+				if containsTag(n.Tags, t) {
+					section = i
+					break find
+				}
+			}
+		}
+		sections[section] = append(sections[section], n)
+	}
+	if title == "" {
+		title = "Changes since " + since
+	}
+	fmt.Printf("# %s\n", title)
+	for i, entries := range sections {
+		if len(entries) == 0 {
+			continue
+		}
+		heading := "Other changes"
+		if i < len(releaseSections) {
+			heading = releaseSections[i].Heading
+		}
+		fmt.Printf("\n## %s\n\n", heading)
+		for _, n := range entries {
+			fmt.Printf("- %s\n", plainTitle(n.Text))
+		}
+	}
+	return nil
+}
+
+// runReleaseNotes writes release notes from the notes tagged for them.
+func runReleaseNotes(args []string) error {
+	releaseNotesCommand := newFlagSet("release-notes")
+	releaseNotesSincePtr := releaseNotesCommand.String("since", "", "Start of the release: a git tag, a date <yyyy>-<mm>-<dd>, or a period such as 2w.")
+	releaseNotesTagPtr := releaseNotesCommand.String("t", "changelog", "Tag marking notes that belong in the release notes.")
+	releaseNotesTitlePtr := releaseNotesCommand.String("title", "", "Heading for the release notes.")
+	if err := parseFlags(releaseNotesCommand, args); err != nil {
+		return err
+	}
+	if *releaseNotesSincePtr == "" {
+		return errors.New("usage: notectl release-notes -since <tag|date|period> [-t tag] [-title title]")
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return releaseNotes(*releaseNotesSincePtr, *releaseNotesTagPtr, *releaseNotesTitlePtr, database)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// replaceInNotes replaces from with to in every note matching q, printing a
+// diff of each change. from is a regular expression when regex is set, and
+// to may then refer to its groups as $1. All notes are updated in a single
+// transaction, and nothing is written when dryRun is set.
+func replaceInNotes(from string, to string, regex bool, q string, dryRun bool, database *sql.DB) error {
+	if from == "" {
+		return errors.New("-from must not be empty")
+	}
+	pattern := regexp.QuoteMeta(from)
+	if regex {
+		pattern = from
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %s", err)
+	}
+	query, err := parseQuery(q)
+	if err != nil {
+		return err
+	}
+	notes, err := findNotes(query, database)
+	if err != nil {
+		return err
+	}
+	changed := make(map[int]string)
+	var order []int
+	for _, n := range notes {
+		var text string
+		if regex {
+			text = re.ReplaceAllString(n.Text, to)
+		} else {
+			text = re.ReplaceAllLiteralString(n.Text, to)
+		}
+		if text == n.Text {
+			continue
+		}
+		fmt.Println(colorize(colorBold, fmt.Sprintf("note %d", n.ID)))
+		fmt.Print(unifiedDiff(diffLines(splitLines(n.Text), splitLines(text))))
+		changed[n.ID] = text
+		order = append(order, n.ID)
+	}
+	if len(order) == 0 {
+		return errNoMatch
+	}
+	if dryRun {
+		fmt.Printf("Would change %d notes\n", len(order))
+		return nil
+	}
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	for _, id := range order {
+		if interrupted(interrupt) {
+			rollbackJournaled(tx)
+			return errors.New("interrupted, no notes were changed")
+		}
+		if err := updateNoteText(id, changed[id], tx); err != nil {
+			rollbackJournaled(tx)
+			return fmt.Errorf("note %d: %s", id, err)
+		}
+	}
+	if err := commitJournaled(tx); err != nil {
+		return err
+	}
+	fmt.Printf("Changed %d notes\n", len(order))
+	return nil
+}
+
+// runReplace finds and replaces text across notes.
+func runReplace(args []string) error {
+	replaceCommand := newFlagSet("replace")
+	replaceFromPtr := replaceCommand.String("from", "", "Text to replace.")
+	replaceToPtr := replaceCommand.String("to", "", "Replacement text.")
+	replaceRegexPtr := replaceCommand.Bool("regex", false, "Treat -from as a regular expression; -to may use $1 for its groups.")
+	replaceQueryPtr := replaceCommand.String("q", "", "Only change notes matching this query, e.g. 'tag:runbook'.")
+	replaceDryRunPtr := replaceCommand.Bool("dry-run", false, "Show the changes without saving them.")
+	if err := parseFlags(replaceCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return replaceInNotes(*replaceFromPtr, *replaceToPtr, *replaceRegexPtr, *replaceQueryPtr, *replaceDryRunPtr, database)
+}
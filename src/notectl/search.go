@@ -0,0 +1,87 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// errNoFTS5 is returned when SQLite was built without the FTS5 extension.
+var errNoFTS5 = errors.New("full-text search needs notectl built with FTS5: make build, or go build -tags sqlite_fts5")
+
+// searchNotes prints the notes matching an FTS5 query and carrying all of
+// tags, best matches first, with the matched terms highlighted in a snippet.
+// Matches in titles rank above matches in tags, which rank above matches in
+// the body.
+func searchNotes(match string, tags []string, limit int, database *sql.DB) error {
+	options := notes.SearchOptions{Tags: tags, Limit: limit, MarkStart: "[", MarkEnd: "]", Ellipsis: ellipsis()}
+	if jsonOutput {
+		options.MarkStart, options.MarkEnd = "", ""
+	} else if useColor {
+		options.MarkStart, options.MarkEnd = colorBold+colorYellow, colorReset
+	}
+	results, err := noteStore(database).Search(match, options)
+	if err == notes.ErrNoFTS5 {
+		return errNoFTS5
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return errNoMatch
+	}
+	if jsonOutput {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	for _, r := range results {
+		fmt.Printf("%d - %s - %s, tags: %s\n    %s\n", r.ID, formatDateTime(r.Created), r.Title, formatTagList(notes.FormatTags(r.Tags)), r.Snippet)
+	}
+	return nil
+}
+
+// runSearch finds notes with the full text search index.
+func runSearch(args []string) error {
+	searchCommand := newFlagSet("search")
+	searchTagPtr := searchCommand.String("tag", "", "Only find notes with all of these comma-delimited tags.")
+	searchLimitPtr := searchCommand.Int("limit", 20, "Show at most this many notes, 0 shows all.")
+	searchJSONPtr := searchCommand.Bool("json", false, "Print the notes found as a JSON array.")
+	searchRawPtr := searchCommand.Bool("fts", false, "Take the query as FTS5 syntax, with AND, OR, NOT, NEAR and column filters.")
+	searchReindexPtr := searchCommand.Bool("reindex", false, "Rebuild the search index before searching.")
+	positional, err := parseInterspersed(searchCommand, args)
+	if err != nil {
+		return err
+	}
+	words := strings.Join(positional, " ")
+	match := notes.FTSQuery(words)
+	if *searchRawPtr {
+		match = words
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	if *searchReindexPtr {
+		if err := notes.EnsureSearchIndex(database); err == notes.ErrNoFTS5 {
+			return errNoFTS5
+		} else if err != nil {
+			return err
+		}
+		if err := notes.RebuildSearchIndex(database); err != nil {
+			return err
+		}
+		if words == "" {
+			return nil
+		}
+	}
+	jsonOutput = *searchJSONPtr
+	return searchNotes(match, splitList(*searchTagPtr), *searchLimitPtr, database)
+}
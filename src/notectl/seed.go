@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// seedWords are the words synthetic notes are written with.
+var seedWords = strings.Fields(`the a to of and in for on with from about after before
+meeting project release deploy database query index server client customer
+design review plan budget team roadmap incident outage alert metric latency
+backup migration schema feature bug fix test build pipeline cache queue
+idea question answer decision note follow-up draft summary agenda action
+today tomorrow week month quarter morning afternoon evening
+check update write read call email ship merge discuss agree ask decide
+slow fast broken stable urgent later important simple large small new old
+postgres sqlite kubernetes terraform golang python frontend backend api`)
+
+// seedTags are the tags synthetic notes are given. Earlier tags are used
+// more often, as in real databases.
+var seedTags = []string{"work", "idea", "meeting", "todo", "personal", "reading", "incident", "project-x", "journal", "recipe", "travel", "health", "finance", "learning", "someday"}
+
+// seedNote writes a synthetic note. Most notes are a sentence or two, some
+// run to several paragraphs with headings and lists.
+func seedNote(rng *rand.Rand, when time.Time) note {
+	sentence := func() string {
+		words := make([]string, 4+rng.Intn(12))
+		for i := range words {
+			words[i] = seedWords[rng.Intn(len(seedWords))]
+		}
+		s := strings.Join(words, " ")
+		return strings.ToUpper(s[:1]) + s[1:] + "."
+	}
+	var text strings.Builder
+	switch r := rng.Float64(); {
+	case r < 0.6:
+		for i := 1 + rng.Intn(2); i > 0; i-- {
+			text.WriteString(sentence() + " ")
+		}
+	case r < 0.9:
+		fmt.Fprintf(&text, "# %s\n\n", strings.TrimSuffix(sentence(), "."))
+		for p := 1 + rng.Intn(3); p > 0; p-- {
+			for i := 2 + rng.Intn(4); i > 0; i-- {
+				text.WriteString(sentence() + " ")
+			}
+			text.WriteString("\n\n")
+		}
+	default:
+		fmt.Fprintf(&text, "# %s\n\n", strings.TrimSuffix(sentence(), "."))
+		for s := 2 + rng.Intn(4); s > 0; s-- {
+			fmt.Fprintf(&text, "## %s\n\n", strings.TrimSuffix(sentence(), "."))
+			for i := 2 + rng.Intn(6); i > 0; i-- {
+				text.WriteString(sentence() + " ")
+			}
+			text.WriteString("\n\n")
+			for i := rng.Intn(5); i > 0; i-- {
+				checkbox := ""
+				if rng.Intn(3) == 0 {
+					checkbox = "[ ] "
+				}
+				fmt.Fprintf(&text, "- %s%s\n", checkbox, sentence())
+			}
+			text.WriteString("\n")
+		}
+	}
+
+	zipf := rand.NewZipf(rng, 1.2, 1, uint64(len(seedTags)-1))
+	var tags tagList
+	for i := 1 + rng.Intn(3); i > 0; i-- {
+		tags = addTags(tags, tagList{seedTags[zipf.Uint64()]})
+	}
+	status := ""
+	if rng.Intn(5) == 0 {
+		status = noteStatuses[rng.Intn(len(noteStatuses))]
+	}
+	uuid := make([]byte, 16)
+	rng.Read(uuid)
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return note{
+		Time:   when,
+		Text:   strings.TrimSpace(text.String()),
+		Tags:   tags,
+		Status: status,
+		UUID:   fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]),
+	}
+}
+
+// seedDatabase fills an empty database with count synthetic notes spread over
+// the days before until. The same seed always gives the same notes.
+func seedDatabase(count int, seed int64, days int, until time.Time, force bool, database *sql.DB) error {
+	var existing int
+	if err := database.QueryRow("SELECT count(*) FROM notes").Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 && !force {
+		return fmt.Errorf("the database already holds %d notes; seed an empty one, for example with NOTECTL_DB=/tmp/seed.db, or pass -force", existing)
+	}
+	if days < 1 {
+		return errors.New("-days must be at least 1")
+	}
+	// Seeding is for throwaway databases, so trade durability for speed. The
+	// pragma applies per connection, hence the single one.
+	database.SetMaxOpenConns(1)
+	if _, err := database.Exec("PRAGMA synchronous = OFF"); err != nil {
+		return err
+	}
+	rng := rand.New(rand.NewSource(seed))
+	start := until.AddDate(0, 0, -days)
+	span := int64(until.Sub(start) / time.Second)
+	times := make([]time.Time, count)
+	for i := range times {
+		times[i] = start.Add(time.Duration(rng.Int63n(span)) * time.Second)
+	}
+	// Notes are saved oldest first, as they would have been written.
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	interrupt, stop := notifyInterrupt()
+	defer stop()
+	for i, when := range times {
+		if interrupted(interrupt) {
+			return fmt.Errorf("interrupted after seeding %d of %d notes", i, count)
+		}
+		n := seedNote(rng, when)
+		if err := n.Save(database); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Seeded %d notes from %s to %s with seed %d\n", count, start.Format(dueDateFormat), until.Format(dueDateFormat), seed)
+	return nil
+}
+
+// runDev dispatches the "dev seed" subcommand.
+func runDev(args []string, database *sql.DB) error {
+	usage := "usage: notectl dev seed [-notes n] [-seed n] [-days n] [-until <yyyy>-<mm>-<dd>] [-force]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "seed":
+		seedCommand := newFlagSet("dev seed")
+		notesPtr := seedCommand.Int("notes", 1000, "How many notes to create.")
+		seedPtr := seedCommand.Int64("seed", 1, "Seed for the random generator; the same seed gives the same notes.")
+		daysPtr := seedCommand.Int("days", 730, "How many days before -until the notes are spread over.")
+		untilPtr := seedCommand.String("until", "2025-01-01", "Date of the newest possible note, fixed so seeded databases are reproducible.")
+		forcePtr := seedCommand.Bool("force", false, "Add notes to a database that already has some.")
+		if err := parseFlags(seedCommand, args[1:]); err != nil {
+			return err
+		}
+		until, err := parseDueDate(*untilPtr)
+		if err != nil {
+			return err
+		}
+		return seedDatabase(*notesPtr, *seedPtr, *daysPtr, until, *forcePtr, database)
+	}
+	return errors.New(usage)
+}
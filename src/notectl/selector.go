@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// dateSelectors name periods relative to now that stand in for dates, as in
+// notectl show today or notectl export yesterday -to markdown -dir out.
+var dateSelectors = []string{"today", "yesterday", "this-week", "last-week", "this-month", "last-month", "this-year", "last-year"}
+
+// dateSelectorRange returns the start and end of the period a selector names,
+// the end being the start of the following period.
+func dateSelectorRange(selector string, now time.Time) (time.Time, time.Time, bool) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	week := day.AddDate(0, 0, -((int(day.Weekday()) - int(weekStart()) + 7) % 7))
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	year := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(selector) {
+	case "today":
+		return day, day.AddDate(0, 0, 1), true
+	case "yesterday":
+		return day.AddDate(0, 0, -1), day, true
+	case "this-week":
+		return week, week.AddDate(0, 0, 7), true
+	case "last-week":
+		return week.AddDate(0, 0, -7), week, true
+	case "this-month":
+		return month, month.AddDate(0, 1, 0), true
+	case "last-month":
+		return month.AddDate(0, -1, 0), month, true
+	case "this-year":
+		return year, year.AddDate(1, 0, 0), true
+	case "last-year":
+		return year.AddDate(-1, 0, 0), year, true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// addDateSelector restricts filter to the period a selector names, reporting
+// whether it was one.
+func addDateSelector(filter *queryFilter, selector string) bool {
+	start, end, ok := dateSelectorRange(selector, time.Now())
+	if ok {
+		filter.Add("timestamp >= (?) AND timestamp < (?)", start.Unix(), end.Unix())
+	}
+	return ok
+}
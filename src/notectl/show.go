@@ -0,0 +1,366 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// runShow prints notes by ID, alias or range, lists those matching the
+// filters, or exports one note to a portable file.
+func runShow(args []string) error {
+	showCommand := newFlagSet("show")
+	showAllPtr := showCommand.Bool("all", false, "Show all notes.")
+	showByIDPtr := showCommand.Int("i", -1, "Show a note based of the ID it has assigned to it.")
+	showByDayPtr := showCommand.Int("day", -1, "Show notes from the specified day of the current month and year.")
+	showByMonthPtr := showCommand.Int("month", -1, "Show notes from the specified month of the current year.")
+	showByYearPtr := showCommand.Int("year", -1, "Show notes from the specified year.")
+	showByDatePtr := showCommand.String("date", "", "Show notes by date in the format <d>/<m>/<y>.")
+	showUSADatePtr := showCommand.Bool("usa", false, "Allows for searching by date in US format <m>/<d>/<y>.")
+	showTagsPtr := showCommand.String("t", "", "Show notes with all of these comma-delimited tags.")
+	showGrepPtr := showCommand.String("grep", "", "Show notes matching this regular expression, or with -i print only the lines of the note matching it.")
+	showAfterPtr := showCommand.Int("A", 0, "With -grep, lines of context to print after each match.")
+	showBeforePtr := showCommand.Int("B", 0, "With -grep, lines of context to print before each match.")
+	showContextPtr := showCommand.Int("C", 0, "With -grep, lines of context to print around each match.")
+	showNoHighlightPtr := showCommand.Bool("no-highlight", false, "Do not highlight matched text.")
+	showRepoPtr := showCommand.String("repo", "", "Show commits recorded by the git hook for a repository.")
+	showHerePtr := showCommand.Bool("here", false, "Show notes associated with the current git repository or directory.")
+	showLangPtr := showCommand.String("lang", "", "Show notes written in a language, given as a two letter code such as de.")
+	showExportPtr := showCommand.String("export", "", "With -i, write the note and its attachments to a portable file.")
+	showEncryptPtr := showCommand.Bool("encrypt", false, "With -export, encrypt the file with a passphrase.")
+	showJSONPtr := showCommand.Bool("json", false, "Print the notes as a JSON array.")
+	showLimitPtr := showCommand.Int("limit", 0, "Show at most this many notes, 0 shows all.")
+	showAfterCursorPtr := showCommand.String("after", "", "Continue a listing after the cursor printed at the end of the previous page.")
+	showPreviewLinesPtr := showCommand.Int("preview-lines", listPreview.Lines, "Preview the first N lines of each note instead of its first characters, 0 previews characters.")
+	showPreviewLengthPtr := showCommand.Int("preview-length", listPreview.Length, "Preview at most N characters of each note, or of each line with -preview-lines. 0 shows notes whole.")
+	if err := parseFlags(showCommand, args); err != nil {
+		return err
+	}
+	var err error
+	if *showByIDPtr != -1 {
+		err = validateID(*showByIDPtr)
+	}
+	if err == nil && *showByDayPtr != -1 {
+		err = validateDay(*showByDayPtr)
+	}
+	if err == nil && *showByMonthPtr != -1 {
+		err = validateMonth(*showByMonthPtr)
+	}
+	if err == nil && *showByYearPtr != -1 {
+		err = validateYear(*showByYearPtr)
+	}
+	if err != nil {
+		return err
+	}
+	if *showNoHighlightPtr {
+		useColor = false
+	}
+	jsonOutput = *showJSONPtr
+	if *showPreviewLinesPtr < 0 || *showPreviewLengthPtr < 0 {
+		return errors.New("-preview-lines and -preview-length cannot be negative")
+	}
+	listPreview = preview{Lines: *showPreviewLinesPtr, Length: *showPreviewLengthPtr}
+	page := notePage{Limit: *showLimitPtr}
+	if *showAfterCursorPtr != "" {
+		cursor, err := parseCursor(*showAfterCursorPtr)
+		if err != nil {
+			return err
+		}
+		page.After = &cursor
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	// Bare arguments name the notes to show by ID, ID range or alias, or a
+	// period such as today.
+	filters := showFilters{
+		Tags:  *showTagsPtr,
+		Day:   *showByDayPtr,
+		Month: *showByMonthPtr,
+		Year:  *showByYearPtr,
+		Date:  *showByDatePtr,
+		USA:   *showUSADatePtr,
+		Repo:  *showRepoPtr,
+		Here:  *showHerePtr,
+		Lang:  *showLangPtr,
+		Grep:  *showGrepPtr,
+	}
+	var showRefs []string
+	if _, _, ok := dateSelectorRange(showCommand.Arg(0), time.Now()); ok && showCommand.NArg() == 1 {
+		filters.Period = showCommand.Arg(0)
+	} else if showCommand.NArg() > 0 && *showByIDPtr == -1 {
+		showRefs = showCommand.Args()
+	}
+	if _, _, isRange := parseIDRange(showCommand.Arg(0)); len(showRefs) == 1 && !isRange {
+		showRefs = nil
+		id, err := resolveNoteRef(showCommand.Arg(0), database)
+		if err != nil {
+			return err
+		}
+		*showByIDPtr = id
+	}
+	if *showExportPtr != "" {
+		if *showByIDPtr == -1 {
+			return errors.New("-export requires -i <id>")
+		}
+		passphrase := ""
+		if *showEncryptPtr {
+			if passphrase, err = exportPassphrase(); err != nil {
+				return err
+			}
+		}
+		if err := exportNote(*showByIDPtr, *showExportPtr, passphrase, database); err != nil {
+			return err
+		}
+	} else if *showGrepPtr != "" && *showByIDPtr != -1 {
+		before, after := *showBeforePtr, *showAfterPtr
+		if before == 0 {
+			before = *showContextPtr
+		}
+		if after == 0 {
+			after = *showContextPtr
+		}
+		matches, err := grepNote(*showByIDPtr, *showGrepPtr, before, after, database)
+		if err != nil {
+			return err
+		}
+		if matches == 0 {
+			return exitStatus(exitNoMatch)
+		}
+	} else if len(showRefs) > 0 {
+		err = showNotesByRef(showRefs, database)
+	} else if *showByIDPtr != -1 {
+		err = showNoteByID(*showByIDPtr, database)
+	} else if filters.active() || *showAllPtr {
+		var filter queryFilter
+		if filter, err = filters.build(database); err == nil {
+			err = listNotes(filter, page, database)
+		}
+	} else {
+		showCommand.PrintDefaults()
+		return exitStatus(1)
+	}
+	return err
+}
+
+// jsonOutput makes listings print notes as a JSON array, set by show -json.
+var jsonOutput bool
+
+// jsonNote is a note as printed with -json. Text holds what the listing read,
+// which is cut short in list views when Size is larger.
+type jsonNote struct {
+	ID      int       `json:"id"`
+	Created time.Time `json:"created"`
+	Title   string    `json:"title"`
+	Text    string    `json:"text"`
+	Size    int       `json:"size"`
+	Tags    tagList   `json:"tags"`
+	Status  string    `json:"status,omitempty"`
+}
+
+// printRows lists notes, showing the start of each as selected by
+// noteListColumns.
+func printRows(rows *sql.Rows) error {
+	_, _, err := printNoteRows(rows, listPreview)
+	return err
+}
+
+// printMatches lists notes like printRows, returning errNoMatch when there
+// were none.
+func printMatches(rows *sql.Rows) error {
+	_, count, err := printNoteRows(rows, listPreview)
+	if err == nil && count == 0 {
+		return errNoMatch
+	}
+	return err
+}
+
+// printNoteRows prints notes, cut down to the given preview, or as JSON with
+// jsonOutput. It returns the position of the last note and how many there
+// were.
+func printNoteRows(rows *sql.Rows, p preview) (pageCursor, int, error) {
+	var last pageCursor
+	count := 0
+	listed := []jsonNote{}
+	var id int
+	var day int
+	var month string
+	var year int
+	var timestamp int
+	var notetext noteText
+	var tags string
+	var status string
+	var title sql.NullString
+	var textsize sql.NullInt64
+	fetch := time.Now()
+	for rows.Next() {
+		rows.Scan(&id, &day, &month, &year, &timestamp, &notetext, &tags, &status, &title, &textsize)
+		profileSince("query", fetch)
+		render := time.Now()
+		last = pageCursor{int64(timestamp), id}
+		count++
+		if jsonOutput {
+			size := int(textsize.Int64)
+			if !textsize.Valid {
+				size = len(notetext)
+			}
+			listed = append(listed, jsonNote{id, time.Unix(int64(timestamp), 0), title.String, string(notetext), size, parseTags(tags), status})
+			fetch = time.Now()
+			continue
+		}
+		heading := formatDateTime(time.Unix(int64(timestamp), 0))
+		if !title.Valid || title.String == "" {
+			title.String = plainTitle(string(notetext))
+		}
+		// Short notes are their own title; only longer ones need it spelled out.
+		if title.String != strings.TrimSpace(string(notetext)) {
+			heading += " - " + title.String
+		}
+		text := noteSnippet(string(notetext), int(textsize.Int64), id, p)
+		if status != "" {
+			fmt.Printf("%d - %s: %s, tags: %s, status: %s\n", id, heading, highlightMatches(text), formatTagList(tags), status)
+		} else {
+			fmt.Printf("%d - %s: %s, tags: %s\n", id, heading, highlightMatches(text), formatTagList(tags))
+		}
+		profileSince("render", render)
+		fetch = time.Now()
+	}
+	profileSince("query", fetch)
+	if err := rows.Err(); err != nil {
+		return last, count, err
+	}
+	if jsonOutput {
+		render := time.Now()
+		data, err := json.MarshalIndent(listed, "", "  ")
+		if err != nil {
+			return last, count, err
+		}
+		fmt.Println(string(data))
+		profileSince("render", render)
+	}
+	return last, count, nil
+}
+
+// noteSnippet cuts the text of a note down to a preview, and points at the
+// full note when it was cut, here or by the query that loaded it.
+func noteSnippet(text string, size int, id int, p preview) string {
+	snippet, cut := p.cut(text)
+	if !cut && size <= len(text) {
+		return text
+	}
+	return fmt.Sprintf("%s%s (%d bytes, notectl show -i %d for the full note)", strings.TrimRight(snippet, " \n"), ellipsis(), size, id)
+}
+
+func showNoteByID(id int, database *sql.DB) error {
+	rows, err := database.Query("SELECT "+noteColumns+" FROM notes WHERE id = (?)", id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	_, count, err := printNoteRows(rows, preview{})
+	if err == nil && count == 0 {
+		return errNoMatch
+	}
+	return err
+}
+
+// showNotesByRef shows the notes named by IDs, ID ranges and aliases in full,
+// in ID order.
+func showNotesByRef(refs []string, database *sql.DB) error {
+	filter, err := noteRefFilter(refs, database)
+	if err != nil {
+		return err
+	}
+	rows, err := database.Query("SELECT "+noteColumns+" FROM notes"+filter.Where()+" ORDER BY id", filter.Args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	_, count, err := printNoteRows(rows, preview{})
+	if err == nil && count == 0 {
+		return errNoMatch
+	}
+	return err
+}
+
+// showFilters are the show flags that narrow a listing. Every one given must
+// match.
+type showFilters struct {
+	Tags   string
+	Day    int
+	Month  int
+	Year   int
+	Date   string
+	USA    bool
+	Period string
+	Repo   string
+	Here   bool
+	Lang   string
+	Grep   string
+}
+
+// active reports whether any filter was given.
+func (f showFilters) active() bool {
+	return f.Tags != "" || f.Day != -1 || f.Month != -1 || f.Year != -1 || f.Date != "" || f.Period != "" || f.Repo != "" || f.Here || f.Lang != "" || f.Grep != ""
+}
+
+// build turns the filters into query conditions. A day without a month is in
+// the current month, and a month without a year in the current year.
+func (f showFilters) build(database *sql.DB) (queryFilter, error) {
+	var filter queryFilter
+	for _, tag := range splitList(f.Tags) {
+		filter.Add(tagMatchClause, tag)
+	}
+	day, month, year := f.Day, f.Month, f.Year
+	if f.Date != "" {
+		order := configValue("date.order", "dmy")
+		if f.USA {
+			order = "mdy"
+		}
+		var err error
+		if day, month, year, err = parseDate(f.Date, order); err != nil {
+			return filter, err
+		}
+	}
+	if day != -1 && month == -1 {
+		month = int(time.Now().Month())
+	}
+	if month != -1 && year == -1 {
+		year = time.Now().Year()
+	}
+	if day != -1 {
+		filter.Add("day = (?)", day)
+	}
+	if month != -1 {
+		filter.Add("month = (?)", month)
+	}
+	if year != -1 {
+		filter.Add("year = (?)", year)
+	}
+	if f.Period != "" {
+		addDateSelector(&filter, f.Period)
+	}
+	if f.Repo != "" {
+		addRepoFilter(&filter, f.Repo)
+	}
+	if f.Here {
+		if err := addHereFilter(&filter); err != nil {
+			return filter, err
+		}
+	}
+	if f.Lang != "" {
+		if err := addLanguageFilter(&filter, f.Lang, database); err != nil {
+			return filter, err
+		}
+	}
+	if f.Grep != "" {
+		if err := addGrepFilter(&filter, f.Grep, database); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxNoteSize is the largest note text accepted when note.max_size is
+// not configured.
+const DefaultMaxNoteSize = 1 << 20
+
+// summaryLines is how much of an oversized note is kept in the summary note.
+const summaryLines = 20
+
+// sizeSuffixes are the units parseSize understands, longest first.
+var sizeSuffixes = []struct {
+	suffix     string
+	multiplier int
+}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1}}
+
+// parseSize reads a byte count with an optional KB, MB or GB suffix.
+func parseSize(size string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := 1
+	for _, unit := range sizeSuffixes {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a number of bytes such as 512KB or 2MB", size)
+	}
+	return n * multiplier, nil
+}
+
+// maxNoteSize returns the configured note.max_size, 0 meaning unlimited.
+func maxNoteSize() (int, error) {
+	value := configValue("note.max_size", "")
+	if value == "" {
+		return DefaultMaxNoteSize, nil
+	}
+	return parseSize(value)
+}
+
+// noteTooLargeError is returned when note text is larger than note.max_size.
+type noteTooLargeError struct {
+	Size  int
+	Limit int
+}
+
+func (e noteTooLargeError) Error() string {
+	return fmt.Sprintf("note is %d bytes, larger than the %d byte limit set by note.max_size", e.Size, e.Limit)
+}
+
+// checkNoteSize enforces note.max_size on text about to be stored, returning
+// a noteTooLargeError when it is too large. Notes replayed from the journal
+// or restored from an archive were accepted when first written and are not
+// checked again.
+func checkNoteSize(text string) error {
+	if replaying || restoring {
+		return nil
+	}
+	limit, err := maxNoteSize()
+	if err != nil {
+		return err
+	}
+	if limit == 0 || len(text) <= limit {
+		return nil
+	}
+	return noteTooLargeError{len(text), limit}
+}
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// summarize keeps the first lines of a large text and says where the rest is.
+func summarize(text string, attachmentName string) string {
+	lines := strings.SplitN(text, "\n", summaryLines+1)
+	if len(lines) > summaryLines {
+		lines = lines[:summaryLines]
+	}
+	summary := []rune(strings.Join(lines, "\n"))
+	if len(summary) > 2048 {
+		summary = summary[:2048]
+	}
+	return fmt.Sprintf("%s\n\n[%s %d bytes in total, full text attached as %s]\n", strings.TrimRight(string(summary), "\n"), ellipsis(), len(text), attachmentName)
+}
+
+// saveNote saves a note. Notes larger than the configured size limit can
+// instead be stored as an attachment to a short summary note, which is
+// offered when running interactively.
+func saveNote(n *note, database *sql.DB) error {
+	err := checkNoteSize(n.Text)
+	tooLarge, ok := err.(noteTooLargeError)
+	if !ok {
+		if err != nil {
+			return err
+		}
+		n.PrintConsole()
+		return n.Save(database)
+	}
+	if !stdinIsTerminal() && !assumeYes {
+		return tooLarge
+	}
+	fmt.Printf("%v.\n", tooLarge)
+	summarized, err := confirm(nil, "Store the text as an attachment with a summary note instead?", false)
+	if err != nil || !summarized {
+		return tooLarge
+	}
+	full := n.Text
+	name := fmt.Sprintf("note-%s.txt", n.Time.Format("20060102-150405"))
+	n.Text = summarize(full, name)
+	n.PrintConsole()
+	if err := n.Save(database); err != nil {
+		return err
+	}
+	attachmentID, err := addAttachment(n.ID, name, "text/plain; charset=utf-8", []byte(full), database)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved note %d with the full text as attachment %d\n", n.ID, attachmentID)
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNoteSizeLimitAppliesToEveryWrite(t *testing.T) {
+	database := openTestDatabase(t)
+	small := saveTestNote(t, database, time.Date(2026, time.April, 1, 9, 0, 0, 0, time.UTC), "small")
+	setTestConfig(t, "note.max_size", "1KB")
+	huge := strings.Repeat("log line\n", 200)
+
+	n := note{Time: time.Now(), Text: huge}
+	if err := n.Save(database); err != (noteTooLargeError{len(huge), 1024}) {
+		t.Errorf("Save of %d bytes returned %v, want noteTooLargeError", len(huge), err)
+	}
+	if err := updateNoteText(small.ID, "small\n"+huge, database); err == nil {
+		t.Error("updateNoteText stored text over the limit")
+	}
+	if text, err := getNoteText(small.ID, database); err != nil || text != "small" {
+		t.Errorf("note text is %d bytes after refused update, %v", len(text), err)
+	}
+
+	replaying = true
+	defer func() { replaying = false }()
+	if err := updateNoteText(small.ID, huge, database); err != nil {
+		t.Errorf("replayed change was checked against the limit: %s", err)
+	}
+}
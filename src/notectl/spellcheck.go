@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// misspelling is a word the spellchecker did not recognise.
+type misspelling struct {
+	Line        int
+	Offset      int
+	Word        string
+	Suggestions []string
+}
+
+// spellchecker returns the command line of an ispell compatible checker for
+// the configured language, preferring hunspell over aspell.
+func spellchecker() ([]string, error) {
+	lang := configValue("spellcheck.lang", "en_US")
+	if command := configValue("spellcheck.command", ""); command != "" {
+		return append(strings.Fields(command), "-a"), nil
+	}
+	if path, err := exec.LookPath("hunspell"); err == nil {
+		return []string{path, "-a", "-d", lang}, nil
+	}
+	if path, err := exec.LookPath("aspell"); err == nil {
+		return []string{path, "-a", "--lang=" + lang}, nil
+	}
+	return nil, errors.New("no spellchecker found, install hunspell or aspell")
+}
+
+// checkSpelling runs text through the spellchecker's ispell pipe mode. Each
+// line is sent prefixed with ^ so it is never read as a checker command, and
+// the checker answers each line with one result per word and a blank line.
+func checkSpelling(text string) ([]misspelling, error) {
+	command, err := spellchecker()
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(text, "\n")
+	var input strings.Builder
+	for _, line := range lines {
+		input.WriteString("^" + line + "\n")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(input.String())
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", command[0], err)
+	}
+	var found []misspelling
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	scanner.Buffer(make([]byte, 64*1024), len(output)+1)
+	lineNo := 0
+	for scanner.Scan() {
+		result := scanner.Text()
+		switch {
+		case strings.HasPrefix(result, "@(#)"):
+			// Version banner.
+		case result == "":
+			lineNo++
+		case result[0] == '&' || result[0] == '#':
+			// "& word count offset: suggestion, ..." or "# word offset".
+			head, suggestions := result, ""
+			if i := strings.Index(result, ": "); i >= 0 {
+				head, suggestions = result[:i], result[i+2:]
+			}
+			fields := strings.Fields(head)
+			if len(fields) < 3 || lineNo >= len(lines) {
+				continue
+			}
+			offset, _ := strconv.Atoi(fields[len(fields)-1])
+			m := misspelling{Line: lineNo, Offset: offset, Word: fields[1]}
+			if suggestions != "" {
+				m.Suggestions = strings.Split(suggestions, ", ")
+			}
+			found = append(found, m)
+		}
+	}
+	return found, scanner.Err()
+}
+
+// wordIndex finds a misspelt word in its line. Checkers disagree on whether
+// offsets count the ^ prefix, so both positions are tried before searching.
+func wordIndex(line string, m misspelling) int {
+	for _, i := range []int{m.Offset - 1, m.Offset} {
+		if i >= 0 && strings.HasPrefix(line[i:], m.Word) {
+			return i
+		}
+	}
+	return strings.Index(line, m.Word)
+}
+
+// fixSpelling asks, for each misspelling, whether to replace it with a
+// suggestion or a typed word, or to ignore it. It returns text unchanged when
+// not running interactively.
+func fixSpelling(text string) (string, error) {
+	if !stdinIsTerminal() {
+		return text, nil
+	}
+	found, err := checkSpelling(text)
+	if err != nil || len(found) == 0 {
+		return text, err
+	}
+	lines := strings.Split(text, "\n")
+	reader := bufio.NewReader(os.Stdin)
+	ignored := make(map[string]bool)
+	// Fix from the end so earlier offsets stay valid after replacements.
+	for i := len(found) - 1; i >= 0; i-- {
+		m := found[i]
+		if ignored[m.Word] {
+			continue
+		}
+		line := lines[m.Line]
+		start := wordIndex(line, m)
+		if start < 0 {
+			continue
+		}
+		fmt.Printf("\n%d: %s\n", m.Line+1, line[:start]+colorize(colorRed, m.Word)+line[start+len(m.Word):])
+		for n, suggestion := range m.Suggestions {
+			if n == 9 {
+				break
+			}
+			fmt.Printf("  %d) %s\n", n+1, suggestion)
+		}
+		answer, err := prompt(reader, "Number or word to replace with, i to ignore, a to ignore everywhere, q to stop: ")
+		if err != nil {
+			return text, err
+		}
+		replacement := ""
+		switch answer {
+		case "", "i":
+			continue
+		case "a":
+			ignored[m.Word] = true
+			continue
+		case "q":
+			return strings.Join(lines, "\n"), nil
+		default:
+			replacement = answer
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(m.Suggestions) {
+				replacement = m.Suggestions[n-1]
+			}
+		}
+		lines[m.Line] = line[:start] + replacement + line[start+len(m.Word):]
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tags are decorated from the tag.color.<tag> and tag.emoji.<tag> settings.
+
+func tagColorKey(tag string) string { return "tag.color." + tag }
+
+func tagEmojiKey(tag string) string { return "tag.emoji." + tag }
+
+// formatTag renders a tag with its configured emoji and color.
+func formatTag(tag string) string {
+	label := tag
+	if emoji := configValue(tagEmojiKey(tag), ""); emoji != "" && !plainOutput {
+		label = emoji + " " + tag
+	}
+	if color, ok := namedColors[configValue(tagColorKey(tag), "")]; ok {
+		return colorize(color, label)
+	}
+	return label
+}
+
+// formatTagList renders stored tags, "[a b]", with their decorations.
+func formatTagList(tags string) string {
+	list := parseTags(tags)
+	formatted := make([]string, len(list))
+	for i, tag := range list {
+		formatted[i] = formatTag(tag)
+	}
+	return "[" + strings.Join(formatted, " ") + "]"
+}
+
+// runTagStyle dispatches "tags color" and "tags emoji", which set or remove a
+// tag's decoration in the configuration file.
+func runTagStyle(kind string, args []string) error {
+	usage := fmt.Sprintf("usage: notectl tags %s <set <tag> <%s>|rm <tag>|list>", kind, kind)
+	key := tagColorKey
+	if kind == "emoji" {
+		key = tagEmojiKey
+	}
+	switch {
+	case len(args) == 3 && args[0] == "set":
+		value := args[2]
+		if kind == "color" {
+			value = strings.ToLower(value)
+			if _, ok := namedColors[value]; !ok {
+				var names []string
+				for name := range namedColors {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return fmt.Errorf("unknown color %q, expected one of %s", value, strings.Join(names, ", "))
+			}
+		}
+		if err := setConfigValue(key(args[1]), value); err != nil {
+			return err
+		}
+		fmt.Println(formatTag(args[1]))
+		return nil
+	case len(args) == 2 && args[0] == "rm":
+		return setConfigValue(key(args[1]), "")
+	case len(args) == 1 && args[0] == "list":
+		prefix := key("")
+		var tags []string
+		for k := range config {
+			if strings.HasPrefix(k, prefix) {
+				tags = append(tags, strings.TrimPrefix(k, prefix))
+			}
+		}
+		sort.Strings(tags)
+		for _, tag := range tags {
+			fmt.Printf("%s  %s\n", formatTag(tag), config[key(tag)])
+		}
+		return nil
+	}
+	return errors.New(usage)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// loadTagSets returns the tags of every note.
+func loadTagSets(database *sql.DB) ([]tagList, error) {
+	rows, err := database.Query("SELECT tags FROM notes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sets []tagList
+	var tags string
+	for rows.Next() {
+		if err := rows.Scan(&tags); err != nil {
+			return nil, err
+		}
+		sets = append(sets, parseTags(tags))
+	}
+	return sets, rows.Err()
+}
+
+func countTagUse(sets []tagList) map[string]int {
+	counts := make(map[string]int)
+	for _, set := range sets {
+		for _, tag := range set {
+			counts[tag]++
+		}
+	}
+	return counts
+}
+
+func sortTagCounts(counts map[string]int) []tagCount {
+	var list []tagCount
+	for name, count := range counts {
+		list = append(list, tagCount{Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count == list[j].Count {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Count > list[j].Count
+	})
+	return list
+}
+
+func listTags(database *sql.DB) error {
+	sets, err := loadTagSets(database)
+	if err != nil {
+		return err
+	}
+	for _, t := range sortTagCounts(countTagUse(sets)) {
+		fmt.Printf("%5d  %s\n", t.Count, formatTag(t.Name))
+	}
+	return nil
+}
+
+// showRelatedTags lists the tags used together with tag, strongest first, and
+// suggests merging tags that (almost) never appear without each other.
+func showRelatedTags(tag string, database *sql.DB) error {
+	sets, err := loadTagSets(database)
+	if err != nil {
+		return err
+	}
+	use := countTagUse(sets)
+	if use[tag] == 0 {
+		return fmt.Errorf("no notes are tagged %q", tag)
+	}
+	together := make(map[string]int)
+	for _, set := range sets {
+		has := false
+		for _, t := range set {
+			if t == tag {
+				has = true
+			}
+		}
+		if !has {
+			continue
+		}
+		for _, t := range set {
+			if t != tag {
+				together[t]++
+			}
+		}
+	}
+	related := sortTagCounts(together)
+	if len(related) == 0 {
+		fmt.Printf("%q (%d notes) is never used with other tags.\n", tag, use[tag])
+		return nil
+	}
+	fmt.Printf("Tags used with %q (%d notes):\n", tag, use[tag])
+	var suggestions []string
+	for _, r := range related {
+		union := use[tag] + use[r.Name] - r.Count
+		jaccard := float64(r.Count) / float64(union)
+		fmt.Printf("  %-20s %5d together  %3.0f%% of %q  %3.0f%% of %q  overlap %.2f\n",
+			r.Name, r.Count, percent(int64(r.Count), int64(use[tag])), tag, percent(int64(r.Count), int64(use[r.Name])), r.Name, jaccard)
+		switch {
+		case r.Count == use[tag] && r.Count == use[r.Name]:
+			suggestions = append(suggestions, fmt.Sprintf("%q and %q always appear together, consider keeping only one", tag, r.Name))
+		case r.Count == use[r.Name]:
+			suggestions = append(suggestions, fmt.Sprintf("%q only ever appears with %q, it may be redundant", r.Name, tag))
+		case r.Count == use[tag]:
+			suggestions = append(suggestions, fmt.Sprintf("%q only ever appears with %q, it may be redundant", tag, r.Name))
+		}
+	}
+	if len(suggestions) > 0 {
+		fmt.Println("\nSuggestions:")
+		for _, s := range suggestions {
+			fmt.Printf("  %s\n", s)
+		}
+	}
+	return nil
+}
+
+// runTags dispatches the "tags list" and "tags related" subcommands.
+func runTags(args []string, database *sql.DB) error {
+	usage := "usage: notectl tags <list|related <tag>|color ...|emoji ...>"
+	switch {
+	case len(args) > 0 && (args[0] == "color" || args[0] == "emoji"):
+		return runTagStyle(args[0], args[1:])
+	case len(args) == 0, args[0] == "list" && len(args) == 1:
+		return listTags(database)
+	case args[0] == "related" && len(args) == 2:
+		return showRelatedTags(args[1], database)
+	}
+	return errors.New(usage)
+}
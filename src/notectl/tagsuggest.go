@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+func min3(a int, b int, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// isSubsequence reports whether the runes of short appear in long in order,
+// so "mtg" fuzzily matches "meeting".
+func isSubsequence(short string, long string) bool {
+	rs := []rune(short)
+	i := 0
+	for _, r := range long {
+		if i < len(rs) && r == rs[i] {
+			i++
+		}
+	}
+	return i == len(rs)
+}
+
+// similarTag returns the known tag most likely meant by tag: a singular or
+// plural form, or a near spelling. Ties go to the more used tag.
+func similarTag(tag string, known map[string]int) (string, bool) {
+	best, bestDistance := "", -1
+	limit := 1 + len([]rune(tag))/4
+	for candidate, count := range known {
+		distance := editDistance(tag, candidate)
+		if candidate+"s" == tag || tag+"s" == candidate || candidate+"es" == tag || tag+"es" == candidate {
+			distance = 0
+		}
+		if distance > limit {
+			continue
+		}
+		if bestDistance < 0 || distance < bestDistance || distance == bestDistance && count > known[best] {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, bestDistance >= 0
+}
+
+// matchingTags lists known tags starting with or fuzzily matching prefix,
+// most used first.
+func matchingTags(prefix string, known map[string]int) []string {
+	var matches []tagCount
+	for tag, count := range known {
+		if strings.HasPrefix(tag, prefix) || isSubsequence(prefix, tag) {
+			matches = append(matches, tagCount{tag, count})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		iPrefix, jPrefix := strings.HasPrefix(matches[i].Name, prefix), strings.HasPrefix(matches[j].Name, prefix)
+		if iPrefix != jPrefix {
+			return iPrefix
+		}
+		return matches[i].Count > matches[j].Count
+	})
+	var names []string
+	for i, m := range matches {
+		if i == 10 {
+			break
+		}
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+// knownTags counts the tags in use across all notes.
+func knownTags(database *sql.DB) (map[string]int, error) {
+	sets, err := loadTagSets(database)
+	if err != nil {
+		return nil, err
+	}
+	return countTagUse(sets), nil
+}
+
+// promptTags asks for comma-delimited tags. Ending the input with ? lists
+// known tags matching it instead, and each unknown tag that looks like an
+// existing one, say a plural or a typo, is offered as that tag instead.
+func promptTags(reader *bufio.Reader, question string, database *sql.DB) (tagList, error) {
+	known, err := knownTags(database)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		input, err := prompt(reader, question)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(input, "?") {
+			prefix := strings.TrimSpace(strings.TrimSuffix(input[strings.LastIndex(input, ",")+1:], "?"))
+			if matches := matchingTags(prefix, known); len(matches) > 0 {
+				fmt.Println(strings.Join(matches, ", "))
+			} else {
+				fmt.Println("No matching tags.")
+			}
+			continue
+		}
+		var tags tagList
+		for _, tag := range strings.Split(input, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "" {
+				continue
+			}
+			if known[tag] == 0 {
+				if similar, ok := similarTag(tag, known); ok {
+					ok, err := confirm(reader, "No notes are tagged %q, use %q instead?", true, tag, similar)
+					if err != nil {
+						return nil, err
+					}
+					if ok {
+						tag = similar
+					}
+				}
+			}
+			tags = append(tags, tag)
+		}
+		return tags, nil
+	}
+}
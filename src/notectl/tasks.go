@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// taskKeyPrefix starts the metadata keys linking a note's checkbox to a
+// Taskwarrior task. The rest of the key identifies the checkbox by its text.
+const taskKeyPrefix = "taskwarrior:"
+
+// taskwarriorTag marks tasks created from notes.
+const taskwarriorTag = "notectl"
+
+var doneCheckboxPattern = regexp.MustCompile(`^\s*[-*+]\s+\[[xX]\]\s+(\S.*)$`)
+
+type taskwarriorTask struct {
+	UUID        string           `json:"uuid"`
+	Description string           `json:"description"`
+	Status      string           `json:"status"`
+	Entry       string           `json:"entry,omitempty"`
+	Tags        []string         `json:"tags,omitempty"`
+	Annotations []taskAnnotation `json:"annotations,omitempty"`
+}
+
+type taskAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// checkboxKey identifies a checkbox within a note by its text.
+func checkboxKey(text string) string {
+	return taskKeyPrefix + notes.Checksum(strings.TrimSpace(text))[:12]
+}
+
+// runTask runs the task command without prompts, feeding it stdin.
+func runTask(stdin []byte, args ...string) ([]byte, error) {
+	path, err := exec.LookPath("task")
+	if err != nil {
+		return nil, fmt.Errorf("taskwarrior not found (%s)", err)
+	}
+	cmd := exec.Command(path, append([]string{"rc.confirmation=off", "rc.verbose=nothing"}, args...)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+// tickCheckbox marks the open checkbox with the given key as done.
+func tickCheckbox(id int, key string, database *sql.DB) error {
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if m := openCheckboxPattern.FindStringSubmatch(line); m != nil && checkboxKey(m[1]) == key {
+			lines[i] = strings.Replace(line, "[ ]", "[x]", 1)
+			return updateNoteText(id, strings.Join(lines, "\n"), database)
+		}
+	}
+	return nil
+}
+
+// checkboxState reports whether the checkbox with the given key is still open
+// or has been ticked in the note's text.
+func checkboxState(id int, key string, database *sql.DB) (open bool, done bool, err error) {
+	text, err := getNoteText(id, database)
+	if err != nil {
+		return false, false, err
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if m := openCheckboxPattern.FindStringSubmatch(line); m != nil && checkboxKey(m[1]) == key {
+			open = true
+		}
+		if m := doneCheckboxPattern.FindStringSubmatch(line); m != nil && checkboxKey(m[1]) == key {
+			done = true
+		}
+	}
+	return open, done, nil
+}
+
+// syncTaskwarrior exports open checkboxes as Taskwarrior tasks and brings
+// completion back and forth: completing a task ticks its checkbox, and
+// ticking a checkbox completes its task.
+func syncTaskwarrior(database *sql.DB) error {
+	output, err := runTask(nil, "+"+taskwarriorTag, "export")
+	if err != nil {
+		return err
+	}
+	var tasks []taskwarriorTask
+	if err := json.Unmarshal(output, &tasks); err != nil {
+		return fmt.Errorf("reading taskwarrior export: %s", err)
+	}
+	status := make(map[string]string)
+	for _, task := range tasks {
+		status[task.UUID] = task.Status
+	}
+
+	type link struct {
+		NoteID int
+		Key    string
+		UUID   string
+	}
+	rows, err := database.Query("SELECT note_id, key, value FROM metadata WHERE key LIKE (?)", taskKeyPrefix+"%")
+	if err != nil {
+		return err
+	}
+	var links []link
+	linked := make(map[int]map[string]bool)
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.NoteID, &l.Key, &l.UUID); err != nil {
+			rows.Close()
+			return err
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+
+	ticked, completed := 0, 0
+	for _, l := range links {
+		open, done, err := checkboxState(l.NoteID, l.Key, database)
+		if err != nil {
+			return err
+		}
+		finished := false
+		switch status[l.UUID] {
+		case "completed":
+			if open {
+				if err := tickCheckbox(l.NoteID, l.Key, database); err != nil {
+					return err
+				}
+				ticked++
+			}
+			finished = true
+		case "pending", "waiting":
+			if done && !open {
+				if _, err := runTask(nil, l.UUID, "done"); err != nil {
+					return err
+				}
+				completed++
+				finished = true
+			} else if !open {
+				// The checkbox was removed from the note; leave the task be.
+				finished = true
+			}
+		default:
+			// Deleted in Taskwarrior, or gone from its database entirely.
+			finished = true
+		}
+		if finished {
+			if err := deleteNoteMeta(l.NoteID, l.Key, database); err != nil {
+				return err
+			}
+			continue
+		}
+		if linked[l.NoteID] == nil {
+			linked[l.NoteID] = make(map[string]bool)
+		}
+		linked[l.NoteID][l.Key] = true
+	}
+
+	var query noteQuery
+	query.Filter.Add("status NOT IN ('done', 'archived')")
+	found, err := findNotes(query, database)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format("20060102T150405Z")
+	var created []taskwarriorTask
+	var createdKeys []link
+	for _, n := range found {
+		for _, line := range strings.Split(n.Text, "\n") {
+			m := openCheckboxPattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			key := checkboxKey(m[1])
+			if linked[n.ID][key] {
+				continue
+			}
+			if linked[n.ID] == nil {
+				linked[n.ID] = make(map[string]bool)
+			}
+			linked[n.ID][key] = true
+			task := taskwarriorTask{
+				UUID:        notes.NewUUID(),
+				Description: strings.TrimSpace(m[1]),
+				Status:      "pending",
+				Entry:       now,
+				Tags:        []string{taskwarriorTag},
+				Annotations: []taskAnnotation{{now, fmt.Sprintf("notectl note %d", n.ID)}},
+			}
+			created = append(created, task)
+			createdKeys = append(createdKeys, link{n.ID, key, task.UUID})
+		}
+	}
+	if len(created) > 0 {
+		data, err := json.Marshal(created)
+		if err != nil {
+			return err
+		}
+		if _, err := runTask(data, "import", "-"); err != nil {
+			return err
+		}
+		for _, l := range createdKeys {
+			if err := setNoteMeta(l.NoteID, l.Key, l.UUID, database); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Printf("Created %d tasks, ticked %d checkboxes, completed %d tasks\n", len(created), ticked, completed)
+	return nil
+}
+
+// runTasks dispatches the "tasks sync" subcommand.
+func runTasks(args []string, database *sql.DB) error {
+	usage := "usage: notectl tasks sync -taskwarrior"
+	if len(args) == 0 || args[0] != "sync" {
+		return errors.New(usage)
+	}
+	syncCommand := newFlagSet("tasks sync")
+	taskwarriorPtr := syncCommand.Bool("taskwarrior", false, "Sync open checkboxes with Taskwarrior.")
+	if err := parseFlags(syncCommand, args[1:]); err != nil {
+		return err
+	}
+	if !*taskwarriorPtr {
+		return errors.New(usage)
+	}
+	return syncTaskwarrior(database)
+}
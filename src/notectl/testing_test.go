@@ -0,0 +1,56 @@
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestDatabase returns a new database in a temporary directory, with the
+// schema created.
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "notectl-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database, err := connectToDatabase(filepath.Join(dir, "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		database.Close()
+		os.RemoveAll(dir)
+	})
+	if err := createTableIfNotExist(database); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+// saveTestNote saves a note with the given text and tags, created at.
+func saveTestNote(t *testing.T, database *sql.DB, at time.Time, text string, tags ...string) note {
+	t.Helper()
+	n := note{Time: at, Text: text, Tags: tags}
+	if err := n.Save(database); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// setTestConfig sets a config value for the rest of the test.
+func setTestConfig(t *testing.T, key string, value string) {
+	t.Helper()
+	previous, had := config[key]
+	config[key] = value
+	t.Cleanup(func() {
+		if had {
+			config[key] = previous
+		} else {
+			delete(config, key)
+		}
+	})
+}
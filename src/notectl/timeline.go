@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// parseSince turns a relative period such as "7d", "2w" or "36h" into the
+// point in time that far back from now.
+func parseSince(since string) (time.Time, error) {
+	now := time.Now()
+	if len(since) < 2 {
+		return now, fmt.Errorf("invalid period %q, expected something like 7d or 2w", since)
+	}
+	unit := since[len(since)-1]
+	n, err := strconv.Atoi(since[:len(since)-1])
+	if err == nil && n >= 0 {
+		switch unit {
+		case 'd':
+			return now.AddDate(0, 0, -n), nil
+		case 'w':
+			return now.AddDate(0, 0, -7*n), nil
+		case 'm':
+			return now.AddDate(0, -n, 0), nil
+		case 'y':
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return now, fmt.Errorf("invalid period %q, expected something like 7d or 2w", since)
+	}
+	return now.Add(-d), nil
+}
+
+func showTimeline(since time.Time, database *sql.DB) error {
+	rows, err := database.Query("SELECT id, timestamp, "+notes.TextColumn+", tags FROM notes WHERE timestamp >= (?) ORDER BY timestamp", since.Unix())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var id int
+	var timestamp int64
+	var notetext noteText
+	var tags string
+	var lastDay string
+	for rows.Next() {
+		if err := rows.Scan(&id, &timestamp, &notetext, &tags); err != nil {
+			return err
+		}
+		t := time.Unix(timestamp, 0)
+		day := formatDate(t)
+		if day != lastDay {
+			if lastDay != "" {
+				fmt.Println()
+			}
+			fmt.Println(day)
+			lastDay = day
+		}
+		fmt.Printf("  %s  #%d %s\n", t.Format("15:04"), id, formatTagList(tags))
+		for _, line := range strings.Split(strings.TrimRight(string(notetext), "\n"), "\n") {
+			fmt.Printf("      %s\n", line)
+		}
+	}
+	return rows.Err()
+}
+
+// runTimeline prints the notes of a recent period along a timeline.
+func runTimeline(args []string) error {
+	timelineCommand := newFlagSet("timeline")
+	timelineSincePtr := timelineCommand.String("since", "7d", "How far back to go, e.g. 7d, 2w, 3m or 1y.")
+	if err := parseFlags(timelineCommand, args); err != nil {
+		return err
+	}
+	since, err := parseSince(*timelineSincePtr)
+	if err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return showTimeline(since, database)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultTitleLength is the longest generated title, in characters.
+const DefaultTitleLength = 60
+
+var (
+	titleMarkup      = regexp.MustCompile(`^(?:#{1,6}\s+|>\s*|[-*+]\s+(?:\[[ xX]\]\s+)?|\d+[.)]\s+)`)
+	sentenceEndRegex = regexp.MustCompile(`[.!?](\s|$)`)
+)
+
+func titleLength() int {
+	length, err := strconv.Atoi(configValue("title.length", ""))
+	if err != nil || length < 10 {
+		return DefaultTitleLength
+	}
+	return length
+}
+
+// generateTitle derives a title from a note's first non-empty line, cut at
+// the end of its first sentence and shortened to a word boundary. When
+// title.command is configured, the note text is piped to that command, such
+// as a local LLM, and the first line it prints is used instead.
+func generateTitle(text string) string {
+	if command := configValue("title.command", ""); command != "" {
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = strings.NewReader(text)
+		if output, err := cmd.Output(); err == nil {
+			if title := firstLine(string(output), titleLength()); title != "" {
+				return title
+			}
+		}
+	}
+	return plainTitle(text)
+}
+
+// plainTitle is generateTitle without the configured command, cheap enough to
+// run while listing notes that were saved before titles existed.
+func plainTitle(text string) string {
+	line := ""
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.TrimSpace(titleMarkup.ReplaceAllString(strings.TrimSpace(l), ""))
+		if l != "" && l != "```" {
+			line = l
+			break
+		}
+	}
+	if loc := sentenceEndRegex.FindStringIndex(line); loc != nil && loc[0] > 0 {
+		line = line[:loc[0]]
+	}
+	line = stripEmphasis(line)
+	length := titleLength()
+	runes := []rune(line)
+	if len(runes) <= length {
+		return line
+	}
+	cut := string(runes[:length-1])
+	if i := strings.LastIndex(cut, " "); i > length/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-") + ellipsis()
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// inboxStatus is given to quick captures waiting to be triaged.
+const inboxStatus = "inbox"
+
+func setNoteTags(id int, tags tagList, database execer) error {
+	if err := notes.UpdateTags(database, id, tags); err != nil {
+		return err
+	}
+	return journal(database, journalEntry{Op: journalTags, ID: id, Tags: tags})
+}
+
+// captureToInbox saves a quick note for later triage.
+func captureToInbox(text string, database *sql.DB) error {
+	n := note{Time: time.Now(), Text: text, Tags: tagList{"generic"}, Status: inboxStatus}
+	return saveNote(&n, database)
+}
+
+func listInbox(database *sql.DB) error {
+	rows, err := database.Query("SELECT "+noteListColumns()+" FROM notes WHERE status = (?) ORDER BY timestamp", inboxStatus)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	return printRows(rows)
+}
+
+type inboxNote struct {
+	ID   int
+	Time time.Time
+	Text string
+	Tags tagList
+}
+
+func loadInbox(database *sql.DB) ([]inboxNote, error) {
+	rows, err := database.Query("SELECT id, timestamp, "+notes.TextColumn+", tags FROM notes WHERE status = (?) ORDER BY timestamp", inboxStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notes []inboxNote
+	for rows.Next() {
+		var n inboxNote
+		var timestamp int64
+		var text noteText
+		var tags string
+		if err := rows.Scan(&n.ID, &timestamp, &text, &tags); err != nil {
+			return nil, err
+		}
+		n.Time = time.Unix(timestamp, 0)
+		n.Text = string(text)
+		n.Tags = parseTags(tags)
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+func prompt(reader *bufio.Reader, question string) (string, error) {
+	fmt.Print(question)
+	answer, err := reader.ReadString('\n')
+	return strings.TrimSpace(answer), err
+}
+
+// promptSecret asks for a passphrase or key without echoing it. It refuses
+// when stdin is not a terminal, as the secret should then come from the
+// environment or the keyring.
+func promptSecret(question string) (string, error) {
+	if !stdinIsTerminal() {
+		return "", fmt.Errorf("cannot ask for %s, stdin is not a terminal", strings.TrimSuffix(question, ": "))
+	}
+	fmt.Print(question)
+	restore, err := disableEcho()
+	if err != nil {
+		return "", err
+	}
+	// Echo must come back even if the prompt is interrupted.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-signals; ok {
+			restore()
+			fmt.Println()
+			os.Exit(1)
+		}
+	}()
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	signal.Stop(signals)
+	close(signals)
+	restore()
+	fmt.Println()
+	return strings.TrimRight(answer, "\r\n"), err
+}
+
+// addTags merges extra tags into a note's tags, dropping the generic
+// placeholder once the note has a real tag.
+func addTags(tags tagList, extra tagList) tagList {
+	var merged tagList
+	seen := make(map[string]bool)
+	for _, tag := range append(append(tagList{}, tags...), extra...) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		merged = append(merged, tag)
+	}
+	if len(merged) > 1 {
+		var withoutGeneric tagList
+		for _, tag := range merged {
+			if tag != "generic" {
+				withoutGeneric = append(withoutGeneric, tag)
+			}
+		}
+		merged = withoutGeneric
+	}
+	return merged
+}
+
+// triage walks through inbox notes oldest first. Tagging and setting a due
+// date keep the note on screen; archiving, converting to a task, marking it
+// done or skipping moves on to the next one.
+func triage(database *sql.DB) error {
+	notes, err := loadInbox(database)
+	if err != nil {
+		return err
+	}
+	if len(notes) == 0 {
+		fmt.Println("Inbox zero, nothing to triage.")
+		return nil
+	}
+	reader := bufio.NewReader(os.Stdin)
+	processed := 0
+	for i, n := range notes {
+		fmt.Printf("\n[%d/%d] #%d, %s, tags: %s\n\n%s\n\n", i+1, len(notes), n.ID, formatDateTime(n.Time), n.Tags.String(), strings.TrimRight(n.Text, "\n"))
+	next:
+		for {
+			answer, err := prompt(reader, "[t]ag, [d]ue date, [a]rchive, convert to [k] task, mark [x] done, [s]kip, [q]uit: ")
+			if err != nil {
+				fmt.Println()
+				return nil
+			}
+			switch answer {
+			case "t":
+				extra, err := promptTags(reader, "Tags to add (comma-delimited, end with ? to list matches): ", database)
+				if err != nil {
+					return err
+				}
+				if err := checkTagVocabulary(extra, reader); err != nil {
+					fmt.Println(err)
+					continue
+				}
+				n.Tags = addTags(n.Tags, extra)
+				if err := setNoteTags(n.ID, n.Tags, database); err != nil {
+					return err
+				}
+				fmt.Printf("Tags: %s\n", n.Tags.String())
+			case "d":
+				input, err := prompt(reader, "Due date (<yyyy>-<mm>-<dd>): ")
+				if err != nil {
+					return err
+				}
+				due, err := parseDueDate(input)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				if err := setNoteMeta(n.ID, "due", due.Format(dueDateFormat), database); err != nil {
+					return err
+				}
+				fmt.Printf("Due %s\n", formatLocal(due, "Mon 2 Jan 2006"))
+			case "a", "k", "x":
+				status := map[string]string{"a": "archived", "k": "todo", "x": "done"}[answer]
+				if err := setNoteStatus(n.ID, status, database); err != nil {
+					return err
+				}
+				fmt.Printf("Moved #%d to %s\n", n.ID, status)
+				processed++
+				break next
+			case "s":
+				break next
+			case "q":
+				fmt.Printf("Triaged %d of %d notes.\n", processed, len(notes))
+				return nil
+			}
+		}
+	}
+	fmt.Printf("\nTriaged %d of %d notes.\n", processed, len(notes))
+	return nil
+}
+
+// runInbox captures text into the inbox, or lists the inbox without text.
+func runInbox(args []string) error {
+	inboxCommand := newFlagSet("inbox")
+	if err := parseFlags(inboxCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	// With text this is a quick capture, without it lists the inbox.
+	if inboxCommand.NArg() > 0 {
+		err = captureToInbox(strings.Join(inboxCommand.Args(), " "), database)
+	} else {
+		err = listInbox(database)
+	}
+	return err
+}
+
+// runTriage walks through the inbox, filing each note.
+func runTriage(args []string) error {
+	triageCommand := newFlagSet("triage")
+	if err := parseFlags(triageCommand, args); err != nil {
+		return err
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	return triage(database)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateOrderLayouts describes each supported date.order for error messages.
+var dateOrderLayouts = map[string]string{"dmy": "<d>/<m>/<y>", "mdy": "<m>/<d>/<y>", "ymd": "<y>/<m>/<d>"}
+
+func validateDay(day int) error {
+	if day < 1 || day > 31 {
+		return fmt.Errorf("invalid day %d, expected a day of the month from 1 to 31", day)
+	}
+	return nil
+}
+
+func validateMonth(month int) error {
+	if month < 1 || month > 12 {
+		return fmt.Errorf("invalid month %d, expected a month from 1 (January) to 12 (December)", month)
+	}
+	return nil
+}
+
+func validateYear(year int) error {
+	if year >= 0 && year < 100 {
+		return fmt.Errorf("invalid year %d, years need all four digits, e.g. %d", year, 2000+year)
+	}
+	if year < 1 || year > 9999 {
+		return fmt.Errorf("invalid year %d, expected a year such as %d", year, time.Now().Year())
+	}
+	return nil
+}
+
+func validateID(id int) error {
+	if id < 1 {
+		return fmt.Errorf("invalid ID %d, IDs start at 1", id)
+	}
+	return nil
+}
+
+// parseID parses a note ID given as a string argument.
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(s), "#"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q, expected a number such as 12", s)
+	}
+	return id, validateID(id)
+}
+
+// parseDate splits a date written in the given order ("dmy", "mdy" or "ymd")
+// and checks that it names a real calendar day.
+func parseDate(date string, order string) (int, int, int, error) {
+	layout, ok := dateOrderLayouts[order]
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid date.order %q, expected dmy, mdy or ymd", order)
+	}
+	parts := strings.FieldsFunc(date, func(r rune) bool { return r == '/' || r == '-' || r == '.' })
+	if len(parts) != 3 {
+		return 0, 0, 0, fmt.Errorf("invalid date %q, expected %s, e.g. %s", date, layout, formatDateForOrder(time.Now(), order))
+	}
+	var numbers [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid date %q, %q is not a number", date, part)
+		}
+		numbers[i] = n
+	}
+	var day, month, year int
+	switch order {
+	case "dmy":
+		day, month, year = numbers[0], numbers[1], numbers[2]
+	case "mdy":
+		month, day, year = numbers[0], numbers[1], numbers[2]
+	case "ymd":
+		year, month, day = numbers[0], numbers[1], numbers[2]
+	}
+	if err := validateMonth(month); err != nil {
+		if order == "dmy" && day >= 1 && day <= 12 {
+			return 0, 0, 0, fmt.Errorf("%v; for US dates in the form <m>/<d>/<y> add -usa", err)
+		}
+		if order == "mdy" && day >= 1 && day <= 12 {
+			return 0, 0, 0, fmt.Errorf("%v; for dates in the form <d>/<m>/<y> drop -usa", err)
+		}
+		return 0, 0, 0, err
+	}
+	if err := validateYear(year); err != nil {
+		return 0, 0, 0, err
+	}
+	if err := validateDay(day); err != nil {
+		return 0, 0, 0, err
+	}
+	if t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local); t.Day() != day {
+		days := time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.Local).Day()
+		return 0, 0, 0, fmt.Errorf("invalid date %q, %s %d only has %d days", date, time.Month(month), year, days)
+	}
+	return day, month, year, nil
+}
+
+func formatDateForOrder(t time.Time, order string) string {
+	switch order {
+	case "mdy":
+		return t.Format("1/2/2006")
+	case "ymd":
+		return t.Format("2006/1/2")
+	}
+	return t.Format("2/1/2006")
+}
+
+// parseDueDate parses a <yyyy>-<mm>-<dd> date, explaining what is wrong with it.
+func parseDueDate(s string) (time.Time, error) {
+	day, month, year, err := parseDate(s, "ymd")
+	if err == nil && strings.Count(s, "-") != 2 {
+		err = fmt.Errorf("invalid date %q", s)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%v; due dates are written <yyyy>-<mm>-<dd>, e.g. %s", err, time.Now().Format(dueDateFormat))
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local), nil
+}
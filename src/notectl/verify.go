@@ -0,0 +1,97 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/hsnodgrass/notectl/pkg/notes"
+)
+
+// verifyNotes compares each note's text against its stored checksum and
+// reports mismatches. With rehash set, mismatched and missing checksums are
+// recomputed from the current text, accepting intentional external edits. It
+// returns the number of problems found.
+func verifyNotes(id int, rehash bool, database *sql.DB) (int, error) {
+	query := "SELECT id, " + notes.TextColumn + ", checksum FROM notes"
+	var args []interface{}
+	if id > 0 {
+		query += " WHERE id = (?)"
+		args = append(args, id)
+	}
+	rows, err := database.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var stale []int
+	var checked, unreadable, mismatched, missing int
+	for rows.Next() {
+		var noteID int
+		var text noteText
+		var checksum sql.NullString
+		checked++
+		if err := rows.Scan(&noteID, &text, &checksum); err != nil {
+			// Corrupt compressed text fails to scan; the ID is still useful.
+			fmt.Printf("note %d: unreadable: %s\n", noteID, err)
+			unreadable++
+			continue
+		}
+		switch {
+		case !checksum.Valid || checksum.String == "":
+			missing++
+			stale = append(stale, noteID)
+		case checksum.String != notes.Checksum(string(text)):
+			fmt.Printf("note %d: checksum mismatch\n", noteID)
+			mismatched++
+			stale = append(stale, noteID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	fmt.Printf("Checked %d notes: %d mismatched, %d unreadable, %d without checksum\n", checked, mismatched, unreadable, missing)
+	if !rehash {
+		if missing > 0 {
+			fmt.Println("Run notectl verify -rehash to store checksums for notes without one.")
+		}
+		return mismatched + unreadable, nil
+	}
+	for _, noteID := range stale {
+		text, err := getNoteText(noteID, database)
+		if err != nil {
+			return 0, err
+		}
+		if err := notes.UpdateChecksum(database, noteID, notes.Checksum(text)); err != nil {
+			return 0, err
+		}
+	}
+	fmt.Printf("Rehashed %d notes\n", len(stale))
+	return unreadable, nil
+}
+
+// runVerify checks note checksums, failing when any does not match.
+func runVerify(args []string) error {
+	verifyCommand := newFlagSet("verify")
+	verifyIDPtr := verifyCommand.Int("i", 0, "Only verify the note with this ID.")
+	verifyRehashPtr := verifyCommand.Bool("rehash", false, "Recompute checksums for mismatched notes after intentional external edits.")
+	if err := parseFlags(verifyCommand, args); err != nil {
+		return err
+	}
+	if *verifyIDPtr != 0 {
+		if err := validateID(*verifyIDPtr); err != nil {
+			return err
+		}
+	}
+	database, err := openDatabase(databasePath())
+	if err != nil {
+		return err
+	}
+	problems, err := verifyNotes(*verifyIDPtr, *verifyRehashPtr, database)
+	if err != nil {
+		return err
+	}
+	if problems > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// Build information, set at link time with
+// -ldflags "-X main.version=... -X main.commit=... -X main.date=... -X main.builtBy=...".
+var (
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+	builtBy = "unknown"
+)
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	BuiltBy   string `json:"builtBy"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+	Driver    string `json:"driver"`
+}
+
+func currentBuildInfo() buildInfo {
+	return buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		BuiltBy:   builtBy,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Driver:    sqliteDriver,
+	}
+}
+
+func showVersion(asJSON bool) error {
+	info := currentBuildInfo()
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+	fmt.Printf("notectl %s (commit %s, built %s by %s, %s %s)\n", info.Version, info.Commit, info.Date, info.BuiltBy, info.GoVersion, info.Platform)
+	return nil
+}
+
+// runVersion prints build information.
+func runVersion(args []string) error {
+	versionCommand := newFlagSet("version")
+	versionJSONPtr := versionCommand.Bool("json", false, "Print build information as JSON.")
+	if err := parseFlags(versionCommand, args); err != nil {
+		return err
+	}
+	return showVersion(*versionJSONPtr)
+}
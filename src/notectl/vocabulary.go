@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// strictTags reports whether tags.strict limits tags to the tags.allowed
+// vocabulary.
+func strictTags() bool {
+	switch strings.ToLower(configValue("tags.strict", "false")) {
+	case "true", "on", "yes", "1":
+		return true
+	}
+	return false
+}
+
+// allowedTags reads the comma-delimited tags.allowed setting. The generic
+// placeholder is always allowed.
+func allowedTags() map[string]bool {
+	allowed := map[string]bool{"generic": true}
+	for _, tag := range strings.Split(configValue("tags.allowed", ""), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			allowed[tag] = true
+		}
+	}
+	return allowed
+}
+
+// interactiveReader returns a reader for vocabulary prompts, or nil when
+// stdin is not a terminal and unknown tags should simply be rejected.
+func interactiveReader() *bufio.Reader {
+	if !stdinIsTerminal() {
+		return nil
+	}
+	return bufio.NewReader(os.Stdin)
+}
+
+// checkTagVocabulary rejects tags outside the allowed vocabulary in strict
+// mode. With a reader, it first offers to add each unknown tag to the
+// vocabulary in the configuration file.
+func checkTagVocabulary(tags tagList, reader *bufio.Reader) error {
+	if !strictTags() {
+		return nil
+	}
+	allowed := allowedTags()
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || allowed[tag] {
+			continue
+		}
+		if reader != nil || assumeYes {
+			ok, err := confirm(reader, "Tag %q is not in the vocabulary, add it?", false, tag)
+			if err != nil {
+				return err
+			}
+			if ok {
+				allowed[tag] = true
+				var vocabulary []string
+				for t := range allowed {
+					if t != "generic" {
+						vocabulary = append(vocabulary, t)
+					}
+				}
+				sort.Strings(vocabulary)
+				if err := setConfigValue("tags.allowed", strings.Join(vocabulary, ", ")); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		return fmt.Errorf("tag %q is not allowed, add it to tags.allowed or turn off tags.strict", tag)
+	}
+	return nil
+}
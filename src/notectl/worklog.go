@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// worklogTag marks commands recorded by the shell integration.
+const worklogTag = "worklog"
+
+// defaultWorklogPatterns match commands worth keeping in an on-call trail.
+const defaultWorklogPatterns = `^(sudo\s+)?(terraform|kubectl|helm|ssh|ansible-playbook|systemctl|docker|aws|gcloud)\b`
+
+const bashWorklogHook = `__notectl_worklog() {
+	local cmd
+	cmd=$(HISTTIMEFORMAT= history 1 | sed 's/^ *[0-9]* *//')
+	if [ -n "$cmd" ] && [ "$cmd" != "$__notectl_worklog_last" ]; then
+		__notectl_worklog_last=$cmd
+		(notectl worklog record -- "$cmd" >/dev/null 2>&1 &)
+	fi
+}
+PROMPT_COMMAND="__notectl_worklog${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`
+
+const zshWorklogHook = `__notectl_worklog() {
+	(notectl worklog record -- "$1" >/dev/null 2>&1 &)
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec __notectl_worklog
+`
+
+// worklogPatterns compiles the worklog.patterns setting, a comma separated
+// list of regular expressions matched against each command.
+func worklogPatterns() ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, pattern := range strings.Split(configValue("worklog.patterns", defaultWorklogPatterns), ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid worklog pattern %q: %s", pattern, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// recordWorklog saves a command as a note when the worklog is enabled and the
+// command matches one of the configured patterns.
+func recordWorklog(command string, database *sql.DB) error {
+	if configValue("worklog", "off") != "on" {
+		return nil
+	}
+	command = strings.TrimSpace(command)
+	patterns, err := worklogPatterns()
+	if err != nil {
+		return err
+	}
+	for _, re := range patterns {
+		if !re.MatchString(command) {
+			continue
+		}
+		meta := metaList{"command": command}
+		if dir, err := os.Getwd(); err == nil {
+			meta["cwd"] = dir
+		}
+		if host, err := os.Hostname(); err == nil {
+			meta["host"] = host
+		}
+		n := note{Time: time.Now(), Text: command, Tags: tagList{worklogTag}, Meta: meta}
+		return n.Save(database)
+	}
+	return nil
+}
+
+// runWorklog dispatches the "worklog init", "worklog record" and "worklog
+// show" subcommands.
+func runWorklog(args []string, database *sql.DB) error {
+	usage := "usage: notectl worklog <init bash|zsh|record -- <command>|show [-since 1d]>"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "init":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		switch args[1] {
+		case "bash":
+			fmt.Print(bashWorklogHook)
+		case "zsh":
+			fmt.Print(zshWorklogHook)
+		default:
+			return fmt.Errorf("unsupported shell %q, expected bash or zsh", args[1])
+		}
+		if configValue("worklog", "off") != "on" {
+			fmt.Fprintln(os.Stderr, "# Set worklog = on in the config to start recording.")
+		}
+		return nil
+	case "record":
+		command := args[1:]
+		if len(command) > 0 && command[0] == "--" {
+			command = command[1:]
+		}
+		return recordWorklog(strings.Join(command, " "), database)
+	case "show":
+		showCommand := newFlagSet("worklog show")
+		sincePtr := showCommand.String("since", "1d", "How far back to show, e.g. 12h, 1d or 1w.")
+		if err := parseFlags(showCommand, args[1:]); err != nil {
+			return err
+		}
+		since, err := parseSince(*sincePtr)
+		if err != nil {
+			return err
+		}
+		rows, err := database.Query("SELECT "+noteListColumns()+" FROM notes WHERE timestamp >= (?) AND "+tagMatchClause+" ORDER BY timestamp", since.Unix(), worklogTag)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		return printMatches(rows)
+	default:
+		return errors.New(usage)
+	}
+}